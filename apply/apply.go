@@ -0,0 +1,293 @@
+package apply
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/onepanelio/cli/output"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+// DefaultFieldManager is the field manager the engine applies with, so the fields opctl sets are owned by it
+const DefaultFieldManager = "opctl"
+
+// Conflict is a field set by another field manager with a different value, that server-side apply refused to take over
+type Conflict struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (c Conflict) String() string {
+	if c.Field == "" {
+		return c.Message
+	}
+
+	return c.Field + ": " + c.Message
+}
+
+// Result is what the engine did with an object
+type Result struct {
+	Object *unstructured.Unstructured
+	// Result is one of output.ResultApplied, output.ResultDeleted, output.ResultNotFound, output.ResultFailed or output.ResultSkipped
+	Result string
+	Err    error
+	// Conflicts are the fields apply failed to set, as they are owned by another field manager
+	Conflicts []Conflict
+}
+
+// String returns the object like kubectl prints it, as in deployment.apps/onepanel-core
+func (r Result) String() string {
+	gvk := r.Object.GroupVersionKind()
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group != "" {
+		kind += "." + gvk.Group
+	}
+
+	return kind + "/" + r.Object.GetName()
+}
+
+// Resource returns the result as the resource of an output document
+func (r Result) Resource() output.Resource {
+	resource := output.Resource{
+		APIVersion: r.Object.GetAPIVersion(),
+		Kind:       r.Object.GetKind(),
+		Namespace:  r.Object.GetNamespace(),
+		Name:       r.Object.GetName(),
+		Result:     r.Result,
+	}
+	if r.Err != nil {
+		resource.Error = r.Err.Error()
+	}
+	for _, conflict := range r.Conflicts {
+		resource.Conflicts = append(resource.Conflicts, conflict.String())
+	}
+
+	return resource
+}
+
+// Engine applies and deletes objects with the dynamic client, finding their resources with a RESTMapper
+type Engine struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+	// FieldManager is the field manager of server-side apply
+	FieldManager string
+	// Force if true, apply takes over the fields owned by other field managers, instead of failing with conflicts
+	Force bool
+	// DefaultNamespace is the namespace of namespaced objects that don't have one
+	DefaultNamespace string
+}
+
+// NewEngine returns an engine using client, and mapper to find the resource of each kind
+func NewEngine(client dynamic.Interface, mapper meta.RESTMapper) *Engine {
+	return &Engine{
+		client:           client,
+		mapper:           mapper,
+		FieldManager:     DefaultFieldManager,
+		DefaultNamespace: metav1.NamespaceDefault,
+	}
+}
+
+// Parse returns the objects of content, a multi document yaml, in order
+func Parse(content []byte) ([]*unstructured.Unstructured, error) {
+	nodes, err := kio.FromBytes(content)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*unstructured.Unstructured, 0, len(nodes))
+	for _, node := range nodes {
+		data, err := node.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		object := &unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, nil
+}
+
+// Apply applies objects, in order, with server-side apply. Every object is tried, even if one before it failed.
+// The results are in the order of objects. An error is returned if any object failed, or ctx was done before all were applied.
+func (e *Engine) Apply(ctx context.Context, objects []*unstructured.Unstructured) ([]Result, error) {
+	results := make([]Result, len(objects))
+	for i, object := range objects {
+		if ctx.Err() != nil {
+			results[i] = Result{Object: object, Result: output.ResultSkipped}
+			continue
+		}
+
+		results[i] = e.apply(ctx, object)
+	}
+
+	return results, resultsError(ctx, results)
+}
+
+func (e *Engine) apply(ctx context.Context, object *unstructured.Unstructured) Result {
+	resource, object, err := e.resourceFor(object)
+	if err != nil {
+		return failed(object, err)
+	}
+
+	data, err := object.MarshalJSON()
+	if err != nil {
+		return failed(object, err)
+	}
+
+	force := e.Force
+	_, err = resource.Patch(ctx, object.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: e.FieldManager,
+		Force:        &force,
+	})
+	if err != nil {
+		result := failed(object, err)
+		result.Conflicts = conflicts(err)
+		if len(result.Conflicts) != 0 {
+			result.Err = fmt.Errorf("%v: %v conflicts with other field managers, apply with --force-conflicts to take them over", result.String(), len(result.Conflicts))
+		}
+
+		return result
+	}
+
+	return Result{Object: object, Result: output.ResultApplied}
+}
+
+// Delete deletes objects, in reverse order, so the namespaces and definitions go after what is in them.
+// Objects that are already gone are output.ResultNotFound. The results are in the order of objects.
+// An error is returned if any object failed, or ctx was done before all were deleted.
+func (e *Engine) Delete(ctx context.Context, objects []*unstructured.Unstructured) ([]Result, error) {
+	results := make([]Result, len(objects))
+	for i := len(objects) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			results[i] = Result{Object: objects[i], Result: output.ResultSkipped}
+			continue
+		}
+
+		results[i] = e.delete(ctx, objects[i])
+	}
+
+	return results, resultsError(ctx, results)
+}
+
+func (e *Engine) delete(ctx context.Context, object *unstructured.Unstructured) Result {
+	resource, object, err := e.resourceFor(object)
+	if meta.IsNoMatchError(err) {
+		// The definition of the kind is gone, and its objects along with it
+		return Result{Object: object, Result: output.ResultNotFound}
+	}
+	if err != nil {
+		return failed(object, err)
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	err = resource.Delete(ctx, object.GetName(), metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if apierrors.IsNotFound(err) {
+		return Result{Object: object, Result: output.ResultNotFound}
+	}
+	if err != nil {
+		return failed(object, err)
+	}
+
+	return Result{Object: object, Result: output.ResultDeleted}
+}
+
+// Patch patches the object of kind gvk, with a strategic merge patch, data in JSON
+func (e *Engine) Patch(ctx context.Context, gvk schema.GroupVersionKind, namespace, name string, data []byte) (Result, error) {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	object.SetNamespace(namespace)
+	object.SetName(name)
+
+	resource, object, err := e.resourceFor(object)
+	if err != nil {
+		result := failed(object, err)
+		return result, result.Err
+	}
+
+	if _, err := resource.Patch(ctx, name, types.StrategicMergePatchType, data, metav1.PatchOptions{FieldManager: e.FieldManager}); err != nil {
+		result := failed(object, err)
+		return result, result.Err
+	}
+
+	return Result{Object: object, Result: output.ResultApplied}, nil
+}
+
+// resourceFor returns the client of the resource of object, and object with its namespace set, if it is namespaced.
+// If the kind is not known, the RESTMapper is reset once, in case it was defined since, like by a CustomResourceDefinition applied before it.
+func (e *Engine) resourceFor(object *unstructured.Unstructured) (dynamic.ResourceInterface, *unstructured.Unstructured, error) {
+	gvk := object.GroupVersionKind()
+	mapping, err := e.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		if resettable, ok := e.mapper.(interface{ Reset() }); ok {
+			resettable.Reset()
+			mapping, err = e.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+	}
+	if err != nil {
+		return nil, object, err
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return e.client.Resource(mapping.Resource), object, nil
+	}
+
+	if object.GetNamespace() == "" {
+		object = object.DeepCopy()
+		object.SetNamespace(e.DefaultNamespace)
+	}
+
+	return e.client.Resource(mapping.Resource).Namespace(object.GetNamespace()), object, nil
+}
+
+// failed returns the result of object failing with err, which is prefixed with the object
+func failed(object *unstructured.Unstructured, err error) Result {
+	result := Result{Object: object, Result: output.ResultFailed}
+	result.Err = fmt.Errorf("%v: %v", result.String(), err.Error())
+
+	return result
+}
+
+// conflicts returns the field manager conflicts of err, a server-side apply error
+func conflicts(err error) []Conflict {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok || !apierrors.IsConflict(err) || status.Status().Details == nil {
+		return nil
+	}
+
+	result := make([]Conflict, 0)
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			result = append(result, Conflict{Field: cause.Field, Message: cause.Message})
+		}
+	}
+
+	return result
+}
+
+// resultsError returns the errors of results, along with the error of ctx if it is done, or nil if there are none
+func resultsError(ctx context.Context, results []Result) error {
+	errs := make([]error, 0)
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
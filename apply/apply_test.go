@@ -0,0 +1,124 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/onepanelio/cli/output"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testObjects = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: onepanel
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: onepanel-core
+  namespace: onepanel
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: onepanel
+`
+
+func newTestEngine() (*Engine, *dynamicfake.FakeDynamicClient) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	engine := NewEngine(client, mapper)
+	engine.DefaultNamespace = "onepanel"
+
+	return engine, client
+}
+
+func TestEngine_Apply(t *testing.T) {
+	engine, client := newTestEngine()
+	objects, err := Parse([]byte(testObjects))
+	assert.Nil(t, err)
+
+	patches := make([]k8stesting.PatchAction, 0)
+	client.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		patches = append(patches, patch)
+
+		if patch.GetResource().Resource == "deployments" {
+			conflict := apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"}, patch.GetName(), nil)
+			conflict.ErrStatus.Details.Causes = []metav1.StatusCause{
+				{Type: metav1.CauseTypeFieldManagerConflict, Field: ".spec.replicas", Message: `conflict with "kubectl-client-side-apply"`},
+			}
+			return true, nil, conflict
+		}
+
+		return true, objects[0], nil
+	})
+
+	results, err := engine.Apply(context.Background(), objects)
+	assert.NotNil(t, err)
+	assert.Len(t, patches, 3)
+	assert.Equal(t, types.ApplyPatchType, patches[0].GetPatchType())
+	assert.Equal(t, "onepanel", patches[2].GetNamespace())
+
+	assert.Equal(t, output.ResultApplied, results[0].Result)
+	assert.Equal(t, output.ResultFailed, results[1].Result)
+	assert.Equal(t, []Conflict{{Field: ".spec.replicas", Message: `conflict with "kubectl-client-side-apply"`}}, results[1].Conflicts)
+	assert.Equal(t, "deployment.apps/onepanel-core", results[1].String())
+	assert.Equal(t, output.ResultApplied, results[2].Result)
+
+	resource := results[1].Resource()
+	assert.Equal(t, []string{`.spec.replicas: conflict with "kubectl-client-side-apply"`}, resource.Conflicts)
+}
+
+func TestEngine_Apply_stopped(t *testing.T) {
+	engine, _ := newTestEngine()
+	objects, err := Parse([]byte(testObjects))
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := engine.Apply(ctx, objects)
+	assert.True(t, errors.Is(err, context.Canceled))
+	for _, result := range results {
+		assert.Equal(t, output.ResultSkipped, result.Result)
+	}
+}
+
+func TestEngine_Delete(t *testing.T) {
+	engine, client := newTestEngine()
+	objects, err := Parse([]byte(testObjects))
+	assert.Nil(t, err)
+
+	deleted := make([]string, 0)
+	client.PrependReactor("delete", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deleteAction := action.(k8stesting.DeleteAction)
+		deleted = append(deleted, deleteAction.GetResource().Resource)
+		if deleteAction.GetResource().Resource == "configmaps" {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, deleteAction.GetName())
+		}
+
+		return true, nil, nil
+	})
+
+	results, err := engine.Delete(context.Background(), objects)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"configmaps", "deployments", "namespaces"}, deleted)
+	assert.Equal(t, output.ResultDeleted, results[0].Result)
+	assert.Equal(t, output.ResultDeleted, results[1].Result)
+	assert.Equal(t, output.ResultNotFound, results[2].Result)
+}
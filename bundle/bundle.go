@@ -0,0 +1,373 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Names of the files in a bundle
+const (
+	// MetadataFileName is always the first file of a bundle, so it can be read without going through the whole archive
+	MetadataFileName = "bundle.yaml"
+	// ChecksumsFileName is always the last file of a bundle. It has the sha256 of every other file, in the format of sha256sum.
+	ChecksumsFileName      = "SHA256SUMS"
+	ManifestsDirectory     = "manifests"
+	ConfigFileName         = "config.yaml"
+	ParamsTemplateFileName = "params.template.yaml"
+	ImageListFileName      = "images.txt"
+	ImagesFileName         = "images.tar"
+	// PatchesDirectory has the patch files config.yaml references
+	PatchesDirectory = "patches"
+	// ExtraComponentsDirectory has the extra components config.yaml references, by directory name
+	ExtraComponentsDirectory = "extraComponents"
+)
+
+// Metadata describes what a bundle was created from
+type Metadata struct {
+	CLIVersion       string `yaml:"cliVersion"`
+	ManifestsVersion string `yaml:"manifestsVersion"`
+	// Images is true if the bundle has the images, and not only the list of them
+	Images bool `yaml:"images"`
+}
+
+// Writer writes a bundle, a gzipped tar file, keeping track of the checksum of each file
+type Writer struct {
+	gzip      *gzip.Writer
+	tar       *tar.Writer
+	checksums map[string]string
+}
+
+// NewWriter starts a bundle in w with metadata. Close must be called to finish it.
+func NewWriter(w io.Writer, metadata *Metadata) (*Writer, error) {
+	gzipWriter := gzip.NewWriter(w)
+	writer := &Writer{
+		gzip:      gzipWriter,
+		tar:       tar.NewWriter(gzipWriter),
+		checksums: make(map[string]string),
+	}
+
+	content, err := yaml.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writer.AddFile(MetadataFileName, content); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// AddFile adds a file named name with content
+func (w *Writer) AddFile(name string, content []byte) error {
+	return w.add(name, int64(len(content)), bytes.NewReader(content))
+}
+
+// AddFileFromDisk adds the file at filePath as name, without reading it all into memory
+func (w *Writer) AddFileFromDisk(name, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	return w.add(name, info.Size(), file)
+}
+
+// AddDirectory adds the files of the directory tree at root under name.
+// Like files.HashDirectory, .git directories and symlinks are skipped.
+func (w *Writer) AddDirectory(name, root string) error {
+	root = filepath.Clean(root)
+
+	return filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+
+		return w.AddFileFromDisk(path.Join(name, filepath.ToSlash(relativePath)), filePath)
+	})
+}
+
+func (w *Writer) add(name string, size int64, reader io.Reader) error {
+	if _, ok := w.checksums[name]; ok {
+		return fmt.Errorf("%v is already in the bundle", name)
+	}
+
+	if err := w.tar.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size}); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	if _, err := io.CopyN(w.tar, io.TeeReader(reader, hash), size); err != nil {
+		return fmt.Errorf("unable to add %v: %v", name, err.Error())
+	}
+
+	w.checksums[name] = hex.EncodeToString(hash.Sum(nil))
+
+	return nil
+}
+
+// Close writes the checksums and finishes the bundle. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	names := make([]string, 0, len(w.checksums))
+	for name := range w.checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checksums := ""
+	for _, name := range names {
+		checksums += w.checksums[name] + "  " + name + "\n"
+	}
+
+	if err := w.tar.WriteHeader(&tar.Header{Name: ChecksumsFileName, Mode: 0644, Size: int64(len(checksums))}); err != nil {
+		return err
+	}
+	if _, err := w.tar.Write([]byte(checksums)); err != nil {
+		return err
+	}
+
+	if err := w.tar.Close(); err != nil {
+		return err
+	}
+
+	return w.gzip.Close()
+}
+
+// forEachFile calls f with each file of the bundle at bundlePath, until f returns an error
+func forEachFile(bundlePath string, f func(header *tar.Header, reader io.Reader) error) error {
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return fmt.Errorf("%v is not a bundle: %v", bundlePath, err.Error())
+	}
+	defer gzipReader.Close()
+
+	reader := tar.NewReader(gzipReader)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read %v: %v", bundlePath, err.Error())
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := f(header, reader); err != nil {
+			return err
+		}
+	}
+}
+
+// errStop stops forEachFile early, without an error
+var errStop = fmt.Errorf("stop")
+
+// ReadMetadata returns the metadata of the bundle at bundlePath.
+// Only the start of the bundle is read, as the metadata is its first file.
+func ReadMetadata(bundlePath string) (*Metadata, error) {
+	var metadata *Metadata
+
+	err := forEachFile(bundlePath, func(header *tar.Header, reader io.Reader) error {
+		if header.Name != MetadataFileName {
+			return fmt.Errorf("%v is not a bundle, it does not start with %v", bundlePath, MetadataFileName)
+		}
+
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+
+		metadata = &Metadata{}
+		if err := yaml.Unmarshal(content, metadata); err != nil {
+			return fmt.Errorf("unable to read %v of %v: %v", MetadataFileName, bundlePath, err.Error())
+		}
+
+		return errStop
+	})
+	if err != nil && err != errStop {
+		return nil, err
+	}
+
+	if metadata == nil {
+		return nil, fmt.Errorf("%v is not a bundle, it has no %v", bundlePath, MetadataFileName)
+	}
+
+	return metadata, nil
+}
+
+// readChecksumsFile returns the content of the checksums file of the bundle at bundlePath
+func readChecksumsFile(bundlePath string) ([]byte, error) {
+	var content []byte
+
+	err := forEachFile(bundlePath, func(header *tar.Header, reader io.Reader) error {
+		if header.Name != ChecksumsFileName {
+			return nil
+		}
+
+		var err error
+		content, err = ioutil.ReadAll(reader)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if content == nil {
+		return nil, fmt.Errorf("%v is not a bundle, it has no %v", bundlePath, ChecksumsFileName)
+	}
+
+	return content, nil
+}
+
+// readChecksums returns the checksums of the bundle at bundlePath by file name
+func readChecksums(bundlePath string) (map[string]string, error) {
+	content, err := readChecksumsFile(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "  ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%v of %v is badly formatted: %v", ChecksumsFileName, bundlePath, line)
+		}
+		checksums[parts[1]] = parts[0]
+	}
+
+	return checksums, nil
+}
+
+// ID returns the sha256 of the checksums file of the bundle at bundlePath.
+// Bundles with the same files have the same ID, whatever their name or the manifests version they were created with.
+func ID(bundlePath string) (string, error) {
+	content, err := readChecksumsFile(bundlePath)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Extract writes the files of the bundle at bundlePath under directory, like manifests, to destination.
+// The checksum of each file is verified. If any does not match, or is missing, an error is returned,
+// and destination may have some of the files.
+func Extract(bundlePath, directory, destination string) error {
+	checksums, err := readChecksums(bundlePath)
+	if err != nil {
+		return err
+	}
+
+	prefix := strings.TrimSuffix(directory, "/") + "/"
+	destination = filepath.Clean(destination)
+
+	expected := 0
+	for name := range checksums {
+		if strings.HasPrefix(name, prefix) {
+			expected++
+		}
+	}
+
+	extracted := 0
+	err = forEachFile(bundlePath, func(header *tar.Header, reader io.Reader) error {
+		if !strings.HasPrefix(header.Name, prefix) {
+			return nil
+		}
+
+		checksum, ok := checksums[header.Name]
+		if !ok {
+			return fmt.Errorf("%v of %v has no checksum", header.Name, bundlePath)
+		}
+
+		// Make sure files stay in destination, see http://bit.ly/2MsjAWE
+		filePath := filepath.Join(destination, filepath.FromSlash(strings.TrimPrefix(header.Name, prefix)))
+		if !strings.HasPrefix(filePath, destination+string(os.PathSeparator)) {
+			return fmt.Errorf("%s: illegal file path", header.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+			return err
+		}
+
+		file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+
+		hash := sha256.New()
+		_, err = io.Copy(file, io.TeeReader(reader, hash))
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+			return fmt.Errorf("%v of %v is corrupted, its sha256 is %v instead of %v", header.Name, bundlePath, actual, checksum)
+		}
+
+		extracted++
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if extracted != expected {
+		return fmt.Errorf("%v is incomplete, it has %v of the %v files in %v", bundlePath, extracted, expected, directory)
+	}
+
+	return nil
+}
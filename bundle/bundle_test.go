@@ -0,0 +1,109 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeBundle writes a bundle with a manifests directory and a config file to dir
+func writeBundle(t *testing.T, dir string) string {
+	manifests := filepath.Join(dir, "source")
+	assert.Nil(t, os.MkdirAll(filepath.Join(manifests, "common", "base"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(manifests, "common", "base", "kustomization.yaml"), []byte("resources: []\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(manifests, "vars.yaml"), []byte("vars: []\n"), 0644))
+
+	bundlePath := filepath.Join(dir, "bundle.tgz")
+	file, err := os.Create(bundlePath)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	writer, err := NewWriter(file, &Metadata{CLIVersion: "v1.0.0", ManifestsVersion: "v1.0.0"})
+	assert.Nil(t, err)
+	assert.Nil(t, writer.AddDirectory(ManifestsDirectory, manifests))
+	assert.Nil(t, writer.AddFile(ConfigFileName, []byte("spec: {}\n")))
+	assert.NotNil(t, writer.AddFile(ConfigFileName, []byte("spec: {}\n")))
+	assert.Nil(t, writer.Close())
+
+	return bundlePath
+}
+
+func TestExtract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	bundlePath := writeBundle(t, dir)
+
+	metadata, err := ReadMetadata(bundlePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0.0", metadata.ManifestsVersion)
+	assert.False(t, metadata.Images)
+
+	destination := filepath.Join(dir, "manifests")
+	assert.Nil(t, Extract(bundlePath, ManifestsDirectory, destination))
+
+	content, err := ioutil.ReadFile(filepath.Join(destination, "common", "base", "kustomization.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "resources: []\n", string(content))
+
+	_, err = os.Stat(filepath.Join(destination, ConfigFileName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExtract_Corrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	bundlePath := filepath.Join(dir, "bundle.tgz")
+	file, err := os.Create(bundlePath)
+	assert.Nil(t, err)
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	entries := []struct {
+		name    string
+		content string
+	}{
+		{MetadataFileName, "manifestsVersion: v1.0.0\n"},
+		{"manifests/vars.yaml", "changed\n"},
+		{ChecksumsFileName, "0000000000000000000000000000000000000000000000000000000000000000  manifests/vars.yaml\n"},
+	}
+	for _, entry := range entries {
+		assert.Nil(t, tarWriter.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content))}))
+		_, err := tarWriter.Write([]byte(entry.content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, tarWriter.Close())
+	assert.Nil(t, gzipWriter.Close())
+	assert.Nil(t, file.Close())
+
+	err = Extract(bundlePath, ManifestsDirectory, filepath.Join(dir, "manifests"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "corrupted")
+}
+
+func TestID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	first, err := ID(writeBundle(t, dir))
+	assert.Nil(t, err)
+	assert.Len(t, first, 64)
+
+	same, err := ID(writeBundle(t, dir))
+	assert.Nil(t, err)
+	assert.Equal(t, first, same)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "source", "common", "base", "configmap.yaml"), []byte("kind: ConfigMap\n"), 0644))
+	changed, err := ID(writeBundle(t, dir))
+	assert.Nil(t, err)
+	assert.NotEqual(t, first, changed)
+}
@@ -76,12 +76,12 @@ type ArtifactRepositoryProvider struct {
 }
 
 // Load loads any provider specific information required from the cluster
-func (a *ArtifactRepositoryProvider) Load(c *kubernetes.Clientset, namespace string) error {
+func (a *ArtifactRepositoryProvider) Load(ctx context.Context, c *kubernetes.Clientset, namespace string) error {
 	if a.GCS == nil {
 		return nil
 	}
 
-	secret, err := c.CoreV1().Secrets(namespace).Get(context.Background(), "onepanel", v1.GetOptions{})
+	secret, err := c.CoreV1().Secrets(namespace).Get(ctx, "onepanel", v1.GetOptions{})
 	if err != nil {
 		return err
 	}
@@ -303,9 +303,9 @@ func (g *ArtifactRepositoryGCSProvider) FormatKey(namespace, workflowName, podNa
 
 // TestMinioStorageConnection checks to see if the storage connection has all of the requirements for Onepanel
 // This includes connecting, creating a file, downloading a file, deleting a file.
-// An error with a human friendly message is returned, if there is one.
-func TestMinioStorageConnection(client *minio.Client, bucketName string) error {
-	exists, err := client.BucketExists(bucketName)
+// An error with a human friendly message is returned, if there is one. It stops early if ctx is done.
+func TestMinioStorageConnection(ctx context.Context, client *minio.Client, bucketName string) error {
+	exists, err := client.BucketExistsWithContext(ctx, bucketName)
 	if err != nil {
 		if minioErr, ok := err.(minio.ErrorResponse); ok && minioErr.Code == "SignatureDoesNotMatch" {
 			return fmt.Errorf("unable to connect to the bucket with provided credentials. Original error: %v", err.Error())
@@ -330,7 +330,7 @@ func TestMinioStorageConnection(client *minio.Client, bucketName string) error {
 	objectPath := "onepanel/storage-test/" + randomString + ".txt"
 
 	// Make sure the object does not exist first - we don't want to overwrite anything
-	_, err = client.StatObject(bucketName, objectPath, minio.StatObjectOptions{})
+	_, err = client.StatObjectWithContext(ctx, bucketName, objectPath, minio.StatObjectOptions{})
 	if err != nil {
 		// We want NoSuchKey, that means it's ok
 		if minioErr, ok := err.(minio.ErrorResponse); ok && minioErr.Code != "NoSuchKey" {
@@ -341,7 +341,7 @@ func TestMinioStorageConnection(client *minio.Client, bucketName string) error {
 		return fmt.Errorf("test file '%v' already exists, please try again", objectPath)
 	}
 
-	_, err = client.FPutObject(bucketName, objectPath, localFilePath, minio.PutObjectOptions{})
+	_, err = client.FPutObjectWithContext(ctx, bucketName, objectPath, localFilePath, minio.PutObjectOptions{})
 	if err != nil {
 		return fmt.Errorf("unable to upload file object. Original error %v", err)
 	}
@@ -350,7 +350,7 @@ func TestMinioStorageConnection(client *minio.Client, bucketName string) error {
 		return fmt.Errorf("unable to delete test file locally. Original error: %v", err.Error())
 	}
 
-	err = client.FGetObject(bucketName, objectPath, localFilePath, minio.GetObjectOptions{})
+	err = client.FGetObjectWithContext(ctx, bucketName, objectPath, localFilePath, minio.GetObjectOptions{})
 	if err != nil {
 		return fmt.Errorf("unable to download test file locally. Original error: %v", err.Error())
 	}
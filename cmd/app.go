@@ -6,7 +6,6 @@ import (
 	opConfig "github.com/onepanelio/cli/config"
 	"github.com/onepanelio/cli/util"
 	"github.com/spf13/cobra"
-	"log"
 	"strings"
 )
 
@@ -15,7 +14,23 @@ var appCmd = &cobra.Command{
 	Short:   "Various app functions.",
 	Long:    "Inspect and execute various app functions..",
 	Example: "app",
-	Run:     func(cmd *cobra.Command, args []string) {},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+// appStatusDocument is the structured output of app status
+type appStatusDocument struct {
+	// Ready is true if all the Pods of the deployment are running
+	Ready              bool                     `json:"ready"`
+	Network            *util.NetworkInformation `json:"network,omitempty"`
+	ArtifactRepository storageTestDocument      `json:"artifactRepository"`
+}
+
+// storageTestDocument is the result of testing the connection to a storage
+type storageTestDocument struct {
+	Successful bool   `json:"successful"`
+	Error      string `json:"error,omitempty"`
 }
 
 var statusCmd = &cobra.Command{
@@ -23,97 +38,128 @@ var statusCmd = &cobra.Command{
 	Short:   "Check deployment status.",
 	Long:    "Check deployment status by checking pods statuses.",
 	Example: "status",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := commandContext()
+
 		k8sClient, err := util.NewKubernetesClient()
 		if err != nil {
-			fmt.Printf("Unable to create kubernetes client: error %v", err.Error())
-			return
+			return clusterError("unable to create kubernetes client: %v", err.Error())
 		}
 
 		configFilePath := "config.yaml"
 		config, err := opConfig.FromFile(configFilePath)
 		if err != nil {
-			fmt.Printf("Unable to read configuration file: %v", err.Error())
-			return
+			return configError("unable to read configuration file: %v", err.Error())
 		}
 		yamlFile, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
 		if err != nil {
-			fmt.Println("Error parsing configuration file.")
-			return
+			return paramsError("error parsing %v: %v", config.Spec.Params, err.Error())
 		}
 
-		ready, err := util.NamespacesExist(k8sClient, util.NamespacesToCheck(yamlFile)...)
+		ready, err := util.NamespacesExist(ctx, k8sClient, util.NamespacesToCheck(yamlFile)...)
 		if err != nil {
-			flatMap := yamlFile.FlattenToKeyValue(util.AppendDotFlatMapKeyFormatter)
+			if ctx.Err() != nil {
+				return clusterError("%v", err.Error())
+			}
+
+			flatMap, flatErr := yamlFile.FlattenToKeyValue(util.AppendDotFlatMapKeyFormatter)
+			if flatErr != nil {
+				return paramsError("%v", flatErr.Error())
+			}
 			provider, providerErr := util.GetYamlStringValue(flatMap, "application.provider")
 			if providerErr != nil {
-				fmt.Printf("Unable to read application.provider from params.yaml %v", providerErr.Error())
-				return
+				return paramsError("unable to read application.provider from params.yaml %v", providerErr.Error())
 			}
 			if provider == nil {
-				fmt.Printf("application.provider is not set in params.yaml")
-				return
+				return paramsError("application.provider is not set in params.yaml")
 			}
 
 			if *provider == "microk8s" {
-				fmt.Printf("Unable to connect to cluster. Make sure you are running with \nKUBECONFIG=./kubeconfig opctl app status\nError: %v", err.Error())
-				return
+				return clusterError("unable to connect to cluster. Make sure you are running with \n--kubeconfig ./kubeconfig, or set spec.cluster.kubeconfig to ./kubeconfig in config.yaml\nError: %v", err.Error())
 			}
 
-			fmt.Println(err.Error())
-			return
+			return clusterError("%v", err.Error())
 		}
 
-		if ready {
-			fmt.Println("Your deployment is ready.")
-		} else {
-			fmt.Println("Your deployment is NOT ready; not all Pods are running. To view all Pods:")
-			fmt.Println("$ kubectl get pods -A")
+		document := &appStatusDocument{Ready: ready}
+		if !structuredOutput() {
+			if ready {
+				fmt.Println("Your deployment is ready.")
+			} else {
+				fmt.Println("Your deployment is NOT ready; not all Pods are running. To view all Pods:")
+				fmt.Println("$ kubectl get pods -A")
+			}
 		}
 
 		// Get cluster deployment URL
 		url, err := util.GetDeployedWebURL(yamlFile)
 		if err != nil {
-			fmt.Printf("[error] Unable to get deployed url from configuration: %v", err.Error())
-			return
+			return paramsError("unable to get deployed url from configuration: %v", err.Error())
 		}
 
-		util.PrintClusterNetworkInformation(k8sClient, url)
+		if structuredOutput() {
+			document.Network, err = util.GetClusterNetworkInformation(ctx, k8sClient, url)
+		} else {
+			err = util.PrintClusterNetworkInformation(ctx, k8sClient, url)
+		}
+		if err != nil {
+			return clusterError("unable to get the network information of the cluster: %v", err.Error())
+		}
 
 		_, artifactRepositoryNode := yamlFile.Get("artifactRepository")
 		artifactRepositoryConfig := storage.ArtifactRepositoryProvider{}
 		if err := artifactRepositoryNode.Decode(&artifactRepositoryConfig); err != nil {
-			fmt.Printf("Unable to check artifactRepository configuration. Original error: %v", err.Error())
-			return
+			return paramsError("unable to check artifactRepository configuration. Original error: %v", err.Error())
 		}
 
 		defaultNamespace := yamlFile.GetValue("application.defaultNamespace").Value
 		domain := yamlFile.GetValue("application.domain").Value
 		isHTTPS := strings.ToLower(yamlFile.GetValue("application.insecure").Value) == "false"
 
-		if err := artifactRepositoryConfig.Load(k8sClient, defaultNamespace); err != nil {
-			fmt.Println(err)
-			return
+		if err := artifactRepositoryConfig.Load(ctx, k8sClient, defaultNamespace); err != nil {
+			return clusterError("%v", err.Error())
 		}
 
 		minioClient, err := artifactRepositoryConfig.MinioClient(defaultNamespace, domain, isHTTPS)
 		if err != nil {
-			log.Printf("Unable to run tests on storage. Original error %v", err.Error())
-			return
+			return failure("unable to run tests on storage. Original error %v", err.Error())
 		}
 
 		bucket, err := artifactRepositoryConfig.Bucket()
 		if err != nil {
-			fmt.Printf(err.Error())
-			return
+			return paramsError("%v", err.Error())
+		}
+
+		if err := storage.TestMinioStorageConnection(ctx, minioClient, bucket); err != nil {
+			if ctx.Err() != nil {
+				return failure("unable to run tests on storage: %v", err.Error())
+			}
+
+			if structuredOutput() {
+				document.ArtifactRepository.Error = err.Error()
+				if printErr := printDocument(document); printErr != nil {
+					return printErr
+				}
+			} else {
+				fmt.Printf("ArtifactRepository tests: Failed\n")
+			}
+			return failure("artifactRepository tests failed: %v", err.Error())
 		}
 
-		if err := storage.TestMinioStorageConnection(minioClient, bucket); err != nil {
-			fmt.Printf("ArtifactRepository tests: Failed\n")
-			fmt.Printf("  " + err.Error())
+		document.ArtifactRepository.Successful = true
+		if structuredOutput() {
+			if err := printDocument(document); err != nil {
+				return err
+			}
 		} else {
 			fmt.Printf("ArtifactRepository tests: Successful\n")
 		}
+
+		if !ready {
+			return failure("your deployment is not ready")
+		}
+
+		return nil
 	},
 }
 
@@ -1,175 +1,379 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
 	"io/ioutil"
-	"log"
 	"path/filepath"
 	"time"
 
+	"github.com/ghodss/yaml"
+	"github.com/onepanelio/cli/apply"
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/logging"
+	"github.com/onepanelio/cli/output"
+	"github.com/onepanelio/cli/policy"
 	"github.com/onepanelio/cli/util"
+	"github.com/onepanelio/cli/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
 
 	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/template"
 	"github.com/spf13/cobra"
 )
 
+// applyAPIVersionsFor is the Kubernetes version whose API versions apply validates the resources for.
+// If empty, the version of the cluster is used.
+var applyAPIVersionsFor string
+
+// applyLocked if true, apply refuses to run when the inputs of the build differ from opctl.lock
+var applyLocked bool
+
+// applyWaitTimeout is how long apply waits for the deployment to complete, once its resources are applied
+var applyWaitTimeout time.Duration
+
+// applyForceConflicts if true, apply takes over the fields of the resources owned by other field managers
+var applyForceConflicts bool
+
+// applyDocument is the structured output of apply
+type applyDocument struct {
+	Resources []output.Resource `json:"resources"`
+	// Ready is true if the deployment completed, with all its Pods running
+	Ready   bool                     `json:"ready"`
+	Network *util.NetworkInformation `json:"network,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+}
+
 // applyCmd represents the apply command
 var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Applies application YAML to your Kubernetes cluster.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configFilePath := "config.yaml"
 		if len(args) > 1 {
 			configFilePath = args[0]
 		}
 
+		ctx := commandContext()
+
 		k8sClient, err := util.NewKubernetesClient()
 		if err != nil {
-			fmt.Printf("Unable to create kubernetes client: error %v", err.Error())
-			return
+			return clusterError("unable to create kubernetes client: %v", err.Error())
+		}
+
+		engine, err := util.NewApplyEngine()
+		if err != nil {
+			return clusterError("unable to create kubernetes client: %v", err.Error())
 		}
+		engine.Force = applyForceConflicts
 
-		fmt.Printf("Starting deployment...\n\n")
+		logging.Infof("Starting deployment...")
 
 		config, err := opConfig.FromFile(configFilePath)
 		if err != nil {
-			fmt.Printf("Unable to read configuration file: %v", err.Error())
-			return
+			return configError("unable to read configuration file: %v", err.Error())
 		}
 
 		yamlFile, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
 		if err != nil {
-			fmt.Printf("Unable to read params.yaml: %v", err.Error())
-			return
+			return paramsError("unable to read params.yaml: %v", err.Error())
 		}
 
 		var database *opConfig.Database = nil
 		if !yamlFile.HasKey("database") {
-			database, err = GetDatabaseConfigurationFromCluster(k8sClient)
+			database, err = GetDatabaseConfigurationFromCluster(ctx, k8sClient)
 			if err != nil {
-				fmt.Printf("Unable to connect to cluster to check information: %v", err.Error())
-				return
+				return clusterError("unable to connect to cluster to check information: %v", err.Error())
 			}
 		}
 
+		generated, err := opConfig.LoadGeneratedValues(opConfig.GeneratedValuesFilePath)
+		if err != nil {
+			return configError("%v", err.Error())
+		}
+
+		lock, err := newBuildLock(configFilePath, config)
+		if err != nil {
+			return configError("%v", err.Error())
+		}
+
+		if applyLocked {
+			if err := checkLock(lock); err != nil {
+				return failure("refusing to apply, %v", err.Error())
+			}
+		}
+
+		redactor, err := newRedactor(config)
+		if err != nil {
+			return paramsError("%v", err.Error())
+		}
+		if !showSecrets {
+			logging.Default().SetRedact(redactor.String)
+		}
+
+		kubernetesVersion, err := getKubernetesVersion(applyAPIVersionsFor, k8sClient)
+		if err != nil {
+			return clusterError("unable to get the version of the cluster: %v", err.Error())
+		}
+
+		policyEngine, err := policy.NewEngine(config.Spec.Policy)
+		if err != nil {
+			return configError("unable to read configuration file: %v", err.Error())
+		}
+
 		options := &GenerateKustomizeResultOptions{
-			Database: database,
-			Config:   config,
+			Database:          database,
+			Config:            config,
+			Generated:         generated,
+			Lock:              lock,
+			KubernetesVersion: kubernetesVersion,
+			Policy:            policyEngine,
 		}
 
-		overlayComponentFirst := filepath.Join("common", "application", "base")
-		baseOverlayComponent := config.GetOverlayComponent(overlayComponentFirst)
-		applicationBaseKustomizeTemplate := TemplateFromSimpleOverlayedComponents(baseOverlayComponent)
-		applicationResult, err := GenerateKustomizeResult(applicationBaseKustomizeTemplate, options)
+		// Both phases are built, and checked against the policy, before anything is deployed
+		phases := deploymentPhases(config)
+		applicationResult, err := GenerateKustomizeResult(phases[0].Template, options)
 		if err != nil {
-			fmt.Printf("%s\n", HumanizeKustomizeError(err))
-			return
+			printPolicyReport(policyEngine)
+			return buildError(err)
 		}
 
-		applicationKubernetesYamlFilePath := filepath.Join(".onepanel", "application.kubernetes.yaml")
-		if err := ioutil.WriteFile(applicationKubernetesYamlFilePath, []byte(applicationResult), 0644); err != nil {
-			log.Printf("Error writing to temporary file: %v", err.Error())
-			return
+		result, err := GenerateKustomizeResult(phases[1].Template, options)
+		printPolicyReport(policyEngine)
+		if err != nil {
+			return buildError(err)
 		}
+		redactor.AddGenerated(generated.Values()...)
 
-		if err := applyKubernetesFile(applicationKubernetesYamlFilePath); err != nil {
-			provider := yamlFile.GetValue("application.provider").Value
-			if provider == "microk8s" {
-				fmt.Printf("Unable to connect to cluster. Make sure you are running with \nKUBECONFIG=./kubeconfig opctl apply\nError: %v", err.Error())
-				return
+		if err := saveGeneratedValues(generated); err != nil {
+			return err
+		}
+
+		baseObjects, err := apply.Parse([]byte(applicationResult))
+		if err != nil {
+			return failure("%v", err.Error())
+		}
+		objects, err := apply.Parse([]byte(result))
+		if err != nil {
+			return failure("%v", err.Error())
+		}
+		document := &applyDocument{Resources: append(phaseResources(phases[0].Name, baseObjects), phaseResources(phases[1].Name, objects)...)}
+		baseResources := document.Resources[:len(baseObjects)]
+		resources := document.Resources[len(baseObjects):]
+
+		// fail records err in the document, and prints it, so the results of what was applied before are not lost.
+		// If apply was stopped, how far it got is logged as well.
+		fail := func(err error) error {
+			err = stoppedError(err)
+			if exitCode(err) == ExitCodeTimeout || exitCode(err) == ExitCodeInterrupted {
+				logProgress(document.Resources)
+			}
+
+			if structuredOutput() {
+				document.Error = err.Error()
+				if printErr := printDocument(document); printErr != nil {
+					return printErr
+				}
 			}
 
-			fmt.Printf("\nFailed: %v", err.Error())
-			return
+			return err
 		}
 
-		for i := 0; i < 5; i++ {
-			applicationRunning, err := util.IsApplicationControllerManagerRunning(k8sClient)
-			if err != nil {
-				fmt.Printf("Error checking if application is running: error %v", err.Error())
-				return
+		applicationKubernetesYamlFilePath := filepath.Join(".onepanel", "application.kubernetes.yaml")
+		if err := files.WriteFileAtomic(applicationKubernetesYamlFilePath, []byte(applicationResult), 0644); err != nil {
+			return failure("unable to write to temporary file: %v", err.Error())
+		}
+
+		if err := applyObjects(ctx, engine, baseObjects, baseResources); err != nil {
+			if ctx.Err() != nil {
+				return fail(applyError("%v", err.Error()))
 			}
 
-			if applicationRunning {
-				break
+			provider := yamlFile.GetValue("application.provider").Value
+			if provider == "microk8s" {
+				return fail(applyError("unable to connect to cluster. Make sure you are running with \n--kubeconfig ./kubeconfig, or set spec.cluster.kubeconfig to ./kubeconfig in config.yaml\nError: %v", err.Error()))
 			}
 
-			time.Sleep(1 * time.Second)
+			return fail(applyError("failed: %v", err.Error()))
 		}
 
-		//Apply the rest of the yaml
-		kustomizeTemplate := TemplateFromSimpleOverlayedComponents(config.GetOverlayComponents(overlayComponentFirst))
-
-		result, err := GenerateKustomizeResult(kustomizeTemplate, options)
-		if err != nil {
-			fmt.Printf("%s\n", HumanizeKustomizeError(err))
-			return
+		if _, err := util.Poll(ctx, time.Second, 5*time.Second, func(ctx context.Context) (bool, error) {
+			return util.IsApplicationControllerManagerRunning(ctx, k8sClient)
+		}); err != nil {
+			return fail(clusterError("error checking if application is running: %v", err.Error()))
 		}
 
+		//Apply the rest of the yaml
 		finalKubernetesYamlFilePath := filepath.Join(".onepanel", "kubernetes.yaml")
-		if err := ioutil.WriteFile(finalKubernetesYamlFilePath, []byte(result), 0644); err != nil {
-			log.Printf("Error writing to temporary file: %v", err.Error())
-			return
+		if err := files.WriteFileAtomic(finalKubernetesYamlFilePath, []byte(result), 0644); err != nil {
+			return fail(failure("unable to write to temporary file: %v", err.Error()))
 		}
 
 		for i := 0; i < 5; i++ {
-			err = applyKubernetesFile(finalKubernetesYamlFilePath)
-			if err == nil {
+			err = applyObjects(ctx, engine, objects, resources)
+			if err == nil || i == 4 {
 				break
 			}
 
-			time.Sleep(time.Second * 5)
+			if sleepErr := util.Sleep(ctx, 5*time.Second); sleepErr != nil {
+				break
+			}
+		}
+		if err != nil {
+			return fail(applyError("failed: %v", err.Error()))
 		}
 
 		if config.Spec.HasLikeComponent("kfserving") {
 			defaultNamespace := yamlFile.GetValue("application.defaultNamespace").Value
 			filePath := filepath.Join(config.Spec.ManifestsRepo, "kfserving", "patch", "serviceaccount.yaml")
 
-			if err := util.KubectlPatch(defaultNamespace, "serviceaccount/default", filePath); err != nil {
-				fmt.Printf(err.Error())
-				return
-			}
-		}
-
-		attempts := 0
-		maxAttempts := 5
-		for attempts < maxAttempts {
-			fmt.Println("\nWaiting for deployment to complete...")
-			deploymentStatus, err := util.NamespacesExist(k8sClient, util.NamespacesToCheck(yamlFile)...)
+			content, err := ioutil.ReadFile(filePath)
 			if err != nil {
-				fmt.Printf("Unable to check if namespaces exist in cluster. Error %v", err.Error())
-				return
+				return fail(configError("unable to read %v: %v", filePath, err.Error()))
 			}
-
-			if deploymentStatus {
-				fmt.Printf("\nDeployment is complete.\n\n")
-				break
+			patch, err := yaml.YAMLToJSON(content)
+			if err != nil {
+				return fail(configError("unable to read %v: %v", filePath, err.Error()))
 			}
 
-			if attempts >= maxAttempts {
-				fmt.Println("\nDeployment is still in progress. Check again with `opctl app status` in a few minutes.")
-				break
+			result, err := engine.Patch(ctx, schema.GroupVersionKind{Version: "v1", Kind: "ServiceAccount"}, defaultNamespace, "default", patch)
+			if err != nil {
+				return fail(applyError("%v", err.Error()))
 			}
+			logResult(result)
+		}
 
-			time.Sleep(20 * time.Second)
-			attempts++
+		document.Ready, err = util.Poll(ctx, 20*time.Second, applyWaitTimeout, func(ctx context.Context) (bool, error) {
+			logging.Infof("Waiting for deployment to complete...")
+			return util.NamespacesExist(ctx, k8sClient, util.NamespacesToCheck(yamlFile)...)
+		})
+		if err != nil {
+			return fail(clusterError("unable to check if namespaces exist in cluster: %v", err.Error()))
+		}
+		if document.Ready {
+			logging.Infof("Deployment is complete.")
+		} else {
+			logging.Infof("Deployment is still in progress. Check again with `opctl app status` in a few minutes.")
 		}
 
 		url, err := util.GetDeployedWebURL(yamlFile)
 		if err != nil {
-			fmt.Printf("[error] Unable to get deployed url from configuration: %v", err.Error())
-			return
+			return fail(paramsError("unable to get deployed url from configuration: %v", err.Error()))
+		}
+
+		if structuredOutput() {
+			document.Network, err = util.GetClusterNetworkInformation(ctx, k8sClient, url)
+			if err != nil {
+				if ctx.Err() != nil {
+					return fail(clusterError("%v", err.Error()))
+				}
+				logging.Errorf("%v", err.Error())
+			}
+			return printDocument(document)
+		}
+
+		if err := util.PrintClusterNetworkInformation(ctx, k8sClient, url); err != nil {
+			if ctx.Err() != nil {
+				return fail(clusterError("%v", err.Error()))
+			}
+			logging.Errorf("%v", err.Error())
 		}
 
-		util.PrintClusterNetworkInformation(k8sClient, url)
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(applyCmd)
 	applyCmd.Flags().BoolVarP(&Dev, "latest", "", false, "Sets conditions to allow development/latest testing.")
+	applyCmd.Flags().StringVarP(&applyAPIVersionsFor, "api-versions-for", "", "", "Kubernetes version whose API versions the resources may use. Defaults to the version of the cluster. Only the API versions depend on it, fields are always checked against the Kubernetes "+validation.DefaultVersion.String()+" API types built into opctl.")
+	applyCmd.Flags().BoolVarP(&showSecrets, "show-secrets", "", false, "Does not redact the values of secret params from the logs.")
+	applyCmd.Flags().DurationVarP(&applyWaitTimeout, "wait-timeout", "", 100*time.Second, "How long to wait for the deployment to complete, once its resources are applied.")
+	applyCmd.Flags().BoolVarP(&applyForceConflicts, "force-conflicts", "", false, "Takes over the fields of the resources that are owned by other field managers, like kubectl, instead of failing with conflicts.")
+	applyCmd.Flags().BoolVarP(&applyLocked, "locked", "", false, "Refuses to apply if the CLI, manifests, params or image tags differ from the ones recorded in opctl.lock by build.")
 }
 
-func applyKubernetesFile(filePath string) (err error) {
-	return util.KubectlApply(filePath)
+// deploymentPhase is a part of the deployment, applied after the phases before it
+type deploymentPhase struct {
+	Name     string
+	Template template.Kustomize
+}
+
+// deploymentPhases returns the phases apply deploys, in order.
+// The application base goes first, as the rest of the yaml has resources of the kinds it defines.
+func deploymentPhases(config *opConfig.Config) []deploymentPhase {
+	overlayComponentFirst := filepath.Join("common", "application", "base")
+	baseOverlayComponent := config.GetOverlayComponent(overlayComponentFirst)
+
+	// User patches may target resources that are not part of the application base, which kustomize rejects.
+	// They, and the extra components, are applied with the rest of the yaml.
+	applicationBaseConfig := *config
+	applicationBaseConfig.Spec.ExtraComponents = nil
+	applicationBaseConfig.Spec.PatchesStrategicMerge = nil
+	applicationBaseConfig.Spec.PatchesJson6902 = nil
+
+	return []deploymentPhase{
+		{
+			Name:     "application-base",
+			Template: TemplateFromSimpleOverlayedComponents(baseOverlayComponent, &applicationBaseConfig),
+		},
+		{
+			Name:     "application",
+			Template: TemplateFromSimpleOverlayedComponents(config.GetOverlayComponents(overlayComponentFirst), config),
+		},
+	}
+}
+
+// applyObjects applies objects, setting the result of each in resources, the resources of their phase, in the same order
+func applyObjects(ctx context.Context, engine *apply.Engine, objects []*unstructured.Unstructured, resources []output.Resource) error {
+	results, err := engine.Apply(ctx, objects)
+	for i, result := range results {
+		setResult(&resources[i], result)
+	}
+
+	return err
+}
+
+// setResult sets resource to result, keeping its phase, and logs result
+func setResult(resource *output.Resource, result apply.Result) {
+	phase := resource.Phase
+	*resource = result.Resource()
+	resource.Phase = phase
+
+	logResult(result)
+}
+
+// logResult logs the object of result and what was done with it, like kubectl prints them, along with its conflicts
+func logResult(result apply.Result) {
+	logging.Infof("%v %v", result.String(), result.Result)
+	for _, conflict := range result.Conflicts {
+		logging.Warningf("%v conflict: %v", result.String(), conflict.String())
+	}
+}
+
+// phaseResources returns the resources of objects, the objects of phase, as skipped until they are applied
+func phaseResources(phase string, objects []*unstructured.Unstructured) []output.Resource {
+	resources := make([]output.Resource, 0, len(objects))
+	for _, object := range objects {
+		resource := apply.Result{Object: object, Result: output.ResultSkipped}.Resource()
+		resource.Phase = phase
+		resources = append(resources, resource)
+	}
+
+	return resources
+}
+
+// getKubernetesVersion parses version, or if it is empty, asks the cluster for its version
+func getKubernetesVersion(version string, c *kubernetes.Clientset) (*validation.Version, error) {
+	if version != "" {
+		return validation.ParseVersion(version)
+	}
+
+	info, err := c.Discovery().ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	return validation.ParseServerVersion(info.Major, info.Minor)
 }
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"testing"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_deploymentPhases(t *testing.T) {
+	config := &opConfig.Config{
+		Spec: opConfig.ConfigSpec{
+			Components:            []string{"common/application/base", "argo/base"},
+			Overlays:              []string{"argo/overlays/gke"},
+			PatchesStrategicMerge: []string{"/patches/replicas.yaml"},
+			PatchesJson6902:       []opConfig.PatchJson6902{{Path: "/patches/patch.yaml"}},
+			ExtraComponents:       []string{"/extra/monitoring"},
+		},
+	}
+
+	phases := deploymentPhases(config)
+	assert.Len(t, phases, 2)
+
+	applicationBase := phases[0]
+	assert.Equal(t, "application-base", applicationBase.Name)
+	assert.Equal(t, []string{"common/application/base"}, applicationBase.Template.Resources)
+	assert.Empty(t, applicationBase.Template.PatchesStrategicMerge)
+	assert.Empty(t, applicationBase.Template.PatchesJson6902)
+
+	application := phases[1]
+	assert.Equal(t, "application", application.Name)
+	assert.Equal(t, []string{"argo/overlays/gke", "/extra/monitoring"}, application.Template.Resources)
+	assert.Equal(t, []string{"/patches/replicas.yaml"}, application.Template.PatchesStrategicMerge)
+	assert.Equal(t, "/patches/patch.yaml", application.Template.PatchesJson6902[0].Path)
+
+	assert.Equal(t, []string{"/patches/replicas.yaml"}, config.Spec.PatchesStrategicMerge, "the config is not changed")
+}
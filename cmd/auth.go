@@ -20,65 +20,83 @@ var authCmd = &cobra.Command{
 	Short:   "Get authentication information.",
 	Long:    "Intended to be used to get authentication information.",
 	Example: "auth token",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
 	},
 }
 
+// tokenDocument is the structured output of auth token
+type tokenDocument struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
 var tokenCmd = &cobra.Command{
 	Use:     "token",
 	Short:   "Get the token for a provider.",
 	Long:    "Get a token for a given provider. Google Cloud Platform is different from minikube, for example.",
 	Example: "auth token",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		config, err := util.NewConfig()
 		if err != nil {
-			fmt.Printf("Error getting kubernetes configuration: %v", err.Error())
-			return
+			return clusterError("error getting kubernetes configuration: %v", err.Error())
 		}
 
 		if ServiceAccountName == "" {
 			ServiceAccountName = "admin"
 		}
-		token, username, err := util.GetBearerToken(config, "", ServiceAccountName)
+		ctx := commandContext()
+		token, username, err := util.GetBearerToken(ctx, config, "", ServiceAccountName)
 		if err != nil {
+			if ctx.Err() != nil {
+				return clusterError("%v", err.Error())
+			}
+
 			configFilePath := "config.yaml"
 			opConfig, opErr := opConfig.FromFile(configFilePath)
 			if opErr != nil {
-				fmt.Printf("Unable to read configuration file: %v", err.Error())
-				return
+				return configError("unable to read configuration file: %v", opErr.Error())
 			}
 			yamlFile, yamlErr := util.LoadDynamicYamlFromFile(opConfig.Spec.Params)
 			if yamlErr != nil {
-				fmt.Printf("Error reading file '%v' %v", opConfig.Spec.Params, yamlErr.Error())
-				return
+				return paramsError("error reading file '%v' %v", opConfig.Spec.Params, yamlErr.Error())
 			}
 
-			flatMap := yamlFile.FlattenToKeyValue(util.AppendDotFlatMapKeyFormatter)
+			flatMap, flatErr := yamlFile.FlattenToKeyValue(util.AppendDotFlatMapKeyFormatter)
+			if flatErr != nil {
+				return paramsError("%v", flatErr.Error())
+			}
 			provider, providerErr := util.GetYamlStringValue(flatMap, "application.provider")
 			if providerErr != nil {
-				fmt.Printf("Unable to read application.provider from params.yaml %v", providerErr.Error())
-				return
+				return paramsError("unable to read application.provider from params.yaml %v", providerErr.Error())
 			}
 			if provider == nil {
-				fmt.Printf("application.provider is not set in params.yaml")
-				return
+				return paramsError("application.provider is not set in params.yaml")
 			}
 
 			if *provider == "microk8s" {
-				fmt.Printf("Make sure you are running with \nKUBECONFIG=./kubeconfig opctl auth token\nError: %v", err.Error())
-				return
+				return clusterError("unable to connect to cluster. Make sure you are running with \n--kubeconfig ./kubeconfig, or set spec.cluster.kubeconfig to ./kubeconfig in config.yaml\nError: %v", err.Error())
 			}
 
-			fmt.Printf("Error encountered for user %s: %s\n", username, err.Error())
+			return clusterError("error encountered for user %s: %s", username, err.Error())
 		}
 
+		currentTokenString := ""
 		if token != "" {
 			currentTokenBytes := md5.Sum([]byte(token))
-			currentTokenString := hex.EncodeToString(currentTokenBytes[:])
+			currentTokenString = hex.EncodeToString(currentTokenBytes[:])
+		}
+
+		if structuredOutput() {
+			return printDocument(&tokenDocument{Username: username, Token: currentTokenString})
+		}
 
+		if currentTokenString != "" {
 			fmt.Println(username)
 			fmt.Println(currentTokenString)
 		}
+
+		return nil
 	},
 }
 
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
@@ -12,7 +13,6 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/rand"
 	"k8s.io/client-go/kubernetes"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -21,10 +21,19 @@ import (
 	yaml2 "gopkg.in/yaml.v3"
 
 	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/export"
 	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/gitops"
+	"github.com/onepanelio/cli/helm"
+	"github.com/onepanelio/cli/images"
+	"github.com/onepanelio/cli/logging"
 	"github.com/onepanelio/cli/manifest"
+	"github.com/onepanelio/cli/output"
+	"github.com/onepanelio/cli/policy"
+	"github.com/onepanelio/cli/redact"
 	"github.com/onepanelio/cli/template"
 	"github.com/onepanelio/cli/util"
+	"github.com/onepanelio/cli/validation"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 	"sigs.k8s.io/kustomize/api/filesys"
@@ -32,94 +41,584 @@ import (
 	"sigs.k8s.io/kustomize/api/resmap"
 )
 
+// buildInjectedVars are the variables GenerateKustomizeResult adds to the params before substituting them in the manifests
+var buildInjectedVars = []string{
+	"applicationApiUrl",
+	"applicationApiWsUrl",
+	"applicationApiPath",
+	"applicationUiPath",
+	"applicationApiGrpcPort",
+	"providerType",
+	"onepanelApiUrl",
+	"applicationCoreImageTag",
+	"applicationCoreImagePullPolicy",
+	"applicationCoreuiImageTag",
+	"applicationCoreuiImagePullPolicy",
+	"applicationNodePoolOptions",
+	"kfservingSecureCookies",
+	"kfservingDefaultExternalScheme",
+	"metalLbAddresses",
+	"metalLbSecretKey",
+	"artifactRepositoryProvider",
+	"artifactRepositoryProviderSecret",
+	"artifactRepositoryServiceAccountKey",
+	"artifactRepositoryS3AccessKey",
+	"artifactRepositoryS3SecretKey",
+	"artifactRepositoryS3Bucket",
+	"artifactRepositoryS3Region",
+	"artifactRepositoryS3Endpoint",
+	"artifactRepositoryS3PublicEndpoint",
+	"artifactRepositoryS3Insecure",
+	"workflowEngineContainerRuntimeExecutor",
+	"databaseHost",
+	"databaseUsername",
+	"databasePassword",
+	"databasePort",
+	"databaseDatabaseName",
+	"databaseDriverName",
+}
+
+// buildConsumedVars are the params GenerateKustomizeResult reads itself, rather than substituting them in the manifests
+var buildConsumedVars = []string{
+	"applicationDomain",
+	"applicationFqdn",
+	"applicationInsecure",
+	"applicationProvider",
+	"applicationDefaultNamespace",
+	"applicationNodePool",
+	"metalLbAddresses",
+	"loggingImage",
+	"loggingVolumeStorage",
+}
+
+// buildOutputDir if set, build writes each resource to its own file in this directory
+var buildOutputDir string
+
+// buildFormat is how build writes the resources to buildOutputDir, see the build formats below
+var buildFormat string
+
+// buildGitOps is the GitOps tool build generates objects for, see gitops.Tools
+var buildGitOps string
+
+// buildGitOpsOptions describe the repository --output-dir is committed to, for --gitops
+var buildGitOpsOptions = gitops.Options{Name: "onepanel"}
+
+// buildAPIVersionsFor is the Kubernetes version whose API versions build validates the resources for
+var buildAPIVersionsFor string
+
+// showSecrets if true, build prints Secrets and secret params as they are, and they are not redacted from logs
+var showSecrets bool
+
+// secretParams are the params opctl knows have credentials
+var secretParams = []string{
+	"database.password",
+	"artifactRepository.s3.accessKey",
+	"artifactRepository.s3.secretKey",
+	"artifactRepository.gcs.serviceAccountKey",
+	"artifactRepository.abs.accessKey",
+	"artifactRepository.abs.secretKey",
+	"artifactRepository.abs.storageAccountKey",
+}
+
+// buildCheck if set, build compares the yaml to this file instead of printing it, and exits with 1 if they differ
+var buildCheck string
+
+const (
+	// buildFormatYaml writes the resources as yaml files with a kustomization.yaml
+	buildFormatYaml = "yaml"
+	// buildFormatHelm writes the resources as a Helm chart
+	buildFormatHelm = "helm"
+)
+
+// buildDocument is the structured output of build, a summary of the resources instead of their yaml
+type buildDocument struct {
+	Resources []output.Resource `json:"resources,omitempty"`
+	// OutputDir and Files are set with --output-dir
+	OutputDir string              `json:"outputDir,omitempty"`
+	Files     []string            `json:"files,omitempty"`
+	Check     *buildCheckDocument `json:"check,omitempty"`
+}
+
+// buildCheckDocument is the result of build --check
+type buildCheckDocument struct {
+	File    string `json:"file"`
+	Matches bool   `json:"matches"`
+	// Line is the first line that differs
+	Line int `json:"line,omitempty"`
+}
+
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Builds application YAML for preview.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		configFilePath := "config.yaml"
 		if len(args) > 1 {
 			configFilePath = args[0]
 		}
 
+		if buildFormat != buildFormatYaml && buildFormat != buildFormatHelm {
+			return usageError("unknown format '%v', expected %v or %v", buildFormat, buildFormatYaml, buildFormatHelm)
+		}
+
+		if buildFormat == buildFormatHelm && buildOutputDir == "" {
+			return usageError("--format %v requires --output-dir", buildFormatHelm)
+		}
+
+		if buildGitOps != "" && (buildOutputDir == "" || buildFormat != buildFormatYaml) {
+			return usageError("--gitops requires --output-dir, with the %v format", buildFormatYaml)
+		}
+
+		if buildGitOps != "" && buildGitOps != gitops.ToolArgoCD && buildGitOps != gitops.ToolFlux {
+			return usageError("unknown gitops tool '%v', expected %v", buildGitOps, strings.Join(gitops.Tools(), " or "))
+		}
+
+		if buildGitOps != "" && buildGitOpsOptions.RepoURL == "" {
+			return usageError("--gitops requires --gitops-repo-url")
+		}
+
+		if buildCheck != "" && buildOutputDir != "" {
+			return usageError("--check can't be used with --output-dir")
+		}
+
+		kubernetesVersion, err := validation.ParseVersion(buildAPIVersionsFor)
+		if err != nil {
+			return usageError("%v", err.Error())
+		}
+
 		k8sClient, err := util.NewKubernetesClient()
 		if err != nil {
-			fmt.Printf("Unable to get kubernetes client error: %v", err.Error())
-			return
+			return clusterError("unable to get kubernetes client: %v", err.Error())
 		}
 
 		config, err := opConfig.FromFile(configFilePath)
 		if err != nil {
-			fmt.Printf("Unable to read configuration file: %v", err.Error())
-			fmt.Println() // This gives us a newline as we get an extra "exiting" message
-			return
+			return configError("unable to read configuration file: %v", err.Error())
 		}
 
-		kustomizeTemplate := TemplateFromSimpleOverlayedComponents(config.GetOverlayComponents(""))
+		kustomizeTemplate := TemplateFromSimpleOverlayedComponents(config.GetOverlayComponents(""), config)
 
-		databaseConfig, err := GetDatabaseConfigurationFromCluster(k8sClient)
+		databaseConfig, err := GetDatabaseConfigurationFromCluster(commandContext(), k8sClient)
 		if err != nil {
-			fmt.Printf("[error] %v", err.Error())
-			return
+			return clusterError("%v", err.Error())
 		}
 
-		log.Printf("Building...")
-		result, err := GenerateKustomizeResult(kustomizeTemplate, &GenerateKustomizeResultOptions{
-			Config:   config,
-			Database: databaseConfig,
-		})
+		generated, err := opConfig.LoadGeneratedValues(opConfig.GeneratedValuesFilePath)
+		if err != nil {
+			return configError("%v", err.Error())
+		}
+
+		lock, err := newBuildLock(configFilePath, config)
 		if err != nil {
-			fmt.Printf("%s\n", HumanizeKustomizeError(err))
-			return
+			return configError("%v", err.Error())
 		}
 
-		fmt.Printf("%v", result)
+		redactor, err := newRedactor(config)
+		if err != nil {
+			return paramsError("%v", err.Error())
+		}
+		if !showSecrets {
+			logging.Default().SetRedact(redactor.String)
+		}
+
+		policyEngine, err := policy.NewEngine(config.Spec.Policy)
+		if err != nil {
+			return configError("unable to read configuration file: %v", err.Error())
+		}
+		// The report goes to stderr, after the build, so it does not mix with the yaml
+		defer printPolicyReport(policyEngine)
+
+		logging.Infof("Building...")
+		options := &GenerateKustomizeResultOptions{
+			Config:            config,
+			Database:          databaseConfig,
+			Generated:         generated,
+			Lock:              lock,
+			KubernetesVersion: kubernetesVersion,
+			Policy:            policyEngine,
+		}
+
+		if buildGitOps != "" {
+			paths, err := writeGitOps(config, options)
+			if err != nil {
+				return buildError(err)
+			}
+
+			saveLock(lock)
+			if structuredOutput() {
+				return printDocument(&buildDocument{OutputDir: buildOutputDir, Files: paths})
+			}
+			fmt.Printf("Wrote %v files to %v\n", len(paths), buildOutputDir)
+			return nil
+		}
+
+		if buildOutputDir != "" {
+			if buildFormat == buildFormatHelm {
+				options.ParamPlaceholders = helm.ParamPlaceholders()
+			}
+
+			rm, err := GenerateKustomizeResMap(kustomizeTemplate, options)
+			if err != nil {
+				return buildError(err)
+			}
+
+			if err := saveGeneratedValues(generated); err != nil {
+				return err
+			}
+
+			var paths []string
+			if buildFormat == buildFormatHelm {
+				paths, err = writeHelmChart(rm, config)
+			} else {
+				paths, err = export.Write(filesys.MakeFsOnDisk(), buildOutputDir, rm)
+			}
+			if err != nil {
+				return failure("unable to write to %v: %v", buildOutputDir, err.Error())
+			}
+
+			saveLock(lock)
+			if structuredOutput() {
+				content, err := rm.AsYaml()
+				if err != nil {
+					return failure("%v", err.Error())
+				}
+				resources, err := output.ResourcesFromYaml(content)
+				if err != nil {
+					return failure("%v", err.Error())
+				}
+				return printDocument(&buildDocument{Resources: resources, OutputDir: buildOutputDir, Files: paths})
+			}
+			fmt.Printf("Wrote %v resources to %v\n", len(paths), buildOutputDir)
+			return nil
+		}
+
+		rm, err := GenerateKustomizeResMap(kustomizeTemplate, options)
+		if err != nil {
+			return buildError(err)
+		}
+
+		content, err := rm.AsYaml()
+		if err != nil {
+			return failure("%v", err.Error())
+		}
+		result := string(content)
+
+		// --check compares what would be deployed. The printed yaml is a preview, so only it is redacted,
+		// like the files of --output-dir are not as they are deployed.
+		printed := result
+		if !showSecrets {
+			redactor.AddGenerated(generated.Values()...)
+			redactor.ResMap(rm)
+
+			content, err := rm.AsYaml()
+			if err != nil {
+				return failure("%v", err.Error())
+			}
+			printed = string(content)
+		}
+
+		document := &buildDocument{}
+		if structuredOutput() {
+			document.Resources, err = output.ResourcesFromYaml([]byte(printed))
+			if err != nil {
+				return failure("%v", err.Error())
+			}
+		}
+
+		// Checking does not save generated values, a value missing from the store is a difference
+		if buildCheck != "" {
+			expected, err := ioutil.ReadFile(buildCheck)
+			if err != nil {
+				return failure("unable to read %v: %v", buildCheck, err.Error())
+			}
+
+			document.Check = &buildCheckDocument{File: buildCheck, Matches: true}
+			if line, expectedLine, actualLine, differs := firstDifference(string(expected), result); differs {
+				if !showSecrets {
+					expectedLine, actualLine = redactor.String(expectedLine), redactor.String(actualLine)
+				}
+				if structuredOutput() {
+					document.Check.Matches = false
+					document.Check.Line = line
+					if err := printDocument(document); err != nil {
+						return err
+					}
+				}
+				return failure("the build differs from %v, starting at line %v\n- %v\n+ %v", buildCheck, line, expectedLine, actualLine)
+			}
+
+			if structuredOutput() {
+				return printDocument(document)
+			}
+			fmt.Printf("The build matches %v\n", buildCheck)
+			return nil
+		}
+
+		if err := saveGeneratedValues(generated); err != nil {
+			return err
+		}
+		saveLock(lock)
+		if structuredOutput() {
+			return printDocument(document)
+		}
+		fmt.Printf("%v", printed)
+
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(generateCmd)
 	generateCmd.Flags().BoolVarP(&Dev, "latest", "", false, "Sets conditions to allow development testing.")
+	generateCmd.Flags().BoolVarP(&showSecrets, "show-secrets", "", false, "Prints the data of Secrets and the values of secret params, instead of REDACTED.")
+	generateCmd.Flags().StringVarP(&buildCheck, "check", "", "", "Compares the yaml to this file, instead of printing it, and exits with 1 if they differ. Use it in CI to check a committed build is up to date.")
+	generateCmd.Flags().StringVarP(&buildAPIVersionsFor, "api-versions-for", "", validation.DefaultVersion.String(), "Kubernetes version whose API versions the resources may use. APIs removed in, or added after, this version fail the build. Only the API versions depend on it, fields are always checked against the Kubernetes "+validation.DefaultVersion.String()+" API types built into opctl.")
+	generateCmd.Flags().StringVarP(&buildOutputDir, "output-dir", "", "", "Writes one file per resource, as <namespace>/<kind>-<name>.yaml, and a kustomization.yaml to this directory, instead of printing the yaml.")
+	generateCmd.Flags().StringVarP(&buildFormat, "format", "", buildFormatYaml, "Format of --output-dir, yaml or helm. helm writes a Helm chart with the domain, fqdn, namespace and image tags in values.yaml.")
+	generateCmd.Flags().StringVarP(&buildGitOps, "gitops", "", "", "Writes each deployment phase to its own directory under --output-dir, and the objects to deploy them with argocd or flux.")
+	generateCmd.Flags().StringVarP(&buildGitOpsOptions.RepoURL, "gitops-repo-url", "", "", "Url of the git repository --output-dir is committed to. Required with --gitops.")
+	generateCmd.Flags().StringVarP(&buildGitOpsOptions.Path, "gitops-path", "", "", "Path of --output-dir in the git repository. Defaults to --output-dir.")
+	generateCmd.Flags().StringVarP(&buildGitOpsOptions.Revision, "gitops-revision", "", "main", "Branch of the git repository to deploy.")
+	generateCmd.Flags().StringVarP(&buildGitOpsOptions.Namespace, "gitops-namespace", "", "", "Namespace of the generated objects. Defaults to argocd or flux-system.")
+}
+
+// newRedactor returns a redactor for the values of the secret params of config, the ones in secretParams and spec.secretParams
+func newRedactor(config *opConfig.Config) (*redact.Redactor, error) {
+	params, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	redactor := redact.NewRedactor()
+	for _, key := range append(append([]string{}, secretParams...), config.Spec.SecretParams...) {
+		if value := params.GetValue(key); value != nil {
+			redactor.Add(scalarValues(value)...)
+		}
+	}
+
+	return redactor, nil
+}
+
+// scalarValues returns the values of the scalar nodes under node, including node
+func scalarValues(node *yaml2.Node) []string {
+	if node.Kind == yaml2.ScalarNode {
+		return []string{node.Value}
+	}
+
+	result := make([]string, 0)
+	for i, child := range node.Content {
+		// Keys of mappings are at even indexes
+		if node.Kind == yaml2.MappingNode && i%2 == 0 {
+			continue
+		}
+
+		result = append(result, scalarValues(child)...)
+	}
+
+	return result
+}
+
+// printPolicyReport prints the findings of the policy engine to stderr, if there are any
+func printPolicyReport(engine *policy.Engine) {
+	if report := engine.Report(); report != "" {
+		fmt.Fprintf(os.Stderr, "\n%v", report)
+	}
+}
+
+// saveGeneratedValues persists the values generated by the build, so the next build has the same output.
+// It is called before the output is written or applied, as output with values that are not saved would change on the next build.
+func saveGeneratedValues(generated *opConfig.GeneratedValues) error {
+	if err := generated.Save(); err != nil {
+		return configError("unable to save generated values to %v: %v", opConfig.GeneratedValuesFilePath, err.Error())
+	}
+
+	return nil
+}
+
+// saveLock records the inputs of the build in opctl.lock
+func saveLock(lock *opConfig.Lock) {
+	if err := lock.Save(opConfig.LockFilePath); err != nil {
+		logging.Errorf("Unable to write %v: %v", opConfig.LockFilePath, err.Error())
+	}
+}
+
+// firstDifference returns the first line, starting at 1, where expected and actual differ, along with the lines.
+// differs is false if they are the same.
+func firstDifference(expected, actual string) (line int, expectedLine, actualLine string, differs bool) {
+	if expected == actual {
+		return 0, "", "", false
+	}
+
+	expectedLines := strings.Split(expected, "\n")
+	actualLines := strings.Split(actual, "\n")
+	for i := 0; i < len(expectedLines) || i < len(actualLines); i++ {
+		expectedLine, actualLine = "", ""
+		if i < len(expectedLines) {
+			expectedLine = expectedLines[i]
+		}
+		if i < len(actualLines) {
+			actualLine = actualLines[i]
+		}
+
+		if expectedLine != actualLine || i >= len(expectedLines) || i >= len(actualLines) {
+			return i + 1, expectedLine, actualLine, true
+		}
+	}
+
+	return 0, "", "", false
+}
+
+// writeGitOps exports each deployment phase to its own directory in buildOutputDir,
+// then writes the objects that tell the GitOps tool to deploy them in order.
+// The paths of the written files, relative to buildOutputDir, are returned.
+func writeGitOps(config *opConfig.Config, options *GenerateKustomizeResultOptions) ([]string, error) {
+	gitOpsOptions := buildGitOpsOptions
+	if gitOpsOptions.Path == "" {
+		if filepath.IsAbs(buildOutputDir) {
+			return nil, fmt.Errorf("--gitops-path is required when --output-dir is absolute")
+		}
+		gitOpsOptions.Path = filepath.ToSlash(filepath.Clean(buildOutputDir))
+	}
+
+	// Every phase is built before anything is written, so the generated values are saved first
+	phases := deploymentPhases(config)
+	resMaps := make([]resmap.ResMap, len(phases))
+	for i, phase := range phases {
+		rm, err := GenerateKustomizeResMap(phase.Template, options)
+		if err != nil {
+			return nil, err
+		}
+		resMaps[i] = rm
+	}
+
+	if err := saveGeneratedValues(options.Generated); err != nil {
+		return nil, err
+	}
+
+	fSys := filesys.MakeFsOnDisk()
+	result := make([]string, 0)
+	phaseNames := make([]string, 0)
+	for i, phase := range phases {
+		paths, err := export.Write(fSys, filepath.Join(buildOutputDir, phase.Name), resMaps[i])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			result = append(result, filepath.Join(phase.Name, path))
+		}
+		phaseNames = append(phaseNames, phase.Name)
+	}
+
+	paths, err := gitops.Write(fSys, buildOutputDir, buildGitOps, phaseNames, gitOpsOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(result, paths...), nil
+}
+
+// getManifestsVersion returns the tag of the manifests in .onepanel/cli_config.yaml, or unknown if there is none, like for a directory source
+func getManifestsVersion() string {
+	source, err := manifest.LoadManifestSourceFromFileConfig(filepath.Join(".onepanel", "cli_config.yaml"))
+	if err != nil || source.GetTag() == "" {
+		return "unknown"
+	}
+
+	return source.GetTag()
+}
+
+// writeHelmChart writes rm as a Helm chart to buildOutputDir, with the params of config as the values
+func writeHelmChart(rm resmap.ResMap, config *opConfig.Config) ([]string, error) {
+	params, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	core, err := getCoreImages()
+	if err != nil {
+		return nil, err
+	}
+
+	values := helm.Values{
+		CoreImageTag:   core.CoreTag,
+		CoreUIImageTag: core.CoreUITag,
+	}
+	if node := params.GetValue("application.domain"); node != nil {
+		values.Domain = node.Value
+	}
+	if node := params.GetValue("application.fqdn"); node != nil {
+		values.Fqdn = node.Value
+	}
+	if node := params.GetValue("application.defaultNamespace"); node != nil {
+		values.Namespace = node.Value
+	}
+
+	return helm.Write(filesys.MakeFsOnDisk(), buildOutputDir, rm, helm.ChartOptions{
+		Name:             "onepanel",
+		CLIVersion:       opConfig.CLIVersion,
+		ManifestsVersion: getManifestsVersion(),
+		Values:           values,
+	})
 }
 
+// inMemoryManifestsRoot is where the manifests are copied to in the in-memory file system GenerateKustomizeResult builds with
+const inMemoryManifestsRoot = "/manifests"
+
 // GenerateKustomizeResultOptions is configuration for the GenerateKustomizeResult function
 type GenerateKustomizeResultOptions struct {
 	Database *opConfig.Database
 	Config   *opConfig.Config
+	// Generated has the random values of previous builds, like passwords. If nil, new values are generated.
+	Generated *opConfig.GeneratedValues
+	// Lock records the inputs of the build. If set, each resource is annotated with them.
+	Lock *opConfig.Lock
+	// KubernetesVersion if set, the resources are validated against the API versions of this Kubernetes version,
+	// and the schemas of validation.DefaultVersion
+	KubernetesVersion *validation.Version
+	// Policy if set, checks the resources, failing the build on violations of rules with the error severity
+	Policy *policy.Engine
+	// ParamPlaceholders if set, replace the params they are keyed by, like helm.ParamPlaceholders, for the build only
+	ParamPlaceholders map[string]string
 }
 
 // generateDatabaseConfiguration checks to see if database configuration is already present
 // if not, it'll randomly generate some.
-func generateDatabaseConfiguration(yaml *util.DynamicYaml, database *opConfig.Database) error {
+func generateDatabaseConfiguration(yaml *util.DynamicYaml, database *opConfig.Database, manifestPath string, generated *opConfig.GeneratedValues) error {
 	if yaml.HasKey("database") {
 		return nil
 	}
 
 	if database == nil {
-		dbPath := filepath.Join(".onepanel", "manifests", "cache", "common", "onepanel", "base", "vars.yaml")
+		dbPath := filepath.Join(manifestPath, "common", "onepanel", "base", "vars.yaml")
 		data, err := ioutil.ReadFile(dbPath)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		wrapper := &opConfig.DatabaseWrapper{}
 		if err := yaml2.Unmarshal(data, wrapper); err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("unable to read %v: %v", dbPath, err.Error())
 		}
 
 		database = wrapper.Database
 
-		pass, err := password.Generate(16, 6, 0, false, false)
+		pass, err := generated.Get("database.password", func() (string, error) {
+			return password.Generate(16, 6, 0, false, false)
+		})
 		if err != nil {
 			return err
 		}
 		database.Password.Value = pass
 
-		username, err := password.Generate(8, 6, 0, false, false)
+		username, err := generated.Get("database.username", func() (string, error) {
+			username, err := password.Generate(8, 6, 0, false, false)
+			return "onepanel" + username, err
+		})
 		if err != nil {
 			return err
 		}
-		database.Username.Value = "onepanel" + username
+		database.Username.Value = username
 	}
 
 	yaml.Put("database.host", database.Host.Value)
@@ -134,8 +633,8 @@ func generateDatabaseConfiguration(yaml *util.DynamicYaml, database *opConfig.Da
 
 // GetDatabaseConfigurationFromCluster attempts to load the database configuration from a deployed cluster
 // If there is no configuration (not found) no error is returned
-func GetDatabaseConfigurationFromCluster(c *kubernetes.Clientset) (database *opConfig.Database, err error) {
-	secret, err := c.CoreV1().Secrets("onepanel").Get(context.Background(), "onepanel", v1.GetOptions{})
+func GetDatabaseConfigurationFromCluster(ctx context.Context, c *kubernetes.Clientset) (database *opConfig.Database, err error) {
+	secret, err := c.CoreV1().Secrets("onepanel").Get(ctx, "onepanel", v1.GetOptions{})
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			return nil, nil
@@ -146,7 +645,7 @@ func GetDatabaseConfigurationFromCluster(c *kubernetes.Clientset) (database *opC
 	databaseUsername := string(secret.Data["databaseUsername"])
 	databasePassword := string(secret.Data["databasePassword"])
 
-	configMap, err := c.CoreV1().ConfigMaps("onepanel").Get(context.Background(), "onepanel", v1.GetOptions{})
+	configMap, err := c.CoreV1().ConfigMaps("onepanel").Get(ctx, "onepanel", v1.GetOptions{})
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			return nil, nil
@@ -171,69 +670,111 @@ func GetDatabaseConfigurationFromCluster(c *kubernetes.Clientset) (database *opC
 	return
 }
 
-// GenerateKustomizeResult Given the path to the manifests, and a kustomize config, creates the final kustomization file.
-// It does this by copying the manifests into a temporary directory, inserting the kustomize template
-// and running the kustomize command
-func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *GenerateKustomizeResultOptions) (string, error) {
-	config := *options.Config
+// coreImages are the tags and pull policies of the onepanel core and core-ui images
+type coreImages struct {
+	CoreTag          string
+	CorePullPolicy   string
+	CoreUITag        string
+	CoreUIPullPolicy string
+}
 
-	coreImageTag := opConfig.CoreImageTag
-	coreImagePullPolicy := "IfNotPresent"
-	coreUIImageTag := opConfig.CoreUIImageTag
-	coreUIImagePullPolicy := "IfNotPresent"
+// getCoreImages returns the core images the CLI was built for, or the latest images in dev mode
+func getCoreImages() (*coreImages, error) {
 	if Dev {
-		coreImageTag = "latest"
-		coreImagePullPolicy = "Always"
-		coreUIImageTag = "latest"
-		coreUIImagePullPolicy = "Always"
-	} else if coreImageTag == "" {
-		return "", fmt.Errorf("no version set. If you are running in dev mode, add the --latest flag")
+		return &coreImages{
+			CoreTag:          "latest",
+			CorePullPolicy:   "Always",
+			CoreUITag:        "latest",
+			CoreUIPullPolicy: "Always",
+		}, nil
 	}
 
-	yamlFile, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
+	if opConfig.CoreImageTag == "" {
+		return nil, fmt.Errorf("no version set. If you are running in dev mode, add the --latest flag")
+	}
+
+	return &coreImages{
+		CoreTag:          opConfig.CoreImageTag,
+		CorePullPolicy:   "IfNotPresent",
+		CoreUITag:        opConfig.CoreUIImageTag,
+		CoreUIPullPolicy: "IfNotPresent",
+	}, nil
+}
+
+// GenerateKustomizeResult Given the path to the manifests, and a kustomize config, creates the final kustomization file.
+// The result is the yaml of every resource, see GenerateKustomizeResMap.
+func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *GenerateKustomizeResultOptions) (string, error) {
+	rm, err := GenerateKustomizeResMap(kustomizeTemplate, options)
 	if err != nil {
 		return "", err
 	}
 
-	if err := manifest.Validate(yamlFile); err != nil {
+	kustYaml, err := rm.AsYaml()
+	if err != nil {
 		return "", err
 	}
 
-	manifestPath := config.Spec.ManifestsRepo
-	localManifestsCopyPath := filepath.Join(".onepanel", "manifests", "cache")
+	return string(kustYaml), nil
+}
 
-	// Delete the local files if they exist
-	if err := os.RemoveAll(localManifestsCopyPath); err != nil {
-		return "", err
+// GenerateKustomizeResMap Given the path to the manifests, and a kustomize config, builds the resources.
+// It does this by copying the manifests into an in-memory file system, inserting the kustomize template
+// and running kustomize
+func GenerateKustomizeResMap(kustomizeTemplate template.Kustomize, options *GenerateKustomizeResultOptions) (resmap.ResMap, error) {
+	config := *options.Config
+
+	core, err := getCoreImages()
+	if err != nil {
+		return nil, err
 	}
 
-	if err := files.CopyDir(manifestPath, localManifestsCopyPath); err != nil {
-		return "", err
+	generated := options.Generated
+	if generated == nil {
+		generated = opConfig.NewGeneratedValues()
 	}
 
-	localKustomizePath := filepath.Join(localManifestsCopyPath, "kustomization.yaml")
-	// Create will truncate the file if it exists
-	newFile, err := os.Create(localKustomizePath)
+	yamlFile, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if err := manifest.Validate(yamlFile); err != nil {
+		return nil, err
+	}
+
+	for key, placeholder := range options.ParamPlaceholders {
+		if yamlFile.HasKey(key) {
+			yamlFile.Put(key, placeholder)
+		}
+	}
+
+	// The manifests are built in memory, so the originals are never changed, and builds can run at the same time.
+	manifestPath := config.Spec.ManifestsRepo
+	fSys := filesys.MakeFsInMemory()
+	if err := files.CopyDirToFileSystem(manifestPath, fSys, inMemoryManifestsRoot); err != nil {
+		return nil, err
+	}
+
+	// User patches and extra components live outside of the manifests, kustomize needs them under the kustomization root.
+	if err := kustomizeTemplate.Localize(fSys, inMemoryManifestsRoot); err != nil {
+		return nil, err
 	}
 
 	kustomizeYaml, err := yaml.Marshal(kustomizeTemplate)
 	if err != nil {
-		log.Printf("Error yaml. Error %v", err.Error())
-		return "", err
+		logging.Errorf("Error yaml. Error %v", err.Error())
+		return nil, err
 	}
 
-	_, err = newFile.Write(kustomizeYaml)
-	if err != nil {
-		return "", err
+	if err := fSys.WriteFile(filepath.Join(inMemoryManifestsRoot, "kustomization.yaml"), kustomizeYaml); err != nil {
+		return nil, err
 	}
 
 	domain := yamlFile.GetValue("application.domain").Value
 	fqdn := yamlFile.GetValue("application.fqdn").Value
 	cloudSettings, err := util.LoadDynamicYamlFromFile(filepath.Join(config.Spec.ManifestsRepo, "vars", "onepanel-config-map-hidden.env"))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	defaultNamespace := yamlFile.GetValue("application.defaultNamespace").Value
@@ -266,12 +807,14 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 	yamlFile.PutWithSeparator("providerType", "cloud", ".")
 	yamlFile.PutWithSeparator("onepanelApiUrl", apiPath, ".")
 
-	yamlFile.PutWithSeparator("applicationCoreImageTag", coreImageTag, ".")
-	yamlFile.PutWithSeparator("applicationCoreImagePullPolicy", coreImagePullPolicy, ".")
+	yamlFile.PutWithSeparator("applicationCoreImageTag", core.CoreTag, ".")
+	yamlFile.PutWithSeparator("applicationCoreImagePullPolicy", core.CorePullPolicy, ".")
 
-	yamlFile.PutWithSeparator("applicationCoreuiImageTag", coreUIImageTag, ".")
-	yamlFile.PutWithSeparator("applicationCoreuiImagePullPolicy", coreUIImagePullPolicy, ".")
+	yamlFile.PutWithSeparator("applicationCoreuiImageTag", core.CoreUITag, ".")
+	yamlFile.PutWithSeparator("applicationCoreuiImagePullPolicy", core.CoreUIPullPolicy, ".")
 
+	// application.nodePool.options and metalLb.addresses are lists, manifests use them with $json.
+	// The pre-indented yaml of $(applicationNodePoolOptions) and $(metalLbAddresses) is deprecated, kept for released manifests.
 	applicationNodePoolOptionsConfigMapStr := generateApplicationNodePoolOptions(yamlFile.GetValue("application.nodePool"))
 	yamlFile.PutWithSeparator("applicationNodePoolOptions", applicationNodePoolOptionsConfigMapStr, ".")
 
@@ -280,18 +823,21 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 		metalLbAddressesConfigMapStr := generateMetalLbAddresses(yamlFile.GetValue("metalLb.addresses").Content)
 		yamlFile.PutWithSeparator("metalLbAddresses", metalLbAddressesConfigMapStr, ".")
 
-		metalLbSecretKey, err := bcrypt.GenerateFromPassword([]byte(rand.String(128)), bcrypt.DefaultCost)
+		metalLbSecretKey, err := generated.Get("metalLbSecretKey", func() (string, error) {
+			key, err := bcrypt.GenerateFromPassword([]byte(rand.String(128)), bcrypt.DefaultCost)
+			return base64.StdEncoding.EncodeToString(key), err
+		})
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		yamlFile.PutWithSeparator("metalLbSecretKey", base64.StdEncoding.EncodeToString(metalLbSecretKey), ".")
+		yamlFile.PutWithSeparator("metalLbSecretKey", metalLbSecretKey, ".")
 	}
 
 	_, artifactRepositoryNode := yamlFile.Get("artifactRepository")
 	artifactRepositoryConfig := storage.ArtifactRepositoryProvider{}
 	err = artifactRepositoryNode.Decode(&artifactRepositoryConfig)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if artifactRepositoryConfig.S3 != nil {
 		artifactRepositoryConfig.S3.Source = "s3"
@@ -303,15 +849,17 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 
 		yamlStr, err := artifactRepositoryConfig.S3.MarshalToYaml()
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		yamlFile.Put("artifactRepositoryProvider", yamlStr)
 		yamlFile.Put("artifactRepository.s3.region", artifactRepositoryConfig.S3.Region)
 	} else if artifactRepositoryConfig.GCS != nil {
 		accessKey := artifactRepositoryConfig.GCS.Bucket
-		randomSecret, err := util.RandASCIIString(16)
+		randomSecret, err := generated.Get("artifactRepository.gcs.secretKey", func() (string, error) {
+			return util.RandASCIIString(16)
+		})
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		artifactRepositoryConfig.S3 = &storage.ArtifactRepositoryS3Provider{
@@ -333,7 +881,7 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 		}
 		yamlStr, err := artifactRepositoryConfig.S3.MarshalToYaml()
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		yamlFile.Put("artifactRepositoryProvider", yamlStr)
@@ -365,7 +913,7 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 		}
 		yamlStr, err := artifactRepositoryConfig.S3.MarshalToYaml()
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		yamlFile.Put("artifactRepositoryProvider", yamlStr)
@@ -377,39 +925,42 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 		yamlFile.Put("artifactRepository.s3.publicEndpoint", artifactRepositoryConfig.S3.PublicEndpoint)
 		yamlFile.Put("artifactRepository.s3.insecure", "true")
 	} else {
-		return "", errors.New("unsupported artifactRepository configuration")
+		return nil, errors.New("unsupported artifactRepository configuration")
 	}
 
 	// Check if workflowEngineContainerRuntimeExecutor is in the vars.
 	// If it is, leave it. If it is not, load it from the manifests and use the default
 	if !yamlFile.HasKey("workflowEngine.containerRuntimeExecutor") {
-		argoVarsYaml, err := util.LoadDynamicYamlFromFile(filepath.Join(".onepanel", "manifests", "cache", "common", "argo", "base", "vars.yaml"))
+		argoVarsYaml, err := util.LoadDynamicYamlFromFile(filepath.Join(manifestPath, "common", "argo", "base", "vars.yaml"))
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		_, valueNode := argoVarsYaml.Get("workflowEngine.containerRuntimeExecutor.default")
 		if valueNode == nil {
-			return "", fmt.Errorf("workflowEngine.containerRuntimeExecutor.default does not exist in manifests")
+			return nil, fmt.Errorf("workflowEngine.containerRuntimeExecutor.default does not exist in manifests")
 		}
 
 		yamlFile.Put("workflowEngineContainerRuntimeExecutor", valueNode.Value)
 	}
 
-	if err := generateDatabaseConfiguration(yamlFile, options.Database); err != nil {
-		return "", err
+	if err := generateDatabaseConfiguration(yamlFile, options.Database, manifestPath, generated); err != nil {
+		return nil, err
 	}
 
-	flatMap := yamlFile.FlattenToKeyValue(util.LowerCamelCaseFlatMapKeyFormatter)
-	if err := mapLinkedVars(flatMap, localManifestsCopyPath, &config, true); err != nil {
-		return "", err
+	flatMap, err := yamlFile.FlattenToKeyValue(util.LowerCamelCaseFlatMapKeyFormatter)
+	if err != nil {
+		return nil, paramsError("%v", err.Error())
+	}
+	if err := mapLinkedVars(flatMap, manifestPath, &config, true); err != nil {
+		return nil, err
 	}
 
 	//Read workflow-config-map-hidden for the rest of the values
-	workflowEnvHiddenPath := filepath.Join(localManifestsCopyPath, "vars", "workflow-config-map-hidden.env")
+	workflowEnvHiddenPath := filepath.Join(manifestPath, "vars", "workflow-config-map-hidden.env")
 	workflowEnvCont, workflowEnvFileErr := ioutil.ReadFile(workflowEnvHiddenPath)
 	if workflowEnvFileErr != nil {
-		return "", workflowEnvFileErr
+		return nil, workflowEnvFileErr
 	}
 	workflowEnvContStr := string(workflowEnvCont)
 	//Add these keys and values
@@ -435,17 +986,17 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 	if artifactRepositoryConfig.S3 != nil {
 		artifactRepositoryS3AccessKeySecretName, ok := flatMap["artifactRepositoryS3AccessKeySecretName"].(string)
 		if !ok {
-			return "", fmt.Errorf("missing 'artifactRepositoryS3AccessKeySecretName'")
+			return nil, fmt.Errorf("missing 'artifactRepositoryS3AccessKeySecretName'")
 		}
 		artifactRepositoryS3SecretKeySecretName, ok := flatMap["artifactRepositoryS3SecretKeySecretName"].(string)
 		if !ok {
-			return "", fmt.Errorf("missing 'artifactRepositoryS3SecretKeySecretName'")
+			return nil, fmt.Errorf("missing 'artifactRepositoryS3SecretKeySecretName'")
 		}
 		artifactRepositoryConfig.S3.AccessKeySecret.Name = artifactRepositoryS3AccessKeySecretName
 		artifactRepositoryConfig.S3.SecretKeySecret.Name = artifactRepositoryS3SecretKeySecretName
 		yamlStr, err := artifactRepositoryConfig.S3.MarshalToYaml()
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		flatMap["artifactRepositoryProvider"] = yamlStr
 	}
@@ -456,78 +1007,55 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 	if artifactRepositoryConfig.ABS != nil {
 		missingKeys := yamlFile.FindMissingKeys("artifactRepository.s3.bucket", "artifactRepository.s3.endpoint", "artifactRepository.s3.insecure")
 		if len(missingKeys) == 0 {
-			paramsPath := filepath.Join(localManifestsCopyPath, "vars", "workflow-config-map.env")
-			//Clear previous env file - create truncates if it exists
-			paramsFile, err := os.Create(paramsPath)
-			if err != nil {
-				return "", err
-			}
-			var stringToWrite = fmt.Sprintf("%v=%v\n%v=%v\n%v=%v\n%v=%v\n",
+			var stringToWrite = fmt.Sprintf("%v=%v\n%v=%v\n%v=%v\n",
 				"artifactRepositoryBucket", flatMap["artifactRepositoryS3Bucket"],
 				"artifactRepositoryEndpoint", flatMap["artifactRepositoryS3Endpoint"],
 				"artifactRepositoryInsecure", flatMap["artifactRepositoryS3Insecure"],
 			)
-			_, err = paramsFile.WriteString(stringToWrite)
-			if err != nil {
-				return "", err
+			paramsPath := filepath.Join(inMemoryManifestsRoot, "vars", "workflow-config-map.env")
+			if err := fSys.WriteFile(paramsPath, []byte(stringToWrite)); err != nil {
+				return nil, err
 			}
 		} else {
 			missingKeysMessage := strings.Join(missingKeys, ", ")
-			log.Fatalf("missing required values in params.yaml: %v", missingKeysMessage)
+			return nil, paramsError("missing required values in params.yaml: %v", missingKeysMessage)
 		}
 	} else if artifactRepositoryConfig.S3 != nil && artifactRepositoryConfig.GCS == nil {
 		missingKeys := yamlFile.FindMissingKeys("artifactRepository.s3.bucket", "artifactRepository.s3.endpoint", "artifactRepository.s3.insecure", "artifactRepository.s3.region")
 		if len(missingKeys) == 0 {
-			paramsPath := filepath.Join(localManifestsCopyPath, "vars", "workflow-config-map.env")
-			//Clear previous env file - create truncates if it exists
-			paramsFile, err := os.Create(paramsPath)
-			if err != nil {
-				return "", err
-			}
 			var stringToWrite = fmt.Sprintf("%v=%v\n%v=%v\n%v=%v\n%v=%v\n",
 				"artifactRepositoryBucket", flatMap["artifactRepositoryS3Bucket"],
 				"artifactRepositoryEndpoint", flatMap["artifactRepositoryS3Endpoint"],
 				"artifactRepositoryInsecure", flatMap["artifactRepositoryS3Insecure"],
 				"artifactRepositoryRegion", flatMap["artifactRepositoryS3Region"],
 			)
-			_, err = paramsFile.WriteString(stringToWrite)
-			if err != nil {
-				return "", err
+			paramsPath := filepath.Join(inMemoryManifestsRoot, "vars", "workflow-config-map.env")
+			if err := fSys.WriteFile(paramsPath, []byte(stringToWrite)); err != nil {
+				return nil, err
 			}
 		} else {
 			missingKeysMessage := strings.Join(missingKeys, ", ")
-			log.Fatalf("missing required values in params.yaml: %v", missingKeysMessage)
+			return nil, paramsError("missing required values in params.yaml: %v", missingKeysMessage)
 		}
 	}
 	//logging-config-map.env, optional component
 	if yamlFile.HasKeys("logging.image", "logging.volumeStorage") {
-		paramsPath := filepath.Join(localManifestsCopyPath, "vars", "logging-config-map.env")
-		//Clear previous env file
-		paramsFile, err := os.Create(paramsPath)
-		if err != nil {
-			return "", err
-		}
 		var stringToWrite = fmt.Sprintf("%v=%v\n%v=%v\n",
 			"loggingImage", flatMap["loggingImage"],
 			"loggingVolumeStorage", flatMap["loggingVolumeStorage"],
 		)
-		_, err = paramsFile.WriteString(stringToWrite)
-		if err != nil {
-			return "", err
+		paramsPath := filepath.Join(inMemoryManifestsRoot, "vars", "logging-config-map.env")
+		if err := fSys.WriteFile(paramsPath, []byte(stringToWrite)); err != nil {
+			return nil, err
 		}
 	}
 	//onepanel-config-map.env
-	//Clear previous env file
-	paramsPath := filepath.Join(localManifestsCopyPath, "vars", "onepanel-config-map.env")
-	paramsFile, err := os.Create(paramsPath)
-	if err != nil {
-		return "", err
-	}
 	var stringToWrite = fmt.Sprintf("%v=%v\n",
 		"applicationDefaultNamespace", flatMap["applicationDefaultNamespace"],
 	)
-	if _, err := paramsFile.WriteString(stringToWrite); err != nil {
-		return "", err
+	paramsPath := filepath.Join(inMemoryManifestsRoot, "vars", "onepanel-config-map.env")
+	if err := fSys.WriteFile(paramsPath, []byte(stringToWrite)); err != nil {
+		return nil, err
 	}
 
 	//Write to secret files
@@ -543,59 +1071,85 @@ func GenerateKustomizeResult(kustomizeTemplate template.Kustomize, options *Gene
 					"\n  artifactRepositoryS3SecretKey: %v",
 				flatMap["artifactRepositoryS3AccessKey"], flatMap["artifactRepositoryS3SecretKey"])
 
-			err = replacePlaceholderForSecretManiFile(localManifestsCopyPath, artifactRepoSecretPlaceholder, artifactRepoS3Secret)
+			err = replacePlaceholderForSecretManiFile(fSys, inMemoryManifestsRoot, artifactRepoSecretPlaceholder, artifactRepoS3Secret)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 		} else {
 			missingKeysMessage := strings.Join(missingKeys, ", ")
-			log.Fatalf("Missing required values in params.yaml: %v", missingKeysMessage)
+			return nil, paramsError("missing required values in params.yaml: %v", missingKeysMessage)
 		}
 	}
 
-	//To properly replace $(applicationDefaultNamespace), we need to update it in quite a few files.
-	//Find those files
-	listOfFiles, errorWalking := FilePathWalkDir(localManifestsCopyPath)
-	if errorWalking != nil {
-		return "", err
+	// Substitute the params in the manifests. Only the components, patches and env files used by the kustomization
+	// must have every variable set, the rest of the manifests may be for components that aren't used.
+	renderer := template.NewRenderer(flatMap)
+	renderer.Structured, err = yamlFile.FlattenToStructured(util.LowerCamelCaseFlatMapKeyFormatter)
+	if err != nil {
+		return nil, err
 	}
-
-	if err := replaceVariables(flatMap, listOfFiles); err != nil {
-		return "", err
+	for _, kustomizeVar := range kustomizeTemplate.Vars {
+		renderer.Passthrough[kustomizeVar.Name] = true
+	}
+	if err := renderer.RenderDirectory(fSys, inMemoryManifestsRoot, kustomizeTemplate.SourcePaths()); err != nil {
+		return nil, err
 	}
 
-	//Update the values in those files
-	rm, err := runKustomizeBuild(localManifestsCopyPath)
+	rm, err := runKustomizeBuild(fSys, inMemoryManifestsRoot)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	kustYaml, err := rm.AsYaml()
 
-	return string(kustYaml), nil
+	if options.KubernetesVersion != nil {
+		if err := validation.Validate(rm, *options.KubernetesVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	if registry := config.Spec.ImageRegistry; registry != nil {
+		images.NewRewriter(registry.URL, registry.Overrides).RewriteResMap(rm)
+		images.AddPullSecrets(rm, registry.PullSecrets)
+	}
+
+	if options.Policy != nil {
+		if err := options.Policy.Check(rm); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Lock != nil {
+		if err := stampProvenance(rm, options.Lock); err != nil {
+			return nil, err
+		}
+	}
+
+	return rm, nil
 }
 
-func replacePlaceholderForSecretManiFile(localManifestsCopyPath string, artifactRepoSecretPlaceholder string, artifactRepoSecretVal string) error {
+func replacePlaceholderForSecretManiFile(fSys filesys.FileSystem, manifestsRoot string, artifactRepoSecretPlaceholder string, artifactRepoSecretVal string) error {
 	//Path to secrets file
-	secretsPath := filepath.Join(localManifestsCopyPath, "common", "onepanel", "base", "secret-onepanel-defaultnamespace.yaml")
+	secretsPath := filepath.Join(manifestsRoot, "common", "onepanel", "base", "secret-onepanel-defaultnamespace.yaml")
 	//Read the file, replace the specific value, write the file back
-	secretFileContent, secretFileOpenErr := ioutil.ReadFile(secretsPath)
+	secretFileContent, secretFileOpenErr := fSys.ReadFile(secretsPath)
 	if secretFileOpenErr != nil {
 		return secretFileOpenErr
 	}
 	secretFileContentStr := string(secretFileContent)
 	if strings.Contains(secretFileContentStr, artifactRepoSecretPlaceholder) {
 		secretFileContentStr = strings.Replace(secretFileContentStr, artifactRepoSecretPlaceholder, artifactRepoSecretVal, 1)
-		writeFileErr := ioutil.WriteFile(secretsPath, []byte(secretFileContentStr), 0644)
+		writeFileErr := fSys.WriteFile(secretsPath, []byte(secretFileContentStr))
 		if writeFileErr != nil {
 			return writeFileErr
 		}
 	} else {
-		fmt.Printf("Key: %v not present in %v, not used.\n", artifactRepoSecretPlaceholder, secretsPath)
+		logging.Warningf("Key: %v not present in %v, not used.", artifactRepoSecretPlaceholder, secretsPath)
 	}
 	return nil
 }
 
-func TemplateFromSimpleOverlayedComponents(comps []*opConfig.SimpleOverlayedComponent) template.Kustomize {
+// TemplateFromSimpleOverlayedComponents creates the kustomization for the components.
+// If config is not nil, its extra components, patches, images, labels, annotations and namespace are passed through to the kustomization.
+func TemplateFromSimpleOverlayedComponents(comps []*opConfig.SimpleOverlayedComponent, config *opConfig.Config) template.Kustomize {
 	k := template.Kustomize{
 		ApiVersion:     "kustomize.config.k8s.io/v1beta1",
 		Kind:           "Kustomization",
@@ -609,6 +1163,19 @@ func TemplateFromSimpleOverlayedComponents(comps []*opConfig.SimpleOverlayedComp
 		}
 	}
 
+	if config == nil {
+		return k
+	}
+
+	k.Resources = append(k.Resources, config.Spec.ExtraComponents...)
+
+	k.PatchesStrategicMerge = append(k.PatchesStrategicMerge, config.Spec.PatchesStrategicMerge...)
+	k.PatchesJson6902 = append(k.PatchesJson6902, config.Spec.PatchesJson6902...)
+	k.Images = config.Spec.Images
+	k.CommonLabels = config.Spec.CommonLabels
+	k.CommonAnnotations = config.Spec.CommonAnnotations
+	k.Namespace = config.Spec.Namespace
+
 	return k
 }
 
@@ -634,8 +1201,8 @@ func formatURLForUI(url string) string {
 	return result
 }
 
-func runKustomizeBuild(path string) (rm resmap.ResMap, err error) {
-	fSys := filesys.MakeFsOnDisk()
+// runKustomizeBuild runs kustomize on the kustomization at path in fSys
+func runKustomizeBuild(fSys filesys.FileSystem, path string) (rm resmap.ResMap, err error) {
 	opts := krusty.MakeDefaultOptions()
 
 	k := krusty.MakeKustomizer(opts)
@@ -648,29 +1215,55 @@ func runKustomizeBuild(path string) (rm resmap.ResMap, err error) {
 	return rm, nil
 }
 
+// generateApplicationNodePoolOptions builds the indented yaml for $(applicationNodePoolOptions).
+// Deprecated: manifests should use $json(applicationNodePoolOptions) instead. It is kept for older manifests.
 func generateApplicationNodePoolOptions(nodePoolData *yaml2.Node) string {
 	nodePool := struct {
 		Options []map[string]interface{}
 	}{}
 	if err := nodePoolData.Decode(&nodePool); err != nil {
-		log.Println(err)
+		logging.Errorf("%v", err)
 		return ""
 	}
 
-	nodePoolOptions, err := yaml2.Marshal(nodePool.Options)
-	if err != nil {
-		log.Println(err)
-		return ""
+	// Each option is encoded on its own, then made an item of the list. Encoding the list as a whole nests the mappings
+	// of an item by 2 spaces instead of 4 with some versions of yaml.v3, and released manifests expect 4.
+	var buffer strings.Builder
+	if len(nodePool.Options) == 0 {
+		buffer.WriteString("[]\n")
+	}
+	for _, option := range nodePool.Options {
+		optionYaml := &bytes.Buffer{}
+		encoder := yaml2.NewEncoder(optionYaml)
+		encoder.SetIndent(4)
+		if err := encoder.Encode(option); err != nil {
+			logging.Errorf("%v", err)
+			return ""
+		}
+		if err := encoder.Close(); err != nil {
+			logging.Errorf("%v", err)
+			return ""
+		}
+
+		for i, line := range strings.Split(strings.TrimSuffix(optionYaml.String(), "\n"), "\n") {
+			if i == 0 {
+				buffer.WriteString("- " + line + "\n")
+			} else {
+				buffer.WriteString("  " + line + "\n")
+			}
+		}
 	}
 
 	nodePoolOptionsStr := "|\n"
-	for _, line := range strings.Split(string(nodePoolOptions), "\n") {
+	for _, line := range strings.Split(buffer.String(), "\n") {
 		nodePoolOptionsStr += fmt.Sprintf("    %v\n", line)
 	}
 
 	return nodePoolOptionsStr
 }
 
+// generateMetalLbAddresses builds the indented yaml for $(metalLbAddresses).
+// Deprecated: manifests should use $json(metalLbAddresses) instead. It is kept for older manifests.
 func generateMetalLbAddresses(nodePoolData []*yaml2.Node) string {
 	applicationNodePoolOptions := []string{""}
 	var appendStr string
@@ -738,6 +1331,15 @@ func mapLinkedVars(mapping map[string]interface{}, manifestPath string, config *
 
 // HumanizeKustomizeError takes errors returned from GenerateKustomizeResult and returns them in a human friendly string
 func HumanizeKustomizeError(err error) string {
+	if validationError, ok := err.(*validation.Error); ok {
+		return validationError.Error()
+	}
+
+	// The violations are in the policy report
+	if policyError, ok := err.(*policy.Error); ok {
+		return fmt.Sprintf("Refusing to continue, %v policy violations have the %v severity", len(policyError.Findings), opConfig.PolicySeverityError)
+	}
+
 	if paramsError, ok := err.(*manifest.ParamsError); ok {
 		switch paramsError.ErrorType {
 		case "missing":
@@ -753,66 +1355,3 @@ func HumanizeKustomizeError(err error) string {
 
 	return fmt.Sprintf("Error generating result: %v", err.Error())
 }
-
-// replaceVariable will go through the variables in flatMap and replace any instances of it in fileContent
-// the resulting modified content is returned
-func replaceVariable(flatMap map[string]interface{}, fileContent []byte) []byte {
-	manifestFileContentStr := string(fileContent)
-	useStr := ""
-	rawStr := ""
-
-	for key := range flatMap {
-		valueBool, okBool := flatMap[key].(bool)
-		if okBool {
-			useStr = strconv.FormatBool(valueBool)
-			rawStr = strconv.FormatBool(valueBool)
-		} else {
-			valueInt, okInt := flatMap[key].(int)
-			if okInt {
-				useStr = "\"" + strconv.FormatInt(int64(valueInt), 10) + "\""
-				rawStr = strconv.FormatInt(int64(valueInt), 10)
-			} else {
-				valueStr, ok := flatMap[key].(string)
-				if !ok {
-					log.Fatal("Unrecognized value in flatmap. Check type assertions.")
-				}
-				useStr = valueStr
-				rawStr = valueStr
-			}
-		}
-		oldString := "$(" + key + ")"
-		if strings.Contains(manifestFileContentStr, key) {
-			manifestFileContentStr = strings.Replace(manifestFileContentStr, oldString, useStr, -1)
-		}
-		oldRawString := "$raw(" + key + ")"
-		if strings.Contains(manifestFileContentStr, key) {
-			manifestFileContentStr = strings.Replace(manifestFileContentStr, oldRawString, rawStr, -1)
-		}
-
-		oldBase64String := "$base64(" + key + ")"
-		if strings.Contains(manifestFileContentStr, key) {
-			base64Value := base64.StdEncoding.EncodeToString([]byte(rawStr))
-			manifestFileContentStr = strings.Replace(manifestFileContentStr, oldBase64String, base64Value, -1)
-		}
-	}
-
-	return []byte(manifestFileContentStr)
-}
-
-// replaceVariables will go through the variables in flatMap and replace any instances of it in any of the files in filePaths
-// the file content is replaced, no backups are made.
-func replaceVariables(flatMap map[string]interface{}, filePaths []string) error {
-	for _, filePath := range filePaths {
-		manifestFileContent, manifestFileOpenErr := ioutil.ReadFile(filePath)
-		if manifestFileOpenErr != nil {
-			return manifestFileOpenErr
-		}
-
-		manifestFileContentStr := replaceVariable(flatMap, manifestFileContent)
-		if err := ioutil.WriteFile(filePath, manifestFileContentStr, 0644); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
@@ -1,8 +1,19 @@
 package cmd
 
 import (
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/files"
 	"github.com/onepanelio/cli/util"
 	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -47,3 +58,276 @@ func Test_generateApplicationNodePoolOptions(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, nodePoolOptionsExpected, nodePoolOptionsActual)
 }
+
+func Test_firstDifference(t *testing.T) {
+	_, _, _, differs := firstDifference("a\nb\n", "a\nb\n")
+	assert.False(t, differs)
+
+	line, expected, actual, differs := firstDifference("a\nb\nc\n", "a\nd\nc\n")
+	assert.True(t, differs)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, "b", expected)
+	assert.Equal(t, "d", actual)
+
+	line, _, actual, differs = firstDifference("a\n", "a\nb\n")
+	assert.True(t, differs)
+	assert.Equal(t, 2, line)
+	assert.Equal(t, "b", actual)
+}
+
+// paramKeys returns the params keys, in lowerCamelCase, that build.go passes as literals to the methods of the params
+func paramKeys(t *testing.T, methods ...string) map[string]bool {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "build.go", nil, 0)
+	assert.Nil(t, err)
+
+	isMethod := make(map[string]bool)
+	for _, method := range methods {
+		isMethod[method] = true
+	}
+
+	keys := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+
+		selector, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !isMethod[selector.Sel.Name] {
+			return true
+		}
+
+		receiver, ok := selector.X.(*ast.Ident)
+		if !ok || (receiver.Name != "yamlFile" && receiver.Name != "yaml") {
+			return true
+		}
+
+		for _, arg := range call.Args {
+			literal, ok := arg.(*ast.BasicLit)
+			if !ok || literal.Kind != token.STRING {
+				continue
+			}
+			key, err := strconv.Unquote(literal.Value)
+			assert.Nil(t, err)
+			keys[util.LowerCamelCaseStringFormat(key, ".")] = true
+			if selector.Sel.Name == "Put" || selector.Sel.Name == "PutWithSeparator" {
+				break
+			}
+		}
+
+		return true
+	})
+
+	return keys
+}
+
+func Test_buildInjectedVars(t *testing.T) {
+	put := paramKeys(t, "Put", "PutWithSeparator")
+
+	// artifactRepositoryProviderSecret is substituted in the secret manifest directly, not put in the params
+	expected := map[string]bool{"artifactRepositoryProviderSecret": true}
+	for key := range put {
+		expected[key] = true
+	}
+
+	injected := make(map[string]bool)
+	for _, name := range buildInjectedVars {
+		injected[name] = true
+	}
+
+	assert.Equal(t, expected, injected)
+}
+
+func Test_buildConsumedVars(t *testing.T) {
+	read := paramKeys(t, "GetValue", "HasKey", "HasKeys", "FindMissingKeys")
+
+	known := make(map[string]bool)
+	for _, name := range append(append([]string{}, buildConsumedVars...), buildInjectedVars...) {
+		known[name] = true
+	}
+
+	for key := range read {
+		// Keys of mappings, like database, are read to check the variables under them
+		isMapping := false
+		for name := range known {
+			if strings.HasPrefix(name, key) && name != key {
+				isMapping = true
+			}
+		}
+
+		assert.True(t, known[key] || isMapping, "%v is read by build, it should be in buildConsumedVars", key)
+	}
+
+	for _, name := range buildConsumedVars {
+		assert.True(t, read[name], "%v is in buildConsumedVars, but build doesn't read it", name)
+	}
+}
+
+// buildFixture are the files of a small deployment: manifests with one component, and its params
+var buildFixture = map[string]string{
+	"manifests/configs/varreference.yaml":           "varReference: []\n",
+	"manifests/vars/onepanel-config-map-hidden.env": "applicationCloudApiPath: /api\napplicationCloudApiGRPCPort: 8887\napplicationCloudUiPath: /\n",
+	"manifests/vars/workflow-config-map-hidden.env": "artifactRepositoryS3AccessKeySecretName=onepanel\nartifactRepositoryS3SecretKeySecretName=onepanel\n",
+	"manifests/common/onepanel/base/vars.yaml": `database:
+  host:
+    default: postgres
+  username:
+    default: onepanel
+  password:
+    default: ""
+  port:
+    default: 5432
+  databaseName:
+    default: onepanel
+  driverName:
+    default: postgres
+`,
+	"manifests/common/onepanel/base/kustomization.yaml": "resources:\n- configmap.yaml\n- secret-onepanel-defaultnamespace.yaml\n",
+	"manifests/common/onepanel/base/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: onepanel
+  namespace: $(applicationDefaultNamespace)
+data:
+  fqdn: $(applicationFqdn)
+  apiUrl: $(applicationApiUrl)
+  nodePoolOptions: $(applicationNodePoolOptions)
+  nodePool: '$json(applicationNodePoolOptions)'
+`,
+	"manifests/common/onepanel/base/secret-onepanel-defaultnamespace.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: onepanel
+  namespace: $(applicationDefaultNamespace)
+type: Opaque
+stringData:
+  $(artifactRepositoryProviderSecret)
+  databasePassword: $(databasePassword)
+`,
+	"params.yaml": `application:
+  defaultNamespace: example
+  domain: example.com
+  fqdn: app.example.com
+  insecure: false
+  provider: eks
+  nodePool:
+    label: node.kubernetes.io/instance-type
+    options:
+      - name: 'CPU: 2, RAM: 8GB'
+        value: Standard_D2s_v3
+artifactRepository:
+  s3:
+    accessKey: access-key
+    bucket: bucket
+    endpoint: s3.amazonaws.com
+    insecure: false
+    region: us-west-2
+    secretKey: secret-key
+workflowEngine:
+  containerRuntimeExecutor: pns
+`,
+}
+
+// writeBuildFixture writes buildFixture to a temporary directory, returning the config to build it with
+func writeBuildFixture(t *testing.T) (string, *opConfig.Config) {
+	directory, err := ioutil.TempDir("", "opctl-build")
+	assert.Nil(t, err)
+
+	for name, content := range buildFixture {
+		path := filepath.Join(directory, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	config := &opConfig.Config{Spec: opConfig.ConfigSpec{
+		ManifestsRepo: filepath.Join(directory, "manifests"),
+		Params:        filepath.Join(directory, "params.yaml"),
+		Components:    []string{filepath.Join("common", "onepanel", "base")},
+	}}
+
+	return directory, config
+}
+
+// buildFixtureResult builds config, generating values into generated
+func buildFixtureResult(config *opConfig.Config, generated *opConfig.GeneratedValues) (string, error) {
+	kustomizeTemplate := TemplateFromSimpleOverlayedComponents(config.GetOverlayComponents(""), config)
+
+	return GenerateKustomizeResult(kustomizeTemplate, &GenerateKustomizeResultOptions{
+		Config:    config,
+		Generated: generated,
+	})
+}
+
+func TestGenerateKustomizeResMap(t *testing.T) {
+	Dev = true
+	defer func() { Dev = false }()
+
+	directory, config := writeBuildFixture(t)
+	defer os.RemoveAll(directory)
+
+	sourceHash, err := files.HashDirectory(config.Spec.ManifestsRepo)
+	assert.Nil(t, err)
+
+	generated := opConfig.NewGeneratedValues()
+	result, err := buildFixtureResult(config, generated)
+	assert.Nil(t, err)
+
+	assert.Contains(t, result, "  fqdn: app.example.com\n")
+	assert.Contains(t, result, `  apiUrl: https\:\/\/app\.example\.com\/api`)
+	assert.Contains(t, result, "  nodePoolOptions: |\n    - name: 'CPU: 2, RAM: 8GB'\n      value: Standard_D2s_v3\n")
+	assert.Contains(t, result, "  nodePool: '[{\"name\":\"CPU: 2, RAM: 8GB\",\"value\":\"Standard_D2s_v3\"}]'\n")
+	assert.Contains(t, result, "  artifactRepositoryS3AccessKey: access-key\n")
+	assert.Contains(t, result, "  namespace: example\n")
+	password, err := generated.Get("database.password", nil)
+	assert.Nil(t, err)
+	assert.Contains(t, result, "  databasePassword: "+password+"\n")
+
+	// The manifests are built in memory, the files on disk are left as they are
+	afterHash, err := files.HashDirectory(config.Spec.ManifestsRepo)
+	assert.Nil(t, err)
+	assert.Equal(t, sourceHash, afterHash)
+	content, err := ioutil.ReadFile(filepath.Join(config.Spec.ManifestsRepo, "common", "onepanel", "base", "configmap.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, buildFixture["manifests/common/onepanel/base/configmap.yaml"], string(content))
+
+	// Builds don't share files, so they can run at the same time. Every value is generated already, so generated is only read.
+	results := make([]string, 2)
+	errs := make([]error, 2)
+	wg := sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = buildFixtureResult(config, generated)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range results {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, result, results[i])
+	}
+}
+
+func TestGenerateKustomizeResult_Reproducible(t *testing.T) {
+	Dev = true
+	defer func() { Dev = false }()
+
+	directory, config := writeBuildFixture(t)
+	defer os.RemoveAll(directory)
+	generatedPath := filepath.Join(directory, ".onepanel", "generated.yaml")
+
+	// Each build loads the values saved by the previous one, the way build and apply do
+	results := make([]string, 2)
+	for i := range results {
+		generated, err := opConfig.LoadGeneratedValues(generatedPath)
+		assert.Nil(t, err)
+
+		results[i], err = buildFixtureResult(config, generated)
+		assert.Nil(t, err)
+		assert.Nil(t, generated.Save())
+	}
+
+	assert.Equal(t, results[0], results[1])
+}
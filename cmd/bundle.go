@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/onepanelio/cli/bundle"
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/images"
+	"github.com/onepanelio/cli/internal/resourceutil"
+	"github.com/onepanelio/cli/logging"
+	"github.com/onepanelio/cli/util"
+	"github.com/spf13/cobra"
+	yaml2 "gopkg.in/yaml.v3"
+)
+
+var (
+	// bundleWithImages if true, bundle create saves the images in the bundle, instead of only listing them
+	bundleWithImages bool
+	// bundlePlatform is the platform bundle create saves multi-platform images for
+	bundlePlatform string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:     "bundle",
+	Short:   "Work with bundles for installs without internet access.",
+	Long:    "Package the manifests, configuration and images of the deployment in config.yaml into one file, for installs without internet access.",
+	Example: "bundle create onepanel-bundle.tgz",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create <file>",
+	Short: "Creates a bundle with everything an offline install needs.",
+	Long: "Creates a gzipped tar file with the manifests in use, config.yaml, a template of the params, the list of images, " +
+		"or the images themselves with --with-images, and the sha256 of each file.\n" +
+		"Use it with opctl init --from-bundle <file> on a machine without internet access.",
+	Example: "bundle create onepanel-bundle.tgz --with-images",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := createBundle(commandContext(), args[0]); err != nil {
+			return buildError(err)
+		}
+
+		fmt.Printf("Created bundle %v\n", args[0])
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleCreateCmd)
+
+	bundleCmd.PersistentFlags().BoolVarP(&Dev, "latest", "", false, "Sets conditions to allow development testing.")
+	bundleCreateCmd.Flags().BoolVarP(&bundleWithImages, "with-images", "", false, "Saves the images in the bundle, instead of only listing them.")
+	bundleCreateCmd.Flags().StringVarP(&bundlePlatform, "platform", "", "linux/amd64", "Platform to save multi-platform images for, with --with-images.")
+}
+
+// createBundle writes a bundle of the deployment in config.yaml to bundlePath. If it fails, no file is left behind.
+// Downloading the images is canceled along with ctx.
+func createBundle(ctx context.Context, bundlePath string) (err error) {
+	config, err := opConfig.FromFile("config.yaml")
+	if err != nil {
+		return configError("unable to read configuration file: %v", err.Error())
+	}
+
+	manifestsExist, err := files.Exists(config.Spec.ManifestsRepo)
+	if err != nil {
+		return err
+	}
+	if !manifestsExist {
+		return configError("manifests %v do not exist, run opctl init first", config.Spec.ManifestsRepo)
+	}
+
+	configContent, err := ioutil.ReadFile("config.yaml")
+	if err != nil {
+		return err
+	}
+
+	configContent, configFiles, err := bundleConfigFiles(configContent, config)
+	if err != nil {
+		return err
+	}
+
+	params, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
+	if err != nil {
+		return paramsError("unable to read params file %v: %v", config.Spec.Params, err.Error())
+	}
+	params.ClearStringValues()
+	paramsTemplate, err := params.String()
+	if err != nil {
+		return err
+	}
+
+	imageList, _, err := buildImageList()
+	if err != nil {
+		return err
+	}
+
+	imagesFilePath := ""
+	if bundleWithImages {
+		imagesFilePath, err = saveBundleImages(ctx, imageList)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(imagesFilePath)
+	}
+
+	file, err := os.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		file.Close()
+		if err != nil {
+			os.Remove(bundlePath)
+		}
+	}()
+
+	writer, err := bundle.NewWriter(file, &bundle.Metadata{
+		CLIVersion:       opConfig.CLIVersion,
+		ManifestsVersion: getManifestsVersion(),
+		Images:           bundleWithImages,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writer.AddDirectory(bundle.ManifestsDirectory, config.Spec.ManifestsRepo); err != nil {
+		return err
+	}
+	if err := writer.AddFile(bundle.ConfigFileName, configContent); err != nil {
+		return err
+	}
+	for _, configFile := range configFiles {
+		if configFile.directory {
+			err = writer.AddDirectory(configFile.name, configFile.path)
+		} else {
+			err = writer.AddFileFromDisk(configFile.name, configFile.path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := writer.AddFile(bundle.ParamsTemplateFileName, []byte(paramsTemplate)); err != nil {
+		return err
+	}
+	if err := writer.AddFile(bundle.ImageListFileName, []byte(strings.Join(imageList, "\n")+"\n")); err != nil {
+		return err
+	}
+	if imagesFilePath != "" {
+		if err := writer.AddFileFromDisk(bundle.ImagesFileName, imagesFilePath); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// bundleConfigFile is a file or directory config.yaml references, and its name in the bundle
+type bundleConfigFile struct {
+	name      string
+	path      string
+	directory bool
+}
+
+// bundleConfigFiles returns configContent with the patch files and extra components pointing to their place in the bundle,
+// like patches/replicas.yaml and extraComponents/monitoring, along with the files to add there.
+// Comments and the order of configContent are kept.
+func bundleConfigFiles(configContent []byte, config *opConfig.Config) ([]byte, []bundleConfigFile, error) {
+	root := &yaml2.Node{}
+	if err := yaml2.Unmarshal(configContent, root); err != nil {
+		return nil, nil, configError("unable to read configuration file: %v", err.Error())
+	}
+
+	var spec *yaml2.Node
+	if len(root.Content) > 0 {
+		spec = resourceutil.MappingValue(root.Content[0], "spec")
+	}
+	configFiles := make([]bundleConfigFile, 0)
+	patchPaths := make(map[string]string)
+
+	// addPatch sets node, the path of a patch in the config, to its name in the bundle.
+	// The same file may be used by several patches, but two files can't have the same name.
+	addPatch := func(node *yaml2.Node, patchPath string) error {
+		name := path.Join(bundle.PatchesDirectory, filepath.Base(patchPath))
+		if other, ok := patchPaths[name]; ok && other != patchPath {
+			return configError("the patch files %v and %v have the same name, rename one to bundle them", other, patchPath)
+		}
+		if _, ok := patchPaths[name]; !ok {
+			patchPaths[name] = patchPath
+			configFiles = append(configFiles, bundleConfigFile{name: name, path: patchPath})
+		}
+		node.Value = name
+
+		return nil
+	}
+
+	if patches := resourceutil.MappingValue(spec, "patchesStrategicMerge"); patches != nil && patches.Kind == yaml2.SequenceNode {
+		for i, node := range patches.Content {
+			if i < len(config.Spec.PatchesStrategicMerge) {
+				if err := addPatch(node, config.Spec.PatchesStrategicMerge[i]); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	if patches := resourceutil.MappingValue(spec, "patchesJson6902"); patches != nil && patches.Kind == yaml2.SequenceNode {
+		for i, patch := range patches.Content {
+			node := resourceutil.MappingValue(patch, "path")
+			if node != nil && i < len(config.Spec.PatchesJson6902) {
+				if err := addPatch(node, config.Spec.PatchesJson6902[i].Path); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	// Config.Validate makes sure extra components have unique directory names
+	if components := resourceutil.MappingValue(spec, "extraComponents"); components != nil && components.Kind == yaml2.SequenceNode {
+		for i, node := range components.Content {
+			if i < len(config.Spec.ExtraComponents) {
+				componentPath := config.Spec.ExtraComponents[i]
+				node.Value = path.Join(bundle.ExtraComponentsDirectory, filepath.Base(componentPath))
+				configFiles = append(configFiles, bundleConfigFile{name: node.Value, path: componentPath, directory: true})
+			}
+		}
+	}
+
+	if len(configFiles) == 0 {
+		return configContent, configFiles, nil
+	}
+
+	buffer := &bytes.Buffer{}
+	encoder := yaml2.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return nil, nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return buffer.Bytes(), configFiles, nil
+}
+
+// saveBundleImages saves imageList to a temporary file, returning its path. The caller deletes it.
+func saveBundleImages(ctx context.Context, imageList []string) (string, error) {
+	platform, err := images.ParsePlatform(bundlePlatform)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := newRegistryClient()
+	if err != nil {
+		return "", fmt.Errorf("unable to read registry credentials: %v", err.Error())
+	}
+
+	imagesFile, err := ioutil.TempFile("", "opctl-images-*.tar")
+	if err != nil {
+		return "", err
+	}
+	defer imagesFile.Close()
+
+	logging.Infof("Saving %v images...", len(imageList))
+	if err := client.Save(ctx, imagesFile, imageList, platform); err != nil {
+		os.Remove(imagesFile.Name())
+		return "", fmt.Errorf("unable to save the images: %v", err.Error())
+	}
+
+	return imagesFile.Name(), nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"testing"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_bundleConfigFiles(t *testing.T) {
+	configContent := `spec:
+  # Patches of the site
+  patchesStrategicMerge:
+    - ./patches/replicas.yaml
+  patchesJson6902:
+    - target:
+        kind: Deployment
+        name: core
+      path: ../shared/replicas.yaml
+    - target:
+        kind: Deployment
+        name: core-ui
+      path: ./patches/replicas.yaml
+  extraComponents:
+    - ./extra/monitoring
+`
+	config := &opConfig.Config{Spec: opConfig.ConfigSpec{
+		PatchesStrategicMerge: []string{"/site/patches/replicas.yaml"},
+		PatchesJson6902: []opConfig.PatchJson6902{
+			{Path: "/shared/replicas.yaml"},
+			{Path: "/site/patches/replicas.yaml"},
+		},
+		ExtraComponents: []string{"/site/extra/monitoring"},
+	}}
+
+	_, _, err := bundleConfigFiles([]byte(configContent), config)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "same name")
+
+	config.Spec.PatchesJson6902[0].Path = "/shared/core-replicas.yaml"
+	content, configFiles, err := bundleConfigFiles([]byte(configContent), config)
+	assert.Nil(t, err)
+	assert.Equal(t, []bundleConfigFile{
+		{name: "patches/replicas.yaml", path: "/site/patches/replicas.yaml"},
+		{name: "patches/core-replicas.yaml", path: "/shared/core-replicas.yaml"},
+		{name: "extraComponents/monitoring", path: "/site/extra/monitoring", directory: true},
+	}, configFiles)
+	assert.Contains(t, string(content), "# Patches of the site")
+	assert.Contains(t, string(content), "    - patches/replicas.yaml\n")
+	assert.Contains(t, string(content), "      path: patches/core-replicas.yaml\n")
+	assert.Contains(t, string(content), "    - extraComponents/monitoring\n")
+
+	content, configFiles, err = bundleConfigFiles([]byte("spec:\n  params: params.yaml\n"), &opConfig.Config{})
+	assert.Nil(t, err)
+	assert.Empty(t, configFiles)
+	assert.Equal(t, "spec:\n  params: params.yaml\n", string(content))
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/util"
+)
+
+var (
+	// kubeconfigFlag is the kubeconfig file every client connects with
+	kubeconfigFlag string
+	// contextFlag is the kubeconfig context every client connects with
+	contextFlag string
+	// clusterFlag is the kubeconfig cluster every client connects to
+	clusterFlag string
+)
+
+// clusterPinFile is the config file whose spec.cluster pins the cluster of the current directory
+const clusterPinFile = "config.yaml"
+
+// configureCluster selects the cluster every client connects to.
+// The flags are used, along with spec.cluster of config.yaml, if there is one. Flags can't select another
+// kubeconfig, context or cluster than the ones config.yaml is pinned to.
+func configureCluster() error {
+	options := util.ClusterOptions{
+		Kubeconfig: kubeconfigFlag,
+		Context:    contextFlag,
+		Cluster:    clusterFlag,
+	}
+
+	pin, err := readClusterPin(clusterPinFile)
+	if err != nil {
+		return configError("unable to read spec.cluster of %v: %v", clusterPinFile, err.Error())
+	}
+
+	if pin != nil {
+		if pin.Kubeconfig != "" {
+			if options.Kubeconfig != "" && !samePath(options.Kubeconfig, pin.Kubeconfig) {
+				return usageError("%v is pinned to kubeconfig %v, --kubeconfig can't select %v", clusterPinFile, pin.Kubeconfig, options.Kubeconfig)
+			}
+			options.Kubeconfig = pin.Kubeconfig
+		}
+
+		if pin.Context != "" {
+			if options.Context != "" && options.Context != pin.Context {
+				return usageError("%v is pinned to context %v, --context can't select %v", clusterPinFile, pin.Context, options.Context)
+			}
+			options.Context = pin.Context
+		}
+
+		options.Server = pin.Server
+
+		// --cluster would send the credentials of the pinned context to another cluster, so only its own cluster is allowed
+		if options.Cluster != "" {
+			pinnedCluster, err := util.ContextCluster(options)
+			if err != nil {
+				return configError("unable to find the cluster %v is pinned to: %v", clusterPinFile, err.Error())
+			}
+			if options.Cluster != pinnedCluster {
+				return usageError("%v is pinned to cluster %v, --cluster can't select %v", clusterPinFile, pinnedCluster, options.Cluster)
+			}
+		}
+	}
+
+	util.SetClusterOptions(options)
+
+	return nil
+}
+
+// readClusterPin returns spec.cluster of the config file at path. nil is returned if there is no file, or no spec.cluster.
+// Only spec.cluster is read, so commands that don't use the rest of the file work while it is being edited.
+func readClusterPin(path string) (*opConfig.Cluster, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &struct {
+		Spec struct {
+			Cluster *opConfig.Cluster `yaml:"cluster"`
+		} `yaml:"spec"`
+	}{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+
+	pin := config.Spec.Cluster
+	if pin != nil && pin.Kubeconfig != "" && !filepath.IsAbs(pin.Kubeconfig) {
+		pin.Kubeconfig, err = filepath.Abs(filepath.Join(filepath.Dir(path), pin.Kubeconfig))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pin, nil
+}
+
+// samePath is true if a and b are the same file path, once made absolute
+func samePath(a, b string) bool {
+	absoluteA, errA := filepath.Abs(a)
+	absoluteB, errB := filepath.Abs(b)
+
+	return errA == nil && errB == nil && absoluteA == absoluteB
+}
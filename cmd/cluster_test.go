@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onepanelio/cli/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_readClusterPin(t *testing.T) {
+	directory, err := ioutil.TempDir("", "opctl-cluster")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "config.yaml")
+	pin, err := readClusterPin(path)
+	assert.Nil(t, err)
+	assert.Nil(t, pin)
+
+	content := "spec:\n  cluster:\n    kubeconfig: ./kubeconfig\n    context: microk8s\n    server: https://127.0.0.1:16443\n"
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+
+	pin, err = readClusterPin(path)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(directory, "kubeconfig"), pin.Kubeconfig)
+	assert.Equal(t, "microk8s", pin.Context)
+	assert.Equal(t, "https://127.0.0.1:16443", pin.Server)
+}
+
+func Test_configureCluster(t *testing.T) {
+	defer util.SetClusterOptions(util.ClusterOptions{})
+
+	contextFlag = "staging"
+	clusterFlag = "staging-cluster"
+	defer func() {
+		contextFlag = ""
+		clusterFlag = ""
+	}()
+
+	assert.Nil(t, configureCluster())
+	assert.Equal(t, util.ClusterOptions{Context: "staging", Cluster: "staging-cluster"}, util.GetClusterOptions())
+}
+
+func Test_configureCluster_pinnedCluster(t *testing.T) {
+	defer chdirTemp(t)()
+	defer util.SetClusterOptions(util.ClusterOptions{})
+	defer func() {
+		clusterFlag = ""
+	}()
+
+	kubeconfig := `apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+- name: production-cluster
+  cluster:
+    server: https://10.0.0.1:6443
+- name: staging-cluster
+  cluster:
+    server: https://10.0.0.2:6443
+contexts:
+- name: production
+  context:
+    cluster: production-cluster
+- name: staging
+  context:
+    cluster: staging-cluster
+`
+	assert.Nil(t, ioutil.WriteFile("kubeconfig", []byte(kubeconfig), 0644))
+	assert.Nil(t, ioutil.WriteFile("config.yaml", []byte("spec:\n  cluster:\n    kubeconfig: ./kubeconfig\n    context: production\n"), 0644))
+
+	clusterFlag = "staging-cluster"
+	err := configureCluster()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "pinned to cluster production-cluster")
+
+	clusterFlag = "production-cluster"
+	assert.Nil(t, configureCluster())
+	assert.Equal(t, "production-cluster", util.GetClusterOptions().Cluster)
+
+	// Without a pinned context, the current context of the pinned kubeconfig is used
+	assert.Nil(t, ioutil.WriteFile("config.yaml", []byte("spec:\n  cluster:\n    kubeconfig: ./kubeconfig\n"), 0644))
+	clusterFlag = "staging-cluster"
+	assert.Nil(t, configureCluster())
+
+	clusterFlag = "production-cluster"
+	assert.NotNil(t, configureCluster())
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/onepanelio/cli/logging"
+	"github.com/onepanelio/cli/output"
+)
+
+var (
+	// commandTimeout if set, the command is stopped once it elapses
+	commandTimeout time.Duration
+
+	// commandCtx is the context of the running command, see commandContext
+	commandCtx = context.Background()
+	// cancelCommand releases commandCtx once the command is finished
+	cancelCommand context.CancelFunc = func() {}
+)
+
+// configureContext makes the context of the command. It is done once the command is interrupted with SIGINT or SIGTERM,
+// or --timeout elapses. Interrupting again exits right away.
+func configureContext() error {
+	if commandTimeout < 0 {
+		return usageError("--timeout can't be negative")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if commandTimeout > 0 {
+		timeoutCtx, cancelTimeout := context.WithTimeout(ctx, commandTimeout)
+		cancelParent := cancel
+		ctx, cancel = timeoutCtx, func() {
+			cancelTimeout()
+			cancelParent()
+		}
+	}
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		logging.Warningf("Interrupted, stopping. Interrupt again to exit right away.")
+		cancel()
+
+		<-signals
+		logging.Default().Close()
+		os.Exit(ExitCodeInterrupted)
+	}()
+
+	commandCtx, cancelCommand = ctx, cancel
+
+	return nil
+}
+
+// commandContext returns the context cluster calls and waits of the running command are made with
+func commandContext() context.Context {
+	return commandCtx
+}
+
+// stoppedError returns err, exiting with ExitCodeTimeout or ExitCodeInterrupted, if the command was stopped.
+// Otherwise, err is returned as it is.
+func stoppedError(err error) error {
+	switch commandCtx.Err() {
+	case context.DeadlineExceeded:
+		return &ExitError{Code: ExitCodeTimeout, Err: fmt.Errorf("timed out after %v: %v", commandTimeout, err.Error())}
+	case context.Canceled:
+		return &ExitError{Code: ExitCodeInterrupted, Err: fmt.Errorf("interrupted: %v", err.Error())}
+	}
+
+	return err
+}
+
+// logProgress logs how many of resources got each result, by phase, so a stopped command shows how far it got
+func logProgress(resources []output.Resource) {
+	phases := make([]string, 0)
+	counts := make(map[string]map[string]int)
+	for _, resource := range resources {
+		if _, ok := counts[resource.Phase]; !ok {
+			phases = append(phases, resource.Phase)
+			counts[resource.Phase] = make(map[string]int)
+		}
+		counts[resource.Phase][resource.Result]++
+	}
+
+	results := []string{output.ResultApplied, output.ResultDeleted, output.ResultNotFound, output.ResultFailed, output.ResultSkipped}
+	for _, phase := range phases {
+		summary := make([]string, 0)
+		for _, result := range results {
+			if count := counts[phase][result]; count > 0 {
+				summary = append(summary, fmt.Sprintf("%v %v", count, result))
+			}
+		}
+
+		name := "resources"
+		if phase != "" {
+			name = phase + " resources"
+		}
+		logging.Warningf("%v: %v", name, strings.Join(summary, ", "))
+	}
+}
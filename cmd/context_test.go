@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_stoppedError(t *testing.T) {
+	defer func() {
+		commandCtx = context.Background()
+	}()
+
+	err := errors.New("unable to delete")
+	assert.Equal(t, err, stoppedError(err))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	commandCtx = ctx
+	err = stoppedError(applyError("unable to delete: context canceled"))
+	assert.Equal(t, ExitCodeInterrupted, exitCode(err))
+	assert.Equal(t, "interrupted: unable to delete: context canceled", err.Error())
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	commandCtx = ctx
+	assert.Equal(t, ExitCodeTimeout, exitCode(stoppedError(clusterError("unable to connect"))))
+}
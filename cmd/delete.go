@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
 
+	"github.com/onepanelio/cli/apply"
 	opConfig "github.com/onepanelio/cli/config"
 	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/logging"
+	"github.com/onepanelio/cli/output"
 	"github.com/onepanelio/cli/util"
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var (
@@ -16,59 +20,63 @@ var (
 	skipConfirmDelete bool
 )
 
+// deleteDocument is the structured output of delete
+type deleteDocument struct {
+	Resources []output.Resource `json:"resources"`
+	// Cancelled is true if the confirmation prompt was answered with anything but yes
+	Cancelled bool   `json:"cancelled,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
 var deleteCmd = &cobra.Command{
 	Use:     "delete",
 	Short:   "Deletes onepanel cluster resources",
 	Long:    "Delete all onepanel kubernetes cluster resources. Does not delete database unless it is in-cluster.",
 	Example: "delete",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		messages := messageOutput()
 		if skipConfirmDelete == false {
-			options := clientcmd.NewDefaultPathOptions()
-			config, err := options.GetStartingConfig()
+			currentContext, err := util.CurrentContext()
 			if err != nil {
-				fmt.Printf("Unable to get kubernetes config: %v", err.Error())
-				return
+				return clusterError("unable to get kubernetes config: %v", err.Error())
 			}
 
-			fmt.Println("The current kubernetes context is:", config.CurrentContext)
-			fmt.Printf("Are you sure you want to delete onepanel from '%s'? ('y' or 'yes' to confirm. Anything else to cancel): ", config.CurrentContext)
+			fmt.Fprintln(messages, "The current kubernetes context is:", currentContext)
+			fmt.Fprintf(messages, "Are you sure you want to delete onepanel from '%s'? ('y' or 'yes' to confirm. Anything else to cancel): ", currentContext)
 			userInput := ""
 			if _, err := fmt.Scanln(&userInput); err != nil {
-				fmt.Printf("Unable to get response\n")
-				return
+				return failure("unable to get response")
 			}
 
 			if userInput != "y" && userInput != "yes" {
-				return
+				if structuredOutput() {
+					return printDocument(&deleteDocument{Resources: []output.Resource{}, Cancelled: true})
+				}
+				return nil
 			}
 		}
 
 		config, err := opConfig.FromFile("config.yaml")
 		if err != nil {
-			fmt.Printf("Unable to read configuration file: %v", err.Error())
-			return
+			return configError("unable to read configuration file: %v", err.Error())
 		}
 
 		paramsYamlFile, err := util.LoadDynamicYamlFromFile(config.Spec.Params)
 		if err != nil {
-			fmt.Println("Error parsing configuration file.")
-			return
+			return paramsError("error parsing %v: %v", config.Spec.Params, err.Error())
 		}
 
 		defaultNamespaceNode := paramsYamlFile.GetValue("application.defaultNamespace")
 		if defaultNamespaceNode == nil {
-			fmt.Printf("application.defaultNamespace is missing from your '%s' file\n", config.Spec.Params)
-			return
+			return paramsError("application.defaultNamespace is missing from your '%s' file", config.Spec.Params)
 		}
 
 		if defaultNamespaceNode.Value == "default" {
-			fmt.Println("Unable to delete onepanel in the 'default' namespace")
-			return
+			return paramsError("unable to delete onepanel in the 'default' namespace")
 		}
 
 		if defaultNamespaceNode.Value == "<namespace>" {
-			fmt.Println("Unable to delete onepanel. No namespace set.")
-			return
+			return paramsError("unable to delete onepanel. No namespace set")
 		}
 
 		filesToDelete := []string{
@@ -79,23 +87,67 @@ var deleteCmd = &cobra.Command{
 		for _, filePath := range filesToDelete {
 			exists, err := files.Exists(filePath)
 			if err != nil {
-				fmt.Printf("Error checking if onepanel files exist: %v\n", err.Error())
-				return
+				return failure("error checking if onepanel files exist: %v", err.Error())
 			}
 
 			if !exists {
-				fmt.Printf("'%v' file does not exist. Are you in the directory where you ran 'opctl init'?\n", filePath)
-				return
+				return configError("'%v' file does not exist. Are you in the directory where you ran 'opctl init'?", filePath)
 			}
 		}
 
-		fmt.Printf("Deleting onepanel from your cluster...\n")
-		for _, filePath := range filesToDelete {
-			if err := util.KubectlDelete(filePath); err != nil {
-				fmt.Printf("Unable to delete: %v\n", err.Error())
-				return
+		engine, err := util.NewApplyEngine()
+		if err != nil {
+			return clusterError("unable to create kubernetes client: %v", err.Error())
+		}
+
+		document := &deleteDocument{Resources: []output.Resource{}}
+		fileObjects := make([][]*unstructured.Unstructured, len(filesToDelete))
+		fileResources := make([][]output.Resource, len(filesToDelete))
+		for i, filePath := range filesToDelete {
+			content, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return failure("unable to read %v: %v", filePath, err.Error())
+			}
+
+			fileObjects[i], err = apply.Parse(content)
+			if err != nil {
+				return configError("unable to read %v: %v", filePath, err.Error())
 			}
+			fileResources[i] = phaseResources("", fileObjects[i])
 		}
+
+		logging.Infof("Deleting onepanel from your cluster...")
+		ctx := commandContext()
+		var deleteErr error
+		for i := range filesToDelete {
+			results, err := engine.Delete(ctx, fileObjects[i])
+			for j, result := range results {
+				setResult(&fileResources[i][j], result)
+			}
+			if err != nil {
+				deleteErr = stoppedError(applyError("unable to delete: %v", err.Error()))
+				break
+			}
+		}
+
+		for _, resources := range fileResources {
+			document.Resources = append(document.Resources, resources...)
+		}
+		if ctx.Err() != nil {
+			logProgress(document.Resources)
+		}
+
+		if !structuredOutput() {
+			return deleteErr
+		}
+		if deleteErr != nil {
+			document.Error = deleteErr.Error()
+		}
+		if err := printDocument(document); err != nil {
+			return err
+		}
+
+		return deleteErr
 	},
 }
 
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/onepanelio/cli/manifest"
+)
+
+// Exit codes of opctl. They are documented in the README, so scripts can depend on them. Do not change them.
+const (
+	// ExitCodeFailure is for failures without a more specific code, like build --check finding a difference
+	ExitCodeFailure = 1
+	// ExitCodeUsage is for unknown commands, flags or arguments, and invalid flag values
+	ExitCodeUsage = 2
+	// ExitCodeConfig is for config.yaml, .onepanel/cli_config.yaml and the manifests
+	ExitCodeConfig = 3
+	// ExitCodeParams is for params.yaml values that are missing or not valid
+	ExitCodeParams = 4
+	// ExitCodeCluster is for failures connecting to, or reading from, the cluster
+	ExitCodeCluster = 5
+	// ExitCodeBuild is for kustomize failures, and resources that fail validation or the policy
+	ExitCodeBuild = 6
+	// ExitCodeApply is for failures applying resources to, or deleting them from, the cluster
+	ExitCodeApply = 7
+	// ExitCodeTimeout is for commands stopped by --timeout, like timeout(1)
+	ExitCodeTimeout = 124
+	// ExitCodeInterrupted is for commands stopped by SIGINT or SIGTERM, like shells do for SIGINT
+	ExitCodeInterrupted = 130
+)
+
+// ExitError is an error that makes opctl exit with Code
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// exitCode returns the code opctl exits with for err. Errors that are not an *ExitError come from cobra parsing the command line.
+func exitCode(err error) int {
+	var exitError *ExitError
+	if errors.As(err, &exitError) {
+		return exitError.Code
+	}
+
+	return ExitCodeUsage
+}
+
+func newExitError(code int, format string, a ...interface{}) error {
+	return &ExitError{Code: code, Err: fmt.Errorf(format, a...)}
+}
+
+// failure returns an error exiting with ExitCodeFailure
+func failure(format string, a ...interface{}) error {
+	return newExitError(ExitCodeFailure, format, a...)
+}
+
+// usageError returns an error exiting with ExitCodeUsage
+func usageError(format string, a ...interface{}) error {
+	return newExitError(ExitCodeUsage, format, a...)
+}
+
+// configError returns an error exiting with ExitCodeConfig
+func configError(format string, a ...interface{}) error {
+	return newExitError(ExitCodeConfig, format, a...)
+}
+
+// paramsError returns an error exiting with ExitCodeParams
+func paramsError(format string, a ...interface{}) error {
+	return newExitError(ExitCodeParams, format, a...)
+}
+
+// clusterError returns an error exiting with ExitCodeCluster
+func clusterError(format string, a ...interface{}) error {
+	return newExitError(ExitCodeCluster, format, a...)
+}
+
+// applyError returns an error exiting with ExitCodeApply
+func applyError(format string, a ...interface{}) error {
+	return newExitError(ExitCodeApply, format, a...)
+}
+
+// buildError returns the error of GenerateKustomizeResult and related functions, in a human friendly form, with its exit code.
+// Errors that already have an exit code keep it.
+func buildError(err error) error {
+	var exitError *ExitError
+	if errors.As(err, &exitError) {
+		return err
+	}
+
+	if _, ok := err.(*manifest.ParamsError); ok {
+		return &ExitError{Code: ExitCodeParams, Err: errors.New(HumanizeKustomizeError(err))}
+	}
+
+	return &ExitError{Code: ExitCodeBuild, Err: errors.New(HumanizeKustomizeError(err))}
+}
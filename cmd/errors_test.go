@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/onepanelio/cli/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_exitCode(t *testing.T) {
+	assert.Equal(t, ExitCodeConfig, exitCode(configError("unable to read configuration file: %v", "missing")))
+	assert.Equal(t, ExitCodeCluster, exitCode(fmt.Errorf("wrapped: %w", clusterError("unable to connect"))))
+	assert.Equal(t, ExitCodeUsage, exitCode(errors.New("unknown flag: --foo")))
+}
+
+func Test_buildError(t *testing.T) {
+	err := buildError(&manifest.ParamsError{Key: "application.fqdn", ShortKey: "fqdn", ErrorType: "missing"})
+	assert.Equal(t, ExitCodeParams, exitCode(err))
+	assert.Equal(t, "application.fqdn is missing in your params.yaml", err.Error())
+
+	err = buildError(paramsError("missing required values in params.yaml: artifactRepository.s3.bucket"))
+	assert.Equal(t, ExitCodeParams, exitCode(err))
+
+	err = buildError(errors.New("kustomize failed"))
+	assert.Equal(t, ExitCodeBuild, exitCode(err))
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/images"
+	"github.com/onepanelio/cli/logging"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// imagesResolve if true, images list looks up the digest of each image in its registry
+	imagesResolve bool
+	// imagesPlatform is the platform images save saves multi-platform images for
+	imagesPlatform string
+)
+
+var imagesCmd = &cobra.Command{
+	Use:     "images",
+	Short:   "Work with the container images of the deployment.",
+	Long:    "List and save the container images the deployment in config.yaml uses, for installs without internet access.",
+	Example: "images list",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var imagesListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "Lists the container images the deployment uses.",
+	Long:    "Builds the deployment in config.yaml and lists every unique container image in it, including init containers and images in ConfigMaps.",
+	Example: "images list --resolve",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageList, rewriter, err := buildImageList()
+		if err != nil {
+			return buildError(err)
+		}
+
+		if !imagesResolve && rewriter == nil {
+			for _, image := range imageList {
+				fmt.Println(image)
+			}
+			return nil
+		}
+
+		var client *images.Client
+		if imagesResolve {
+			client, err = newRegistryClient()
+			if err != nil {
+				return configError("unable to read registry credentials: %v", err.Error())
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := "IMAGE"
+		if rewriter != nil {
+			header += "\tMIRROR"
+		}
+		if imagesResolve {
+			header += "\tDIGEST"
+		}
+		fmt.Fprintln(w, header)
+
+		for _, image := range imageList {
+			line := image
+			if rewriter != nil {
+				line += "\t" + rewriter.Rewrite(image)
+			}
+
+			if imagesResolve {
+				digest := ""
+				ref, err := images.ParseReference(image)
+				if err == nil {
+					digest, err = client.ResolveDigest(commandContext(), ref)
+				}
+				if err != nil {
+					digest = fmt.Sprintf("error: %v", err.Error())
+				}
+				line += "\t" + digest
+			}
+
+			fmt.Fprintln(w, line)
+		}
+		w.Flush()
+
+		return nil
+	},
+}
+
+var imagesSaveCmd = &cobra.Command{
+	Use:   "save <file>",
+	Short: "Saves the container images the deployment uses to a tar file.",
+	Long: "Downloads every image images list prints, and saves them to a tar file with an OCI image layout.\n" +
+		"The file can also be loaded with docker load, to push the images to a registry without internet access.",
+	Example: "images save onepanel-images.tar --platform linux/amd64",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		platform, err := images.ParsePlatform(imagesPlatform)
+		if err != nil {
+			return usageError("%v", err.Error())
+		}
+
+		imageList, _, err := buildImageList()
+		if err != nil {
+			return buildError(err)
+		}
+
+		client, err := newRegistryClient()
+		if err != nil {
+			return configError("unable to read registry credentials: %v", err.Error())
+		}
+
+		file, err := os.Create(args[0])
+		if err != nil {
+			return failure("unable to create %v: %v", args[0], err.Error())
+		}
+		defer file.Close()
+
+		logging.Infof("Saving %v images to %v...", len(imageList), args[0])
+		if err := client.Save(commandContext(), file, imageList, platform); err != nil {
+			file.Close()
+			os.Remove(args[0])
+			return failure("unable to save the images: %v", err.Error())
+		}
+
+		fmt.Printf("Saved %v images to %v\n", len(imageList), args[0])
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(imagesCmd)
+	imagesCmd.AddCommand(imagesListCmd)
+	imagesCmd.AddCommand(imagesSaveCmd)
+
+	imagesCmd.PersistentFlags().BoolVarP(&Dev, "latest", "", false, "Sets conditions to allow development testing.")
+	imagesListCmd.Flags().BoolVarP(&imagesResolve, "resolve", "", false, "Looks up the digest of each image in its registry.")
+	imagesSaveCmd.Flags().StringVarP(&imagesPlatform, "platform", "", "linux/amd64", "Platform to save multi-platform images for.")
+}
+
+// buildImageList builds the deployment in config.yaml, and returns the images in it.
+// The images are the ones in the manifests, not the ones in spec.imageRegistry, as those are the ones to download.
+// If spec.imageRegistry is set, the rewriter moving images to it is returned as well.
+// The cluster is not contacted, so this works without access to it.
+func buildImageList() ([]string, *images.Rewriter, error) {
+	config, err := opConfig.FromFile("config.yaml")
+	if err != nil {
+		return nil, nil, configError("unable to read configuration file: %v", err.Error())
+	}
+
+	generated, err := opConfig.LoadGeneratedValues(opConfig.GeneratedValuesFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rewriter *images.Rewriter
+	if registry := config.Spec.ImageRegistry; registry != nil {
+		rewriter = images.NewRewriter(registry.URL, registry.Overrides)
+	}
+
+	upstreamConfig := *config
+	upstreamConfig.Spec.ImageRegistry = nil
+
+	kustomizeTemplate := TemplateFromSimpleOverlayedComponents(upstreamConfig.GetOverlayComponents(""), &upstreamConfig)
+	rm, err := GenerateKustomizeResMap(kustomizeTemplate, &GenerateKustomizeResultOptions{
+		Config:    &upstreamConfig,
+		Generated: generated,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return images.List(rm), rewriter, nil
+}
+
+// newRegistryClient returns a registry client with the credentials of docker login
+func newRegistryClient() (*images.Client, error) {
+	credentials, err := images.LoadDockerCredentials(images.DefaultDockerConfigPath())
+	if err != nil {
+		return nil, err
+	}
+
+	return images.NewClient(credentials), nil
+}
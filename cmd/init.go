@@ -3,13 +3,13 @@ package cmd
 import (
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/onepanelio/cli/config"
 	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/logging"
 	"github.com/onepanelio/cli/manifest"
 	"github.com/onepanelio/cli/template"
 	"github.com/onepanelio/cli/util"
@@ -39,6 +39,7 @@ var (
 	Database                   bool
 	GPUDevicePlugins           []string
 	Services                   []string
+	FromBundle                 string
 )
 
 // ProviderProperties are data associated with various providers, like microk8s vs eks
@@ -68,72 +69,104 @@ var providerProperties = map[string]ProviderProperties{
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Gets latest manifests and generates params.yaml file.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if err := validateInput(); err != nil {
-			log.Println(err.Error())
-			return
+			return usageError("%v", err.Error())
 		}
 
-		log.Printf("Initializing...")
+		ctx := commandContext()
+
+		logging.Infof("Initializing...")
 		configFile := filepath.Join(".onepanel", "cli_config.yaml")
 		exists, err := files.Exists(configFile)
 		if err != nil {
-			log.Printf("[error] checking for config file %v", configFile)
-			return
+			return failure("checking for config file %v: %v", configFile, err.Error())
 		}
 
-		if !exists {
+		if FromBundle != "" {
+			bundlePath, err := filepath.Abs(FromBundle)
+			if err != nil {
+				return failure("%v", err.Error())
+			}
+
+			if err := manifest.CreateBundleSourceConfigFile(configFile, bundlePath); err != nil {
+				return configError("creating bundle source config: %v", err.Error())
+			}
+		} else if !exists {
 			if err := manifest.CreateGithubSourceConfigFile(configFile); err != nil {
-				log.Printf("[error] creating default source config: %v", err.Error())
-				return
+				return configError("creating default source config: %v", err.Error())
 			}
 		}
 
 		source, err := manifest.LoadManifestSourceFromFileConfig(configFile)
 		if err != nil {
-			log.Printf("[error] loading manifest source: %v", err.Error())
-			return
+			return configError("loading manifest source: %v", err.Error())
 		}
 
 		// When updating cli versions, the cli_config.yaml may already exist.
 		// Check if we need to generate a new cli_config.yaml, to match the cli version.
+		// A tag that declares it supports this CLI version is kept, one that declares it doesn't is refused.
+		// Releases without a declaration are replaced, as before compatibility declarations existed.
 		tag := config.ManifestsRepositoryTag
 		if source.GetSourceType() == manifest.SourceGithub {
-			if source.GetTag() != "" {
-				if tag != source.GetTag() {
+			if source.GetTag() != "" && tag != source.GetTag() {
+				compatibility, err := manifest.GetGithubCompatibility(ctx, source.GetTag())
+				if err != nil {
+					return configError("checking compatibility of manifests %v: %v", source.GetTag(), err.Error())
+				}
+
+				switch {
+				case compatibility == nil:
 					if err := manifest.CreateGithubSourceConfigFile(configFile); err != nil {
-						log.Printf("[error] creating default source config: %v", err.Error())
-						return
+						return configError("creating default source config: %v", err.Error())
 					}
 					source, err = manifest.LoadManifestSourceFromFileConfig(configFile)
 					if err != nil {
-						log.Printf("[error] loading manifest source: %v", err.Error())
-						return
+						return configError("loading manifest source: %v", err.Error())
 					}
+				case compatibility.Status(config.CLIVersion) == manifest.Incompatible:
+					return configError("%v pins manifests %v, which require CLI %v. This CLI is %v. Change the tag or delete %v to use manifests %v",
+						configFile, source.GetTag(), compatibility.String(), config.CLIVersion, configFile, tag)
+				case compatibility.Status(config.CLIVersion) == manifest.CompatibilityUnknown:
+					logging.Warningf("unable to check if CLI version '%v' is compatible with manifests %v, which require CLI %v",
+						config.CLIVersion, source.GetTag(), compatibility.String())
 				}
 			}
 		} else {
-			fmt.Printf("cli_config.yaml is using %v as source, ignoring CLI tag %v", manifest.SourceDirectory, config.CLIVersion)
+			fmt.Printf("cli_config.yaml is using %v as source, ignoring CLI tag %v\n", source.GetSourceType(), config.CLIVersion)
 		}
 
 		pwd, err := os.Getwd()
 		if err != nil {
-			log.Printf("[error] %v", err.Error())
-			return
+			return failure("%v", err.Error())
 		}
-		if err := source.MoveToDirectory(filepath.Join(pwd, manifestsFilePath)); err != nil {
-			log.Printf("[error] %v", err.Error())
-			return
+		if err := source.MoveToDirectory(ctx, filepath.Join(pwd, manifestsFilePath)); err != nil {
+			return failure("%v", err.Error())
 		}
 
 		manifestsRepoPath, err := source.GetManifestPath()
 		if err != nil {
-			log.Printf("[error] %v", err.Error())
-			return
+			return failure("%v", err.Error())
+		}
+
+		compatibility, err := manifest.LoadCompatibility(manifestsRepoPath)
+		if err != nil {
+			return failure("%v", err.Error())
+		}
+		if compatibility.Status(config.CLIVersion) == manifest.Incompatible {
+			return configError("manifests at %v require CLI %v. This CLI is %v", manifestsRepoPath, compatibility.String(), config.CLIVersion)
+		}
+
+		// The merged manifests only carry the compatibility.yaml of the last layer that has one,
+		// so every layer, like a pinned onepanel release under a directory of customizations, is checked on its own.
+		if layeredSource, ok := source.(*manifest.LayeredSource); ok {
+			if err := checkLayersCompatibility(layeredSource); err != nil {
+				return err
+			}
 		}
 
 		if err := files.CreateIfNotExist(ParametersFilePath); err != nil {
-			log.Println(err.Error())
+			return failure("%v", err.Error())
 		}
 
 		setup := config.Config{
@@ -148,38 +181,33 @@ var initCmd = &cobra.Command{
 
 		loadedManifest, err := manifest.LoadManifest(manifestsRepoPath)
 		if err != nil {
-			log.Printf("[error] LoadManifest %v", err.Error())
+			return configError("unable to load manifest: %v", err.Error())
 		}
 
 		bld := manifest.CreateBuilder(loadedManifest)
 		if err := bld.AddCommonComponents(); err != nil {
-			log.Printf("[error] AddCommonComponents %v", err.Error())
-			return
+			return configError("unable to add common components: %v", err.Error())
 		}
 
 		bld.AddOverlayContender(ArtifactRepositoryProvider)
 
 		if err := addCloudProviderToManifestBuilder(Provider, bld); err != nil {
-			log.Printf("[error] Adding Cloud Provider: %v", err.Error())
-			return
+			return usageError("adding cloud provider: %v", err.Error())
 		}
 
 		if err := addDNSProviderToManifestBuilder(DNS, bld); err != nil {
-			log.Printf("[error] Adding Dns Provider: %v", err.Error())
-			return
+			return usageError("adding DNS provider: %v", err.Error())
 		}
 
 		if EnableEFKLogging {
 			if err := bld.AddComponent("logging"); err != nil {
-				log.Printf("[error] Adding logging component: %v", err.Error())
-				return
+				return usageError("adding logging component: %v", err.Error())
 			}
 		}
 
 		if GPUDevicePlugins != nil {
 			if err := bld.AddComponent("gpu-plugins"); err != nil {
-				log.Printf("[error] Adding GPU plugins component: %v", err.Error())
-				return
+				return usageError("adding GPU plugins component: %v", err.Error())
 			}
 
 			for i, d := range GPUDevicePlugins {
@@ -199,28 +227,25 @@ var initCmd = &cobra.Command{
 
 		if Services != nil {
 			if err := bld.AddComponent(Services...); err != nil {
-				log.Printf("[error] Adding Components: %v", err.Error())
+				return usageError("adding components: %v", err.Error())
 			}
 		}
 
 		if Provider == "eks" {
 			overlay := strings.Join([]string{"cluster-autoscaler", "overlays", "eks"}, string(os.PathSeparator))
 			if err := bld.AddOverlay(overlay); err != nil {
-				log.Printf("[error] Adding overlay %v:\n", err.Error())
-				return
+				return configError("adding overlay: %v", err.Error())
 			}
 		}
 
 		if !DisableServing {
 			if err := bld.AddComponent("kfserving"); err != nil {
-				log.Printf("[error] Adding component kfserving %v", err.Error())
-				return
+				return configError("adding component kfserving: %v", err.Error())
 			}
 
 			if !EnableCertManager {
 				if err := bld.AddComponent("cert-manager"); err != nil {
-					log.Printf(err.Error())
-					return
+					return configError("adding component cert-manager: %v", err.Error())
 				}
 				bld.AddOverlayContender("self-signed")
 			}
@@ -231,8 +256,7 @@ var initCmd = &cobra.Command{
 		}
 
 		if err := bld.Build(); err != nil {
-			log.Printf("[error] building components and overlays: %v", err.Error())
-			return
+			return configError("building components and overlays: %v", err.Error())
 		}
 
 		for _, overlayComponent := range bld.GetOverlayComponents() {
@@ -244,14 +268,12 @@ var initCmd = &cobra.Command{
 
 		builder := template.NewBuilderFromConfig(setup)
 		if err := builder.Build(); err != nil {
-			log.Printf("err generating config. Error %v", err.Error())
-			return
+			return failure("unable to generate config: %v", err.Error())
 		}
 
 		mergedParams, err := util.LoadDynamicYamlFromFile(ParametersFilePath)
 		if err != nil {
-			log.Printf("[error] loading params file: %v", err.Error())
-			return
+			return paramsError("loading params file: %v", err.Error())
 		}
 
 		mergedParams.Merge(bld.GetYamls()...)
@@ -269,56 +291,47 @@ var initCmd = &cobra.Command{
 		inputCommand += "# Command: opctl " + strings.Join(os.Args[1:], " ") + "\n"
 		inputCommand += "# - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -"
 		if err := mergedParams.SetTopComment(inputCommand); err != nil {
-			log.Printf("[error] setting comments: %v", err.Error())
-			return
+			return failure("setting comments: %v", err.Error())
 		}
 
 		// Workflow Engine defaults to pns, but can be overwritten
 		if err := mergedParams.Delete("workflowEngine"); err != nil {
-			log.Printf("[error] %v", err.Error())
-			return
+			return failure("%v", err.Error())
 		}
 
 		// By default, random credentials for a Postgres database are generated
 		if !Database {
 			if err := mergedParams.Delete("database"); err != nil {
-				log.Printf("[error] %v", err.Error())
-				return
+				return failure("%v", err.Error())
 			}
 		}
 
 		paramsString, err := mergedParams.String()
 		if err != nil {
-			log.Printf("[error] unable to write params to a string")
-			return
+			return failure("unable to write params to a string")
 		}
 
 		paramsFile, err := os.OpenFile(ParametersFilePath, os.O_RDWR|os.O_TRUNC, 0)
 		if err != nil {
-			log.Printf("Error opening parameters file: %v", err.Error())
-			return
+			return failure("unable to open parameters file: %v", err.Error())
 		}
 
 		if _, err := paramsFile.WriteString(paramsString); err != nil {
-			log.Printf("Error writing merged parameters: %v", err.Error())
-			return
+			return failure("unable to write merged parameters: %v", err.Error())
 		}
 
 		file, err := os.Create(ConfigurationFilePath)
 		if err != nil {
-			log.Printf("unable to create %v file: %v", ConfigurationFilePath, err.Error())
-			return
+			return failure("unable to create %v file: %v", ConfigurationFilePath, err.Error())
 		}
 
 		setupData, err := yaml.Marshal(setup)
 		if err != nil {
-			log.Printf("unable to marshal yaml data: %v", err.Error())
-			return
+			return failure("unable to marshal yaml data: %v", err.Error())
 		}
 
 		if _, err := file.Write(setupData); err != nil {
-			log.Printf("unable to write yaml data: %v", err.Error())
-			return
+			return failure("unable to write yaml data: %v", err.Error())
 		}
 
 		fmt.Printf("Configuration has been created with\n")
@@ -330,6 +343,8 @@ var initCmd = &cobra.Command{
 
 		fmt.Printf("- Configuration file: %v\n", ConfigurationFilePath)
 		fmt.Printf("- Parameters file has been created with placeholders: %v\n", ParametersFilePath)
+
+		return nil
 	},
 }
 
@@ -349,6 +364,7 @@ func init() {
 	initCmd.Flags().StringSliceVarP(&Services, "services", "", nil, "Install additional services. Valid values can be comma separated and are: modeldb")
 	initCmd.Flags().BoolVarP(&Database, "database", "", false, "Use a pre-existing database, set up configuration in params.yaml")
 	initCmd.Flags().BoolVarP(&DisableServing, "disable-serving", "", false, "Disable model serving")
+	initCmd.Flags().StringVarP(&FromBundle, "from-bundle", "", "", "Use the manifests of a bundle made by opctl bundle create, without network access")
 }
 
 func validateInput() error {
@@ -502,7 +518,7 @@ func removeUneededArtifactRepositoryProviders(mergedParams *util.DynamicYaml) {
 		if nodeKey != nil {
 			err := mergedParams.Delete(nodeKeyStr)
 			if err != nil {
-				log.Printf("error during init, artifact repository provider. %v", err.Error())
+				logging.Errorf("error during init, artifact repository provider. %v", err.Error())
 				return
 			}
 		}
@@ -511,7 +527,35 @@ func removeUneededArtifactRepositoryProviders(mergedParams *util.DynamicYaml) {
 	parentValue := mergedParams.GetValue("artifactRepository")
 	if parentValue != nil && len(parentValue.Content) == 0 {
 		if err := mergedParams.Delete("artifactRepository"); err != nil {
-			log.Printf("error during init, artifact repository provider. %v", err)
+			logging.Errorf("error during init, artifact repository provider. %v", err)
 		}
 	}
 }
+
+// checkLayersCompatibility refuses the layered source if any of its layers declares it doesn't support this CLI version
+func checkLayersCompatibility(source *manifest.LayeredSource) error {
+	for i, layer := range source.Layers() {
+		layerPath, err := layer.GetManifestPath()
+		if err != nil {
+			return failure("%v", err.Error())
+		}
+
+		compatibility, err := manifest.LoadCompatibility(layerPath)
+		if err != nil {
+			return configError("checking compatibility of manifests layer %v: %v", i, err.Error())
+		}
+
+		switch compatibility.Status(config.CLIVersion) {
+		case manifest.Incompatible:
+			return configError("manifests layer %v (%v %v) requires CLI %v. This CLI is %v",
+				i, layer.GetSourceType(), layerPath, compatibility.String(), config.CLIVersion)
+		case manifest.CompatibilityUnknown:
+			if compatibility != nil {
+				logging.Warningf("unable to check if CLI version '%v' is compatible with manifests layer %v, which requires CLI %v",
+					config.CLIVersion, i, compatibility.String())
+			}
+		}
+	}
+
+	return nil
+}
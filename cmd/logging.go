@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/onepanelio/cli/logging"
+)
+
+var (
+	// verbosity is the number of times --verbose is set. Once shows debug messages, twice every Kubernetes API call as well.
+	verbosity int
+	// quiet if true, only errors are shown
+	quiet bool
+	// logFile if set, every message, down to the Kubernetes API calls, is appended to this file
+	logFile string
+)
+
+// configureLogging sets up the logger of the CLI from the flags.
+// Messages of libraries using the standard log package go through it as well.
+func configureLogging() error {
+	if quiet && verbosity > 0 {
+		return usageError("--quiet can't be used with --verbose")
+	}
+
+	level := logging.LevelInfo
+	switch {
+	case quiet:
+		level = logging.LevelError
+	case verbosity == 1:
+		level = logging.LevelDebug
+	case verbosity > 1:
+		level = logging.LevelTrace
+	}
+	logging.SetLevel(level)
+
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return usageError("unable to open --log-file: %v", err.Error())
+		}
+		if err := logging.Default().SetFile(file, logging.LevelTrace); err != nil {
+			return failure("%v", err.Error())
+		}
+		logging.Debugf("opctl %v", os.Args[1:])
+	}
+
+	log.SetFlags(0)
+	log.SetOutput(logging.Default().Writer(logging.LevelInfo))
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/github"
+	"github.com/onepanelio/cli/manifest"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// manifestsVersionsPage is the page of releases to list
+	manifestsVersionsPage int
+	// manifestsVersionsPerPage is the number of releases per page
+	manifestsVersionsPerPage int
+	// manifestsVersionsAll if true, lists every release, ignoring the page flags
+	manifestsVersionsAll bool
+)
+
+var manifestsCmd = &cobra.Command{
+	Use:     "manifests",
+	Short:   "Work with onepanel manifests.",
+	Long:    "Inspect the onepanel manifests releases and tools for manifest authors.",
+	Example: "manifests versions",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var manifestsVersionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "Lists manifest releases and whether this CLI can use them.",
+	Long: "Lists manifest releases from Github. The release this CLI was built with is marked with a *.\n" +
+		"Compatibility is read from the " + manifest.CompatibilityFileName + " of each release, and cached by tag.",
+	Example: "manifests versions --page 2",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if manifestsVersionsPage < 1 {
+			return usageError("--page starts at 1")
+		}
+		if manifestsVersionsPerPage < 1 || manifestsVersionsPerPage > 100 {
+			return usageError("--per-page should be between 1 and 100")
+		}
+
+		ctx := commandContext()
+
+		githubAPI, err := github.New(manifest.GithubManifestsRepositoryURL)
+		if err != nil {
+			return failure("unable to connect to Github: %v", err.Error())
+		}
+
+		var releases []*github.Release
+		hasNextPage := false
+		if manifestsVersionsAll {
+			releases, err = githubAPI.ListAllReleases(ctx)
+		} else {
+			releases, hasNextPage, err = githubAPI.ListReleases(ctx, manifestsVersionsPage, manifestsVersionsPerPage)
+		}
+		if err != nil {
+			return failure("unable to list manifest releases: %v", err.Error())
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tTAG\tPUBLISHED\tCLI VERSIONS\tCOMPATIBLE")
+		for _, release := range releases {
+			marker := ""
+			if release.TagName == opConfig.ManifestsRepositoryTag {
+				marker = "*"
+			}
+
+			published := release.PublishedAt
+			if index := strings.Index(published, "T"); index > 0 {
+				published = published[:index]
+			}
+
+			compatibilityRange := "?"
+			status := manifest.CompatibilityUnknown
+			data, err := manifest.GetReleaseCompatibilityData(ctx, githubAPI, release.TagName, manifest.CompatibilityCacheDir())
+			if err == nil {
+				compatibility, parseErr := manifest.ParseCompatibility(data)
+				if parseErr != nil {
+					compatibilityRange = "invalid"
+				} else {
+					compatibilityRange = compatibility.String()
+					status = compatibility.Status(opConfig.CLIVersion)
+				}
+			}
+
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", marker, release.TagName, published, compatibilityRange, status)
+		}
+		w.Flush()
+
+		if hasNextPage {
+			fmt.Printf("\nMore releases are available with --page %v\n", manifestsVersionsPage+1)
+		}
+
+		return nil
+	},
+}
+
+var manifestsLintCmd = &cobra.Command{
+	Use:   "lint <dir>",
+	Short: "Checks manifests for mistakes.",
+	Long: "Checks that each component has a base, overlays belong to a component, every variable reference, like $(var),\n" +
+		"is defined by a vars.yaml or by the CLI, and every $file(path) exists. Unused vars.yaml keys are reported as warnings.\n" +
+		"Exits with an error if any errors are found.",
+	Example: "manifests lint ./manifests",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issues, err := manifest.Lint(args[0], manifest.LintOptions{
+			InjectedVars: buildInjectedVars,
+			ConsumedVars: buildConsumedVars,
+		})
+		if err != nil {
+			return failure("unable to lint manifests: %v", err.Error())
+		}
+
+		errorCount := 0
+		for _, issue := range issues {
+			if issue.Severity == manifest.LintError {
+				errorCount++
+			}
+			fmt.Println(issue.String())
+		}
+
+		fmt.Printf("%v errors, %v warnings\n", errorCount, len(issues)-errorCount)
+		if errorCount > 0 {
+			return configError("the manifests have %v errors", errorCount)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(manifestsCmd)
+	manifestsCmd.AddCommand(manifestsVersionsCmd)
+	manifestsCmd.AddCommand(manifestsLintCmd)
+
+	manifestsVersionsCmd.Flags().IntVarP(&manifestsVersionsPage, "page", "", 1, "Page of releases to list, starting at 1")
+	manifestsVersionsCmd.Flags().IntVarP(&manifestsVersionsPerPage, "per-page", "", 30, "Number of releases per page, up to 100")
+	manifestsVersionsCmd.Flags().BoolVarP(&manifestsVersionsAll, "all", "", false, "List every release")
+}
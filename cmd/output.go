@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/onepanelio/cli/output"
+)
+
+var (
+	// outputFlag is the value of --output
+	outputFlag string
+	// outputFormat is the format commands print their results in, parsed from outputFlag
+	outputFormat = output.FormatText
+	// documentPrinted is true once a command printed its document. A command that fails after printing it,
+	// like app status for a deployment that is not ready, does not print an errorDocument as well.
+	documentPrinted bool
+)
+
+// errorDocument is printed instead of a command's document when it fails
+type errorDocument struct {
+	Error    string `json:"error"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// structuredOutput is true if commands print documents, instead of text
+func structuredOutput() bool {
+	return outputFormat.Structured()
+}
+
+// messageOutput is where commands print prompts for people. Progress goes to the logger.
+// With structured output, it is stderr, so stdout only has the document.
+func messageOutput() io.Writer {
+	if structuredOutput() {
+		return os.Stderr
+	}
+
+	return os.Stdout
+}
+
+// printDocument prints the result of a command, in outputFormat, to stdout
+func printDocument(document interface{}) error {
+	if err := output.Write(os.Stdout, outputFormat, document); err != nil {
+		return failure("unable to print the result: %v", err.Error())
+	}
+	documentPrinted = true
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/manifest"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// Annotations added to every built resource, to find the build that produced an object in the cluster
+const (
+	annotationCLIVersion       = "onepanel.io/cli-version"
+	annotationManifestsVersion = "onepanel.io/manifests-version"
+	annotationParamsHash       = "onepanel.io/params-hash"
+	annotationBuildTime        = "onepanel.io/build-time"
+)
+
+// newBuildLock records the current inputs of the build of config, read from configPath.
+// If they match opctl.lock, its build time is kept, so building again gives the same output.
+func newBuildLock(configPath string, config *opConfig.Config) (*opConfig.Lock, error) {
+	core, err := getCoreImages()
+	if err != nil {
+		return nil, err
+	}
+
+	manifestsHash, err := files.HashDirectory(config.Spec.ManifestsRepo)
+	if err != nil {
+		return nil, fmt.Errorf("unable to hash the manifests: %v", err.Error())
+	}
+
+	paramsHash, err := files.HashFile(config.Spec.Params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to hash the params: %v", err.Error())
+	}
+
+	configHash, err := hashConfig(configPath, config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to hash the config: %v", err.Error())
+	}
+
+	lock := &opConfig.Lock{
+		CLIVersion:     opConfig.CLIVersion,
+		ManifestsHash:  manifestsHash,
+		ParamsHash:     paramsHash,
+		ConfigHash:     configHash,
+		CoreImageTag:   core.CoreTag,
+		CoreUIImageTag: core.CoreUITag,
+		BuildTime:      time.Now().UTC().Format(time.RFC3339),
+	}
+
+	// Without a source config, like when the manifests are not set up by init, no source is recorded
+	sourceConfigPath := filepath.Join(".onepanel", "cli_config.yaml")
+	sourceConfigExists, err := files.Exists(sourceConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if sourceConfigExists {
+		source, err := manifest.LoadManifestSourceFromFileConfig(sourceConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the manifests source: %v", err.Error())
+		}
+		lock.ManifestsSource = source.GetSourceType()
+		lock.ManifestsVersion = source.GetTag()
+	}
+
+	existing, err := opConfig.LoadLock(opConfig.LockFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && len(existing.Drift(lock)) == 0 {
+		lock.BuildTime = existing.BuildTime
+	}
+
+	return lock, nil
+}
+
+// hashConfig returns the hex encoded sha256 of the config file at configPath, and of the patch files
+// and extra component directories of config, in the order config lists them.
+func hashConfig(configPath string, config *opConfig.Config) (string, error) {
+	hash := sha256.New()
+
+	add := func(kind, path, sum string) {
+		hash.Write([]byte(kind + " " + path + " " + sum + "\n"))
+	}
+
+	sum, err := files.HashFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	add("config", "", sum)
+
+	patches := append([]string{}, config.Spec.PatchesStrategicMerge...)
+	for _, patch := range config.Spec.PatchesJson6902 {
+		patches = append(patches, patch.Path)
+	}
+	for _, patch := range patches {
+		sum, err := files.HashFile(patch)
+		if err != nil {
+			return "", err
+		}
+		add("patch", filepath.Base(patch), sum)
+	}
+
+	for _, component := range config.Spec.ExtraComponents {
+		sum, err := files.HashDirectory(component)
+		if err != nil {
+			return "", err
+		}
+		add("extraComponent", filepath.Base(component), sum)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// checkLock returns an error if the inputs of the build differ from the ones recorded in opctl.lock
+func checkLock(lock *opConfig.Lock) error {
+	locked, err := opConfig.LoadLock(opConfig.LockFilePath)
+	if err != nil {
+		return err
+	}
+	if locked == nil {
+		return fmt.Errorf("%v does not exist. Run opctl build first", opConfig.LockFilePath)
+	}
+
+	drift := locked.Drift(lock)
+	if len(drift) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("the build has drifted from %v:", opConfig.LockFilePath)
+	for _, item := range drift {
+		message += "\n  " + item
+	}
+
+	return fmt.Errorf("%v", message)
+}
+
+// stampProvenance annotates every resource in rm with the build recorded in lock
+func stampProvenance(rm resmap.ResMap, lock *opConfig.Lock) error {
+	manifestsVersion := lock.ManifestsVersion
+	if manifestsVersion == "" {
+		manifestsVersion = "sha256:" + lock.ManifestsHash[:12]
+	}
+
+	for _, r := range rm.Resources() {
+		annotations := r.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		annotations[annotationCLIVersion] = lock.CLIVersion
+		annotations[annotationManifestsVersion] = manifestsVersion
+		annotations[annotationParamsHash] = lock.ParamsHash
+		annotations[annotationBuildTime] = lock.BuildTime
+
+		if err := r.SetAnnotations(annotations); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	opConfig "github.com/onepanelio/cli/config"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+)
+
+// chdirTemp changes to a new temporary directory, returning a function that changes back and deletes it
+func chdirTemp(t *testing.T) func() {
+	directory, err := ioutil.TempDir("", "opctl-provenance")
+	assert.Nil(t, err)
+
+	previous, err := os.Getwd()
+	assert.Nil(t, err)
+	assert.Nil(t, os.Chdir(directory))
+
+	return func() {
+		assert.Nil(t, os.Chdir(previous))
+		assert.Nil(t, os.RemoveAll(directory))
+	}
+}
+
+func Test_newBuildLock(t *testing.T) {
+	defer chdirTemp(t)()
+
+	Dev = true
+	defer func() { Dev = false }()
+
+	assert.Nil(t, os.MkdirAll(filepath.Join("manifests", "vars"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join("manifests", "vars", "a.env"), []byte("a=1\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile("params.yaml", []byte("application: {}\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile("config.yaml", []byte("spec: {}\n"), 0644))
+	assert.Nil(t, ioutil.WriteFile("patch.yaml", []byte("kind: Deployment\n"), 0644))
+
+	config := &opConfig.Config{
+		Spec: opConfig.ConfigSpec{
+			ManifestsRepo:         "manifests",
+			Params:                "params.yaml",
+			PatchesStrategicMerge: []string{"patch.yaml"},
+		},
+	}
+
+	lock, err := newBuildLock("config.yaml", config)
+	assert.Nil(t, err)
+	assert.Empty(t, lock.ManifestsSource, "there is no source config")
+	assert.NotEmpty(t, lock.ConfigHash)
+
+	lock.BuildTime = "2021-01-01T00:00:00Z"
+	assert.Nil(t, lock.Save(opConfig.LockFilePath))
+
+	unchanged, err := newBuildLock("config.yaml", config)
+	assert.Nil(t, err)
+	assert.Equal(t, "2021-01-01T00:00:00Z", unchanged.BuildTime, "the build time is kept while the inputs don't change")
+
+	assert.Nil(t, ioutil.WriteFile("patch.yaml", []byte("kind: StatefulSet\n"), 0644))
+	changed, err := newBuildLock("config.yaml", config)
+	assert.Nil(t, err)
+	assert.NotEqual(t, lock.ConfigHash, changed.ConfigHash, "the patches are part of the config hash")
+	assert.NotEqual(t, "2021-01-01T00:00:00Z", changed.BuildTime)
+	assert.NotNil(t, checkLock(changed))
+
+	assert.Nil(t, os.MkdirAll(".onepanel", os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(".onepanel", "cli_config.yaml"), []byte("manifestSource: ["), 0644))
+	_, err = newBuildLock("config.yaml", config)
+	assert.NotNil(t, err, "an invalid source config is an error")
+}
+
+func Test_stampProvenance(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	assert.Nil(t, fSys.WriteFile("/build/configmap.yaml", []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: onepanel\n  annotations:\n    existing: value\n")))
+	assert.Nil(t, fSys.WriteFile("/build/kustomization.yaml", []byte("resources:\n- configmap.yaml\n")))
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, "/build")
+	assert.Nil(t, err)
+
+	lock := &opConfig.Lock{
+		CLIVersion:    "v1.0.0",
+		ManifestsHash: "0123456789abcdef",
+		ParamsHash:    "params",
+		BuildTime:     "2021-01-01T00:00:00Z",
+	}
+	assert.Nil(t, stampProvenance(rm, lock))
+
+	annotations := rm.Resources()[0].GetAnnotations()
+	assert.Equal(t, "value", annotations["existing"])
+	assert.Equal(t, "v1.0.0", annotations[annotationCLIVersion])
+	assert.Equal(t, "sha256:0123456789ab", annotations[annotationManifestsVersion], "a directory source is identified by the manifests hash")
+	assert.Equal(t, "params", annotations[annotationParamsHash])
+	assert.Equal(t, "2021-01-01T00:00:00Z", annotations[annotationBuildTime])
+
+	lock.ManifestsVersion = "v0.19.0"
+	assert.Nil(t, stampProvenance(rm, lock))
+	assert.Equal(t, "v0.19.0", rm.Resources()[0].GetAnnotations()[annotationManifestsVersion])
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"time"
 
+	"github.com/onepanelio/cli/logging"
+	"github.com/onepanelio/cli/output"
 	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -27,6 +29,23 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		format, err := output.ParseFormat(outputFlag)
+		if err != nil {
+			return usageError("%v", err.Error())
+		}
+		outputFormat = format
+
+		if err := configureLogging(); err != nil {
+			return err
+		}
+
+		if err := configureCluster(); err != nil {
+			return err
+		}
+
+		return configureContext()
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -34,9 +53,23 @@ to quickly create a Cobra application.`,
 func Execute() {
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil {
+		err = stoppedError(err)
+	}
+	cancelCommand()
+	defer logging.Default().Close()
+	if err != nil {
+		code := exitCode(err)
+		logging.Errorf("%v", err.Error())
+		if structuredOutput() && !documentPrinted {
+			printDocument(&errorDocument{Error: err.Error(), ExitCode: code})
+		}
+		if code == ExitCodeUsage {
+			fmt.Fprintf(os.Stderr, "Run '%v --help' for usage.\n", cmd.CommandPath())
+		}
+		logging.Default().Close()
+		os.Exit(code)
 	}
 }
 
@@ -48,6 +81,18 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cli.yaml)")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "Shows debug messages. Twice, as in -vv, shows every Kubernetes API call as well.")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only shows errors.")
+	rootCmd.PersistentFlags().StringVarP(&logFile, "log-file", "", "", "Appends every message, including every Kubernetes API call, with timestamps to this file. Use it to find out why an install failed.")
+	rootCmd.PersistentFlags().StringVarP(&kubeconfigFlag, "kubeconfig", "", "", "Kubeconfig file to connect with. Defaults to KUBECONFIG, then ~/.kube/config, unless config.yaml sets spec.cluster.kubeconfig.")
+	rootCmd.PersistentFlags().StringVarP(&contextFlag, "context", "", "", "Kubeconfig context to connect with. Defaults to the current context, unless config.yaml sets spec.cluster.context.")
+	rootCmd.PersistentFlags().StringVarP(&clusterFlag, "cluster", "", "", "Kubeconfig cluster to connect to. Defaults to the cluster of the context. Only that cluster is allowed if config.yaml sets spec.cluster.")
+	rootCmd.PersistentFlags().DurationVarP(&commandTimeout, "timeout", "", 0, "Stops the command once this much time elapses, like 10m. Defaults to no timeout.")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", string(output.FormatText), "Output format, text, json or yaml. json and yaml print one document to stdout.")
+
+	// Errors are printed by Execute, with the usage hint only for usage errors
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -7,16 +7,35 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// versionDocument is the structured output of version
+type versionDocument struct {
+	CLIVersion       string `json:"cliVersion"`
+	ManifestsVersion string `json:"manifestsVersion"`
+	APIVersion       string `json:"apiVersion"`
+	WebUIVersion     string `json:"webUIVersion"`
+}
+
 var versionCmd = &cobra.Command{
 	Use:     "version",
 	Short:   "Returns the current version of the CLI",
 	Long:    "Returns the current version of the CLI",
 	Example: "version",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if structuredOutput() {
+			return printDocument(&versionDocument{
+				CLIVersion:       config.CLIVersion,
+				ManifestsVersion: config.ManifestsRepositoryTag,
+				APIVersion:       config.CoreImageTag,
+				WebUIVersion:     config.CoreUIImageTag,
+			})
+		}
+
 		fmt.Printf("CLI version: %v\n", config.CLIVersion)
 		fmt.Printf("Manifest version: %v\n", config.ManifestsRepositoryTag)
 		fmt.Printf("API version: %v\n", config.CoreImageTag)
 		fmt.Printf("Web UI version: %v\n", config.CoreUIImageTag)
+
+		return nil
 	},
 }
 
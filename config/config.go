@@ -6,6 +6,7 @@ import (
 	"github.com/onepanelio/cli/files"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -57,6 +58,32 @@ type ConfigSpec struct {
 	Params        string   `yaml:"params"`
 	Components    []string `yaml:"components"`
 	Overlays      []string `yaml:"overlays"`
+
+	// The following are passed through to the generated kustomization.yaml.
+	// File paths are relative to the config file.
+	PatchesStrategicMerge []string          `yaml:"patchesStrategicMerge,omitempty"`
+	PatchesJson6902       []PatchJson6902   `yaml:"patchesJson6902,omitempty"`
+	Images                []Image           `yaml:"images,omitempty"`
+	CommonLabels          map[string]string `yaml:"commonLabels,omitempty"`
+	CommonAnnotations     map[string]string `yaml:"commonAnnotations,omitempty"`
+	Namespace             string            `yaml:"namespace,omitempty"`
+
+	// ExtraComponents are kustomize directories, outside of the manifests, deployed along with onepanel.
+	// Paths are relative to the config file.
+	ExtraComponents []string `yaml:"extraComponents,omitempty"`
+
+	// ImageRegistry if set, every image of the deployment is pulled from this registry instead
+	ImageRegistry *ImageRegistry `yaml:"imageRegistry,omitempty"`
+
+	// Policy configures the checks build and apply run on the built resources
+	Policy *Policy `yaml:"policy,omitempty"`
+
+	// SecretParams are params, like artifactRepository.s3.secretKey, whose values are redacted from build output and logs.
+	// They are added to the params opctl knows are secret.
+	SecretParams []string `yaml:"secretParams,omitempty"`
+
+	// Cluster if set, pins the deployment to a cluster. The --kubeconfig and --context flags can't select another one.
+	Cluster *Cluster `yaml:"cluster,omitempty"`
 }
 
 // HasComponent checks if the config spec has any component with the exact name given
@@ -93,11 +120,60 @@ func FromFile(path string) (config *Config, err error) {
 		return
 	}
 
+	if err = config.resolvePaths(filepath.Dir(path)); err != nil {
+		return
+	}
+
 	err = config.Validate()
 
 	return
 }
 
+// resolvePaths makes the file paths of kustomize settings absolute, resolving relative paths from directory.
+func (c *Config) resolvePaths(directory string) error {
+	resolve := func(path string) (string, error) {
+		if filepath.IsAbs(path) {
+			return path, nil
+		}
+
+		return filepath.Abs(filepath.Join(directory, path))
+	}
+
+	for i, patch := range c.Spec.PatchesStrategicMerge {
+		resolved, err := resolve(patch)
+		if err != nil {
+			return err
+		}
+		c.Spec.PatchesStrategicMerge[i] = resolved
+	}
+
+	for i := range c.Spec.PatchesJson6902 {
+		resolved, err := resolve(c.Spec.PatchesJson6902[i].Path)
+		if err != nil {
+			return err
+		}
+		c.Spec.PatchesJson6902[i].Path = resolved
+	}
+
+	for i, component := range c.Spec.ExtraComponents {
+		resolved, err := resolve(component)
+		if err != nil {
+			return err
+		}
+		c.Spec.ExtraComponents[i] = resolved
+	}
+
+	if c.Spec.Cluster != nil && c.Spec.Cluster.Kubeconfig != "" {
+		resolved, err := resolve(c.Spec.Cluster.Kubeconfig)
+		if err != nil {
+			return err
+		}
+		c.Spec.Cluster.Kubeconfig = resolved
+	}
+
+	return nil
+}
+
 // Checks the config to make sure all the set files exist, etc.
 // Errors are returned in a human friendly format, and can be printed to stdout.
 func (c *Config) Validate() error {
@@ -117,6 +193,82 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("configuration file error: the parameters file does not exist at %v", c.Spec.Params)
 	}
 
+	patchPaths := append([]string{}, c.Spec.PatchesStrategicMerge...)
+	for _, patch := range c.Spec.PatchesJson6902 {
+		patchPaths = append(patchPaths, patch.Path)
+	}
+
+	for _, patchPath := range patchPaths {
+		patchExists, err := files.Exists(patchPath)
+		if err != nil {
+			return fmt.Errorf("unable to check if file exists at %v", patchPath)
+		}
+		if !patchExists {
+			return fmt.Errorf("configuration file error: the patch file does not exist at %v", patchPath)
+		}
+	}
+
+	// Extra components are copied into the build cache by directory name, so names must be unique
+	extraComponentNames := make(map[string]string)
+	for _, component := range c.Spec.ExtraComponents {
+		info, err := os.Stat(component)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("configuration file error: the extra component does not exist at %v", component)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to check if directory exists at %v", component)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("configuration file error: the extra component at %v is not a directory", component)
+		}
+
+		name := filepath.Base(component)
+		if other, ok := extraComponentNames[name]; ok {
+			return fmt.Errorf("configuration file error: the extra components at %v and %v have the same directory name", other, component)
+		}
+		extraComponentNames[name] = component
+	}
+
+	if registry := c.Spec.ImageRegistry; registry != nil {
+		if registry.URL == "" {
+			return fmt.Errorf("configuration file error: imageRegistry.url is required")
+		}
+		if strings.Contains(registry.URL, "://") {
+			return fmt.Errorf("configuration file error: imageRegistry.url should not have a scheme, like %v", registry.URL[strings.Index(registry.URL, "://")+3:])
+		}
+	}
+
+	if policy := c.Spec.Policy; policy != nil {
+		for rule, severity := range policy.Rules {
+			if severity != PolicySeverityError && severity != PolicySeverityWarning && severity != PolicySeverityOff {
+				return fmt.Errorf("configuration file error: policy.rules.%v is '%v', expected %v, %v or %v", rule, severity, PolicySeverityError, PolicySeverityWarning, PolicySeverityOff)
+			}
+		}
+		for i, exclusion := range policy.Exclude {
+			if exclusion.Rule == "" {
+				return fmt.Errorf("configuration file error: policy.exclude[%v].rule is required", i)
+			}
+		}
+	}
+
+	if cluster := c.Spec.Cluster; cluster != nil {
+		if cluster.Kubeconfig == "" && cluster.Context == "" && cluster.Server == "" {
+			return fmt.Errorf("configuration file error: cluster needs a kubeconfig, context or server")
+		}
+		if cluster.Kubeconfig != "" {
+			kubeconfigExists, err := files.Exists(cluster.Kubeconfig)
+			if err != nil {
+				return fmt.Errorf("unable to check if file exists at %v", cluster.Kubeconfig)
+			}
+			if !kubeconfigExists {
+				return fmt.Errorf("configuration file error: the kubeconfig file does not exist at %v", cluster.Kubeconfig)
+			}
+		}
+		if cluster.Server != "" && !strings.Contains(cluster.Server, "://") {
+			return fmt.Errorf("configuration file error: cluster.server should have a scheme, like https://%v", cluster.Server)
+		}
+	}
+
 	return nil
 }
 
@@ -132,12 +284,17 @@ func (c *Config) GetOverlayComponents(skipOverlayComponent string) []*SimpleOver
 	overlayedComponents := make([]*SimpleOverlayedComponent, 0)
 
 	mappedComponents := make(map[string]*SimpleOverlayedComponent)
+	// The components are returned in the order of the spec, so the build output is the same between runs
+	orderedNames := make([]string, 0)
 
 	for _, component := range c.Spec.Components {
 		if component == skipOverlayComponent {
 			continue
 		}
 		formattedName := strings.TrimSuffix(component, string(os.PathSeparator)+"base")
+		if _, ok := mappedComponents[formattedName]; !ok {
+			orderedNames = append(orderedNames, formattedName)
+		}
 		mappedComponents[formattedName] = CreateSimpleOverlayedComponent(component)
 	}
 
@@ -151,7 +308,7 @@ func (c *Config) GetOverlayComponents(skipOverlayComponent string) []*SimpleOver
 		}
 	}
 
-	for key := range mappedComponents {
+	for _, key := range orderedNames {
 		overlayedComponents = append(overlayedComponents, mappedComponents[key])
 	}
 
@@ -163,11 +320,13 @@ func (c *Config) GetOverlayComponent(overlayComponentGet string) []*SimpleOverla
 	overlayedComponents := make([]*SimpleOverlayedComponent, 0)
 
 	mappedComponents := make(map[string]*SimpleOverlayedComponent)
+	orderedNames := make([]string, 0)
 
 	for _, component := range c.Spec.Components {
 		if overlayComponentGet == component {
 			formattedName := strings.TrimSuffix(component, string(os.PathSeparator)+"base")
 			mappedComponents[formattedName] = CreateSimpleOverlayedComponent(component)
+			orderedNames = append(orderedNames, formattedName)
 			break
 		}
 	}
@@ -182,7 +341,7 @@ func (c *Config) GetOverlayComponent(overlayComponentGet string) []*SimpleOverla
 		}
 	}
 
-	for key := range mappedComponents {
+	for _, key := range orderedNames {
 		overlayedComponents = append(overlayedComponents, mappedComponents[key])
 	}
 
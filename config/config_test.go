@@ -0,0 +1,79 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_resolvePaths(t *testing.T) {
+	directory, err := ioutil.TempDir("", "opctl-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+
+	c := &Config{
+		Spec: ConfigSpec{
+			PatchesStrategicMerge: []string{"patches/replicas.yaml", "/absolute/replicas.yaml"},
+			PatchesJson6902:       []PatchJson6902{{Path: "../patch.yaml"}},
+			ExtraComponents:       []string{"./extra/monitoring"},
+			Cluster:               &Cluster{Kubeconfig: "kubeconfig"},
+		},
+	}
+
+	assert.Nil(t, c.resolvePaths(directory))
+	assert.Equal(t, []string{filepath.Join(directory, "patches", "replicas.yaml"), "/absolute/replicas.yaml"}, c.Spec.PatchesStrategicMerge)
+	assert.Equal(t, filepath.Join(filepath.Dir(directory), "patch.yaml"), c.Spec.PatchesJson6902[0].Path)
+	assert.Equal(t, []string{filepath.Join(directory, "extra", "monitoring")}, c.Spec.ExtraComponents)
+	assert.Equal(t, filepath.Join(directory, "kubeconfig"), c.Spec.Cluster.Kubeconfig)
+}
+
+func TestConfig_Validate_extraComponents(t *testing.T) {
+	directory, err := ioutil.TempDir("", "opctl-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+
+	params := filepath.Join(directory, "params.yaml")
+	assert.Nil(t, ioutil.WriteFile(params, []byte{}, 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(directory, "a", "monitoring"), os.ModePerm))
+	assert.Nil(t, os.MkdirAll(filepath.Join(directory, "b", "monitoring"), os.ModePerm))
+	assert.Nil(t, os.MkdirAll(filepath.Join(directory, "b", "logging"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(directory, "file.yaml"), []byte{}, 0644))
+
+	tests := []struct {
+		name            string
+		extraComponents []string
+		wantErr         string
+	}{
+		{"none", nil, ""},
+		{"distinct names", []string{"a/monitoring", "b/logging"}, ""},
+		{"missing directory", []string{"a/missing"}, "the extra component does not exist"},
+		{"not a directory", []string{"file.yaml"}, "is not a directory"},
+		{"duplicate directory name", []string{"a/monitoring", "b/monitoring"}, "have the same directory name"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Config{
+				Spec: ConfigSpec{
+					ManifestsRepo: directory,
+					Params:        params,
+				},
+			}
+			for _, component := range test.extraComponents {
+				c.Spec.ExtraComponents = append(c.Spec.ExtraComponents, filepath.Join(directory, component))
+			}
+
+			err := c.Validate()
+			if test.wantErr == "" {
+				assert.Nil(t, err)
+				return
+			}
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), test.wantErr)
+			}
+		})
+	}
+}
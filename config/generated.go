@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/onepanelio/cli/files"
+)
+
+// GeneratedValuesFilePath is where build persists the values it generates, like database passwords
+var GeneratedValuesFilePath = filepath.Join(".onepanel", "generated.yaml")
+
+// GeneratedValues are values generated at random for a deployment, like passwords and secret keys.
+// They are persisted, so building again gives the same output instead of rotating them.
+type GeneratedValues struct {
+	path    string
+	values  map[string]string
+	changed bool
+}
+
+// NewGeneratedValues returns an empty store that is not persisted
+func NewGeneratedValues() *GeneratedValues {
+	return &GeneratedValues{
+		values: make(map[string]string),
+	}
+}
+
+// LoadGeneratedValues loads the store persisted at path. If there is no file yet, the store is empty.
+func LoadGeneratedValues(path string) (*GeneratedValues, error) {
+	g := NewGeneratedValues()
+	g.path = path
+
+	exists, err := files.Exists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return g, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &g.values); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", path, err.Error())
+	}
+	if g.values == nil {
+		g.values = make(map[string]string)
+	}
+
+	return g, nil
+}
+
+// Get returns the value stored for key. If there is none, one is created with generate and stored.
+func (g *GeneratedValues) Get(key string, generate func() (string, error)) (string, error) {
+	if value, ok := g.values[key]; ok {
+		return value, nil
+	}
+
+	value, err := generate()
+	if err != nil {
+		return "", err
+	}
+
+	g.values[key] = value
+	g.changed = true
+
+	return value, nil
+}
+
+// Values returns the values in the store, so they can be redacted from output
+func (g *GeneratedValues) Values() []string {
+	result := make([]string, 0, len(g.values))
+	for _, value := range g.values {
+		result = append(result, value)
+	}
+
+	return result
+}
+
+// Save writes the store to the file it was loaded from, if a value was generated since.
+// The file is only readable by the user, as it has secrets.
+func (g *GeneratedValues) Save() error {
+	if g.path == "" || !g.changed {
+		return nil
+	}
+
+	data, err := yaml.Marshal(g.values)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(g.path), 0755); err != nil {
+		return err
+	}
+
+	if err := files.WriteFileAtomic(g.path, data, 0600); err != nil {
+		return err
+	}
+
+	g.changed = false
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratedValues_SaveLoad(t *testing.T) {
+	directory, err := ioutil.TempDir("", "opctl-generated")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, ".onepanel", "generated.yaml")
+
+	generated, err := LoadGeneratedValues(path)
+	assert.Nil(t, err)
+	assert.Empty(t, generated.Values(), "there is no file yet")
+
+	password, err := generated.Get("database.password", func() (string, error) {
+		return "generated-password", nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "generated-password", password)
+	assert.Nil(t, generated.Save())
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	loaded, err := LoadGeneratedValues(path)
+	assert.Nil(t, err)
+	password, err = loaded.Get("database.password", func() (string, error) {
+		return "", errors.New("the saved value is reused")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "generated-password", password)
+	assert.Equal(t, []string{"generated-password"}, loaded.Values())
+}
+
+func TestGeneratedValues_SaveUnchanged(t *testing.T) {
+	directory, err := ioutil.TempDir("", "opctl-generated")
+	assert.Nil(t, err)
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "generated.yaml")
+
+	generated, err := LoadGeneratedValues(path)
+	assert.Nil(t, err)
+	assert.Nil(t, generated.Save())
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "nothing was generated, so nothing is written")
+
+	assert.Nil(t, NewGeneratedValues().Save(), "a store that was not loaded is not persisted")
+}
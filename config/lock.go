@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"github.com/onepanelio/cli/files"
+)
+
+// LockFilePath is where build records the inputs of the build
+const LockFilePath = "opctl.lock"
+
+// Lock records the exact inputs of a build, so a later apply can check nothing has drifted since
+type Lock struct {
+	CLIVersion       string `json:"cliVersion"`
+	ManifestsSource  string `json:"manifestsSource"`  // type of the manifests source, like github
+	ManifestsVersion string `json:"manifestsVersion"` // tag of the manifests, empty for a directory source
+	ManifestsHash    string `json:"manifestsHash"`    // sha256 of the manifests directory
+	ParamsHash       string `json:"paramsHash"`       // sha256 of the params file
+	ConfigHash       string `json:"configHash"`       // sha256 of the config file, and the patches and extra components it references
+	CoreImageTag     string `json:"coreImageTag"`
+	CoreUIImageTag   string `json:"coreUIImageTag"`
+	BuildTime        string `json:"buildTime"` // RFC 3339, kept while the other fields do not change
+}
+
+// LoadLock reads the lock file at path. If there is no file, nil is returned.
+func LoadLock(path string) (*Lock, error) {
+	exists, err := files.Exists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", path, err.Error())
+	}
+
+	return lock, nil
+}
+
+// Save writes the lock file to path, atomically so an interrupted build never leaves a partial lock
+func (l *Lock) Save(path string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	return files.WriteFileAtomic(path, data, 0644)
+}
+
+// Drift returns a description of each input that differs between l and other. The build time is not compared.
+func (l *Lock) Drift(other *Lock) []string {
+	fields := []struct {
+		name          string
+		locked, other string
+	}{
+		{"cliVersion", l.CLIVersion, other.CLIVersion},
+		{"manifestsSource", l.ManifestsSource, other.ManifestsSource},
+		{"manifestsVersion", l.ManifestsVersion, other.ManifestsVersion},
+		{"manifestsHash", l.ManifestsHash, other.ManifestsHash},
+		{"paramsHash", l.ParamsHash, other.ParamsHash},
+		{"configHash", l.ConfigHash, other.ConfigHash},
+		{"coreImageTag", l.CoreImageTag, other.CoreImageTag},
+		{"coreUIImageTag", l.CoreUIImageTag, other.CoreUIImageTag},
+	}
+
+	result := make([]string, 0)
+	for _, field := range fields {
+		if field.locked != field.other {
+			result = append(result, fmt.Sprintf("%v is '%v', locked to '%v'", field.name, field.other, field.locked))
+		}
+	}
+
+	return result
+}
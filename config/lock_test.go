@@ -0,0 +1,31 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLock_Drift(t *testing.T) {
+	locked := &Lock{
+		CLIVersion:     "v1.0.0",
+		ManifestsHash:  "abc",
+		ParamsHash:     "def",
+		ConfigHash:     "ghi",
+		CoreImageTag:   "v1.0.0",
+		CoreUIImageTag: "v1.0.0",
+		BuildTime:      "2021-01-01T00:00:00Z",
+	}
+
+	same := *locked
+	same.BuildTime = "2021-02-01T00:00:00Z"
+	assert.Empty(t, locked.Drift(&same), "the build time is not compared")
+
+	changed := *locked
+	changed.ConfigHash = "jkl"
+	changed.CoreImageTag = "v1.0.1"
+	assert.Equal(t, []string{
+		"configHash is 'jkl', locked to 'ghi'",
+		"coreImageTag is 'v1.0.1', locked to 'v1.0.0'",
+	}, locked.Drift(&changed))
+}
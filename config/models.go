@@ -28,3 +28,72 @@ type Database struct {
 type DatabaseWrapper struct {
 	Database *Database `yaml:"database"`
 }
+
+// PatchTarget selects the resource a JSON 6902 patch applies to
+type PatchTarget struct {
+	Group     string `yaml:"group,omitempty"`
+	Version   string `yaml:"version"`
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// PatchJson6902 is a JSON 6902 patch file, and the resource it applies to
+type PatchJson6902 struct {
+	Target PatchTarget `yaml:"target"`
+	Path   string      `yaml:"path"`
+}
+
+// Image overrides the name, tag or digest of a container image
+type Image struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName,omitempty"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// ImageRegistry is a registry that mirrors the images of the deployment, for clusters that can only pull from it
+type ImageRegistry struct {
+	// URL is the registry, and optional path, images are rewritten to. For example, registry.example.com/onepanel
+	URL string `yaml:"url"`
+	// Overrides maps an image name, without a tag, to the name to use instead of the rewritten one
+	Overrides map[string]string `yaml:"overrides,omitempty"`
+	// PullSecrets are the names of the secrets pods pull the images with
+	PullSecrets []string `yaml:"pullSecrets,omitempty"`
+}
+
+// Severities of a policy rule
+const (
+	// PolicySeverityError fails build and apply
+	PolicySeverityError = "error"
+	// PolicySeverityWarning is reported, without failing
+	PolicySeverityWarning = "warning"
+	// PolicySeverityOff turns the rule off
+	PolicySeverityOff = "off"
+)
+
+// Policy configures the rules checked on the built resources
+type Policy struct {
+	// Rules maps the name of a rule to its severity. Rules not listed have their default severity.
+	Rules map[string]string `yaml:"rules,omitempty"`
+	// Exclude lists resources rules do not check
+	Exclude []PolicyExclusion `yaml:"exclude,omitempty"`
+}
+
+// PolicyExclusion stops a rule from checking the resources it matches. Empty fields match any value.
+type PolicyExclusion struct {
+	Rule      string `yaml:"rule"`
+	Kind      string `yaml:"kind,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Name      string `yaml:"name,omitempty"`
+}
+
+// Cluster pins a deployment to its cluster, so commands can't use another one by accident
+type Cluster struct {
+	// Kubeconfig is the kubeconfig file to connect with, relative to the config file
+	Kubeconfig string `yaml:"kubeconfig,omitempty"`
+	// Context is the kubeconfig context to connect with
+	Context string `yaml:"context,omitempty"`
+	// Server if set, commands refuse to connect to another API server, like https://10.0.0.1:6443
+	Server string `yaml:"server,omitempty"`
+}
@@ -0,0 +1,159 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// ClusterDirectory is the directory resources without a namespace, like ClusterRoles and CRDs, are written to
+const ClusterDirectory = "_cluster"
+
+// KustomizationHeader is the first line of the kustomization.yaml Write generates.
+// It marks the directory as generated, so files from a previous export can safely be removed.
+const KustomizationHeader = "# Generated by opctl build --output-dir. Changes will be overwritten."
+
+// unsafeFileCharacters are the characters that are replaced in file names, like the ':' in system:controller
+var unsafeFileCharacters = regexp.MustCompile(`[^a-z0-9._-]`)
+
+// kustomization is the kustomization.yaml written with the exported resources
+type kustomization struct {
+	ApiVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Resources  []string `yaml:"resources"`
+}
+
+// FilePath returns the path, relative to the export directory, of the file for the resource.
+// For example, onepanel/deployment-onepanel-core.yaml
+func FilePath(r *resource.Resource) string {
+	namespace := r.GetNamespace()
+	if namespace == "" {
+		namespace = ClusterDirectory
+	}
+
+	name := strings.ToLower(fmt.Sprintf("%v-%v", r.GetKind(), r.GetName()))
+
+	return filepath.Join(unsafeFileCharacters.ReplaceAllString(strings.ToLower(namespace), "-"), unsafeFileCharacters.ReplaceAllString(name, "-")+".yaml")
+}
+
+// GroupFilePath is like FilePath, with the API group in the file name.
+// It tells apart resources with the same kind and name in different API groups, like two Certificate kinds.
+func GroupFilePath(r *resource.Resource) string {
+	gvk := r.GetGvk()
+	name := strings.ToLower(fmt.Sprintf("%v.%v-%v", gvk.Kind, gvk.Group, r.GetName()))
+
+	return filepath.Join(filepath.Dir(FilePath(r)), unsafeFileCharacters.ReplaceAllString(name, "-")+".yaml")
+}
+
+// Write writes each resource in rm to its own file under dir, see FilePath, along with a kustomization.yaml listing them.
+// Files listed by the kustomization.yaml of a previous export, that are no longer generated, are removed.
+// To avoid removing files that are not generated, Write refuses to export to a directory with a kustomization.yaml it did not create.
+// The paths of the written files, relative to dir, are returned.
+func Write(fSys filesys.FileSystem, dir string, rm resmap.ResMap) ([]string, error) {
+	previousFiles, err := readPreviousExport(fSys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fSys.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+
+	written := make(map[string]bool)
+	paths := make([]string, 0)
+	for _, r := range rm.Resources() {
+		path := FilePath(r)
+		if written[path] {
+			path = GroupFilePath(r)
+		}
+		if written[path] {
+			return nil, fmt.Errorf("more than one resource would be written to %v", path)
+		}
+
+		content, err := r.AsYAML()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := fSys.MkdirAll(filepath.Join(dir, filepath.Dir(path))); err != nil {
+			return nil, err
+		}
+
+		if err := fSys.WriteFile(filepath.Join(dir, path), content); err != nil {
+			return nil, err
+		}
+
+		written[path] = true
+		paths = append(paths, path)
+	}
+
+	for _, previousFile := range previousFiles {
+		if written[previousFile] {
+			continue
+		}
+
+		if err := fSys.RemoveAll(filepath.Join(dir, previousFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(paths)
+	k := kustomization{
+		ApiVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+		Resources:  paths,
+	}
+	data, err := yaml.Marshal(k)
+	if err != nil {
+		return nil, err
+	}
+
+	content := append([]byte(KustomizationHeader+"\n"), data...)
+	if err := fSys.WriteFile(filepath.Join(dir, "kustomization.yaml"), content); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// readPreviousExport returns the resources listed by the kustomization.yaml in dir, if it was generated by Write.
+func readPreviousExport(fSys filesys.FileSystem, dir string) ([]string, error) {
+	kustomizationPath := filepath.Join(dir, "kustomization.yaml")
+	if !fSys.Exists(kustomizationPath) {
+		return []string{}, nil
+	}
+
+	content, err := fSys.ReadFile(kustomizationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(content, []byte(KustomizationHeader)) {
+		return nil, fmt.Errorf("%v was not generated by opctl, refusing to overwrite it", kustomizationPath)
+	}
+
+	previous := &kustomization{}
+	if err := yaml.Unmarshal(content, previous); err != nil {
+		return nil, err
+	}
+
+	// Only remove files inside of dir
+	result := make([]string, 0)
+	for _, path := range previous.Resources {
+		cleaned := filepath.Clean(path)
+		if filepath.IsAbs(cleaned) || strings.HasPrefix(cleaned, "..") {
+			continue
+		}
+		result = append(result, cleaned)
+	}
+
+	return result, nil
+}
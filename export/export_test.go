@@ -0,0 +1,77 @@
+package export
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+const (
+	resources = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: onepanel
+  namespace: onepanel
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: system:onepanel
+`
+)
+
+func buildResMap(t *testing.T, content string) resmap.ResMap {
+	fSys := filesys.MakeFsInMemory()
+	assert.Nil(t, fSys.WriteFile("/build/resources.yaml", []byte(content)))
+	assert.Nil(t, fSys.WriteFile("/build/kustomization.yaml", []byte("resources:\n- resources.yaml\n")))
+
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, "/build")
+	assert.Nil(t, err)
+
+	return rm
+}
+
+func TestWrite(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	dir := "/output"
+
+	paths, err := Write(fSys, dir, buildResMap(t, resources))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join("_cluster", "clusterrole-system-onepanel.yaml"), filepath.Join("onepanel", "configmap-onepanel.yaml")}, paths)
+	assert.True(t, fSys.Exists(filepath.Join(dir, "onepanel", "configmap-onepanel.yaml")))
+
+	kustomization, err := fSys.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(kustomization), KustomizationHeader)
+}
+
+func TestWrite_RemovesStaleFiles(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	dir := "/output"
+	assert.Nil(t, fSys.WriteFile(filepath.Join(dir, "README.md"), []byte("not generated")))
+
+	_, err := Write(fSys, dir, buildResMap(t, resources))
+	assert.Nil(t, err)
+
+	_, err = Write(fSys, dir, buildResMap(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: onepanel\n  namespace: onepanel\n"))
+	assert.Nil(t, err)
+
+	assert.False(t, fSys.Exists(filepath.Join(dir, "_cluster", "clusterrole-system-onepanel.yaml")))
+	assert.True(t, fSys.Exists(filepath.Join(dir, "onepanel", "configmap-onepanel.yaml")))
+	assert.True(t, fSys.Exists(filepath.Join(dir, "README.md")))
+}
+
+func TestWrite_RefusesUnknownKustomization(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	dir := "/output"
+	assert.Nil(t, fSys.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: []\n")))
+
+	_, err := Write(fSys, dir, buildResMap(t, resources))
+
+	assert.NotNil(t, err)
+}
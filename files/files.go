@@ -194,6 +194,37 @@ func CopyDirChildren(src string, dst string) (err error) {
 	return
 }
 
+// WriteFileAtomic writes data to the file at path, through a temporary file in the same directory renamed over it.
+// If writing is interrupted, the file keeps its previous content, instead of being left half-written.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	temporary, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(temporary.Name())
+		}
+	}()
+
+	if _, err = temporary.Write(data); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err = temporary.Sync(); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err = temporary.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(temporary.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary.Name(), path)
+}
+
 // DeleteIfExists will delete a file if it exists. If it doesn't, nothing happens.
 // Returns if the file existed. If there was an error, existed is set to false, and err is set.
 func DeleteIfExists(path string) (existed bool, err error) {
@@ -0,0 +1,47 @@
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+// CopyDirToFileSystem recursively copies the directory tree at src, on disk, to dst in fSys.
+// This is usually used to load files into an in-memory file system, so they can be changed without touching the originals.
+// .git directories and symlinks are skipped.
+func CopyDirToFileSystem(src string, fSys filesys.FileSystem, dst string) error {
+	src = filepath.Clean(src)
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		destinationPath := filepath.Join(dst, relativePath)
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return fSys.MkdirAll(destinationPath)
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return fSys.WriteFile(destinationPath, content)
+	})
+}
@@ -0,0 +1,69 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// HashFile returns the hex encoded sha256 of the file at path
+func HashFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HashDirectory returns the hex encoded sha256 of the directory tree at root, covering the path and content of each file.
+// Like CopyDirToFileSystem, .git directories and symlinks are skipped, so the hash matches what is built.
+func HashDirectory(root string) (string, error) {
+	root = filepath.Clean(root)
+	hash := sha256.New()
+
+	// filepath.Walk goes through the files in lexical order, so the hash is stable
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		contentSum := sha256.Sum256(content)
+		hash.Write([]byte(filepath.ToSlash(relativePath)))
+		hash.Write([]byte{0})
+		hash.Write(contentSum[:])
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
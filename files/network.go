@@ -1,6 +1,7 @@
 package files
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,9 +11,10 @@ import (
 // DownloadFile will download a url to a local file.
 // The network request attaches the "onepanelio" user-agent to the request headers
 // This is important for certain sites like Github, otherwise you get a 403.
-func DownloadFile(filepath string, url string) error {
+// The download is canceled along with ctx.
+func DownloadFile(ctx context.Context, filepath string, url string) error {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return err
 	}
@@ -1,18 +1,29 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 )
 
+// ErrNotFound is returned when Github responds with a 404 for the requested resource
+var ErrNotFound = errors.New("not found")
+
 type Release struct {
-	Url        string `json:"url"`
-	Name       string `json:"name"`
-	TagName    string `json:"tag_name"`
-	CreatedAt  string `json:"created_at"`
-	TarBallUrl string `json:"tarball_url"`
-	ZipBallUrl string `json:"zipball_url"`
+	Url         string `json:"url"`
+	Name        string `json:"name"`
+	TagName     string `json:"tag_name"`
+	CreatedAt   string `json:"created_at"`
+	PublishedAt string `json:"published_at"`
+	Prerelease  bool   `json:"prerelease"`
+	TarBallUrl  string `json:"tarball_url"`
+	ZipBallUrl  string `json:"zipball_url"`
 }
 
 type Github struct {
@@ -23,11 +34,48 @@ func New(url string) (*Github, error) {
 	return &Github{repoUrl: url}, nil
 }
 
-func (g *Github) GetRelease(url string) (release *Release, err error) {
-	response, err := http.Get(url)
+// get runs a GET request against the Github API.
+// The "onepanelio" user-agent is attached, otherwise Github responds with a 403.
+// If GITHUB_TOKEN is set, it is used to authenticate the request, which raises the rate limit.
+// The request is canceled along with ctx.
+func (g *Github) get(ctx context.Context, url string, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("User-Agent", "onepanelio")
+	if accept != "" {
+		req.Header.Add("Accept", accept)
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Add("Authorization", "token "+token)
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if response.StatusCode > 399 {
+		response.Body.Close()
+		return nil, fmt.Errorf("github responded with %v for %v", response.StatusCode, url)
+	}
+
+	return response, nil
+}
+
+func (g *Github) GetRelease(ctx context.Context, url string) (release *Release, err error) {
+	response, err := g.get(ctx, url, "")
 	if err != nil {
 		return
 	}
+	defer response.Body.Close()
 
 	data, err := ioutil.ReadAll(response.Body)
 	if err != nil {
@@ -40,10 +88,68 @@ func (g *Github) GetRelease(url string) (release *Release, err error) {
 	return
 }
 
-func (g *Github) GetLatestRelease() (release *Release, err error) {
-	return g.GetRelease(g.repoUrl + "/releases/latest")
+func (g *Github) GetLatestRelease(ctx context.Context) (release *Release, err error) {
+	return g.GetRelease(ctx, g.repoUrl+"/releases/latest")
+}
+
+func (g *Github) GetReleaseByTag(ctx context.Context, tag string) (release *Release, err error) {
+	return g.GetRelease(ctx, g.repoUrl+"/releases/tags/"+tag)
+}
+
+// ListReleases returns one page of releases, newest first. Pages start at 1.
+// hasNextPage is true if Github reports there are more releases after this page.
+func (g *Github) ListReleases(ctx context.Context, page, perPage int) (releases []*Release, hasNextPage bool, err error) {
+	url := fmt.Sprintf("%v/releases?page=%v&per_page=%v", g.repoUrl, page, perPage)
+	response, err := g.get(ctx, url, "")
+	if err != nil {
+		return
+	}
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return
+	}
+
+	releases = make([]*Release, 0)
+	if err = json.Unmarshal(data, &releases); err != nil {
+		return
+	}
+
+	hasNextPage = strings.Contains(response.Header.Get("Link"), `rel="next"`)
+
+	return
+}
+
+// ListAllReleases goes through every page of releases and returns all of them, newest first.
+func (g *Github) ListAllReleases(ctx context.Context) ([]*Release, error) {
+	result := make([]*Release, 0)
+
+	for page := 1; ; page++ {
+		releases, hasNextPage, err := g.ListReleases(ctx, page, 100)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, releases...)
+
+		if !hasNextPage {
+			break
+		}
+	}
+
+	return result, nil
 }
 
-func (g *Github) GetReleaseByTag(tag string) (release *Release, err error) {
-	return g.GetRelease(g.repoUrl + "/releases/tags/" + tag)
+// GetFileContent returns the raw content of the file at path, as of ref. Ref can be a tag, branch or commit.
+// ErrNotFound is returned if the file does not exist.
+func (g *Github) GetFileContent(ctx context.Context, path, ref string) ([]byte, error) {
+	fileURL := fmt.Sprintf("%v/contents/%v?ref=%v", g.repoUrl, path, url.QueryEscape(ref))
+	response, err := g.get(ctx, fileURL, "application/vnd.github.v3.raw")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return ioutil.ReadAll(response.Body)
 }
@@ -0,0 +1,64 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithub_ListAllReleases(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "onepanelio", r.Header.Get("User-Agent"))
+
+		page := r.URL.Query().Get("page")
+		if page == "1" {
+			w.Header().Set("Link", fmt.Sprintf(`<%v/releases?page=2&per_page=100>; rel="next", <%v/releases?page=2&per_page=100>; rel="last"`, server.URL, server.URL))
+			w.Write([]byte(`[{"tag_name": "v0.19.0"}, {"tag_name": "v0.18.0"}]`))
+			return
+		}
+
+		w.Header().Set("Link", fmt.Sprintf(`<%v/releases?page=1&per_page=100>; rel="prev", <%v/releases?page=1&per_page=100>; rel="first"`, server.URL, server.URL))
+		w.Write([]byte(`[{"tag_name": "v0.17.0"}]`))
+	}))
+	defer server.Close()
+
+	githubAPI, err := New(server.URL)
+	assert.Nil(t, err)
+
+	releases, hasNextPage, err := githubAPI.ListReleases(context.Background(), 2, 100)
+	assert.Nil(t, err)
+	assert.False(t, hasNextPage)
+	assert.Len(t, releases, 1)
+
+	releases, err = githubAPI.ListAllReleases(context.Background())
+	assert.Nil(t, err)
+	tags := make([]string, 0)
+	for _, release := range releases {
+		tags = append(tags, release.TagName)
+	}
+	assert.Equal(t, []string{"v0.19.0", "v0.18.0", "v0.17.0"}, tags)
+}
+
+func TestGithub_GetFileContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	githubAPI, err := New(server.URL)
+	assert.Nil(t, err)
+
+	_, err = githubAPI.GetFileContent(context.Background(), "compatibility.yaml", "v0.19.0")
+	assert.Equal(t, ErrNotFound, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = githubAPI.GetFileContent(ctx, "compatibility.yaml", "v0.19.0")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+}
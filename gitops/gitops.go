@@ -0,0 +1,258 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+const (
+	// ToolArgoCD generates an Argo CD Application per phase
+	ToolArgoCD = "argocd"
+	// ToolFlux generates a Flux GitRepository, and a Flux Kustomization per phase
+	ToolFlux = "flux"
+)
+
+// Header is the first line of the files Write generates
+const Header = "# Generated by opctl build --gitops. Changes will be overwritten."
+
+// Options describe the git repository the exported phases are committed to
+type Options struct {
+	Name      string // prefix of the generated objects, like onepanel
+	RepoURL   string
+	Path      string // path of the export directory in the repository
+	Revision  string // branch the tools follow
+	Namespace string // namespace of the generated objects, defaults to the one the tool is installed in
+}
+
+// Tools returns the supported GitOps tools
+func Tools() []string {
+	return []string{ToolArgoCD, ToolFlux}
+}
+
+// FileName returns the name of the file Write generates for tool
+func FileName(tool string) string {
+	return tool + ".yaml"
+}
+
+// argoCDApplicationsDirectory is where the Applications of the phases are written for Argo CD, relative to the export directory.
+// The parent Application syncs them from there, so they are not synced along with the parent itself.
+const argoCDApplicationsDirectory = "argocd-applications"
+
+// Write generates the objects telling tool to deploy each phase, in order, and writes them to dir.
+// phases are the names of the directories under options.Path each phase was exported to.
+// The files are written next to the phases, not in them, so the tool does not deploy itself.
+// For Argo CD, FileName(tool) has the parent Application, and the Applications of the phases are under argocd-applications.
+// The paths of the written files, relative to dir, are returned.
+func Write(fSys filesys.FileSystem, dir string, tool string, phases []string, options Options) ([]string, error) {
+	if options.RepoURL == "" {
+		return nil, fmt.Errorf("a repository url is required")
+	}
+
+	files := make(map[string][]interface{})
+	switch tool {
+	case ToolArgoCD:
+		applicationsPath := path.Join(argoCDApplicationsDirectory, "applications.yaml")
+		files[FileName(tool)] = []interface{}{argoCDParentApplication(options)}
+		files[applicationsPath] = argoCDApplications(phases, options)
+	case ToolFlux:
+		files[FileName(tool)] = fluxKustomizations(phases, options)
+	default:
+		return nil, fmt.Errorf("unknown gitops tool '%v', expected %v or %v", tool, ToolArgoCD, ToolFlux)
+	}
+
+	paths := make([]string, 0)
+	for filePath := range files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	for _, filePath := range paths {
+		if err := writeObjects(fSys, filepath.Join(dir, filepath.FromSlash(filePath)), files[filePath]); err != nil {
+			return nil, err
+		}
+	}
+
+	return paths, nil
+}
+
+// writeObjects writes objects to filePath, unless it is a file opctl did not generate
+func writeObjects(fSys filesys.FileSystem, filePath string, objects []interface{}) error {
+	if fSys.Exists(filePath) {
+		content, err := fSys.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.HasPrefix(content, []byte(Header)) {
+			return fmt.Errorf("%v was not generated by opctl, refusing to overwrite it", filePath)
+		}
+	}
+
+	buffer := bytes.NewBufferString(Header + "\n")
+	for i, object := range objects {
+		if i > 0 {
+			buffer.WriteString("---\n")
+		}
+
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+	}
+
+	if err := fSys.MkdirAll(filepath.Dir(filePath)); err != nil {
+		return err
+	}
+
+	return fSys.WriteFile(filePath, buffer.Bytes())
+}
+
+// objectName returns the name of the generated object for the phase, like onepanel-application-base
+func objectName(options Options, phase string) string {
+	return options.Name + "-" + phase
+}
+
+// argoCDNamespace returns the namespace of the Applications
+func argoCDNamespace(options Options) string {
+	if options.Namespace == "" {
+		return "argocd"
+	}
+
+	return options.Namespace
+}
+
+// argoCDParentApplication returns the Application that syncs the Applications of the phases, the "app of apps" pattern.
+// Argo CD only honors the sync waves of Applications synced this way.
+func argoCDParentApplication(options Options) interface{} {
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "argoproj.io/v1alpha1"},
+		{Key: "kind", Value: "Application"},
+		{Key: "metadata", Value: yaml.MapSlice{
+			{Key: "name", Value: options.Name},
+			{Key: "namespace", Value: argoCDNamespace(options)},
+		}},
+		{Key: "spec", Value: yaml.MapSlice{
+			{Key: "project", Value: "default"},
+			{Key: "source", Value: yaml.MapSlice{
+				{Key: "repoURL", Value: options.RepoURL},
+				{Key: "targetRevision", Value: options.Revision},
+				{Key: "path", Value: path.Join(options.Path, argoCDApplicationsDirectory)},
+			}},
+			{Key: "destination", Value: yaml.MapSlice{
+				{Key: "server", Value: "https://kubernetes.default.svc"},
+				{Key: "namespace", Value: argoCDNamespace(options)},
+			}},
+			{Key: "syncPolicy", Value: yaml.MapSlice{
+				{Key: "automated", Value: yaml.MapSlice{
+					{Key: "prune", Value: true},
+					{Key: "selfHeal", Value: true},
+				}},
+			}},
+		}},
+	}
+}
+
+// argoCDApplications returns an Application per phase. Each phase is in a later sync wave than the one before it,
+// so the parent Application syncs a phase once the one before it is healthy.
+func argoCDApplications(phases []string, options Options) []interface{} {
+	namespace := argoCDNamespace(options)
+
+	result := make([]interface{}, 0)
+	for i, phase := range phases {
+		result = append(result, yaml.MapSlice{
+			{Key: "apiVersion", Value: "argoproj.io/v1alpha1"},
+			{Key: "kind", Value: "Application"},
+			{Key: "metadata", Value: yaml.MapSlice{
+				{Key: "name", Value: objectName(options, phase)},
+				{Key: "namespace", Value: namespace},
+				{Key: "annotations", Value: yaml.MapSlice{
+					{Key: "argocd.argoproj.io/sync-wave", Value: strconv.Itoa(i)},
+				}},
+			}},
+			{Key: "spec", Value: yaml.MapSlice{
+				{Key: "project", Value: "default"},
+				{Key: "source", Value: yaml.MapSlice{
+					{Key: "repoURL", Value: options.RepoURL},
+					{Key: "targetRevision", Value: options.Revision},
+					{Key: "path", Value: path.Join(options.Path, phase)},
+				}},
+				{Key: "destination", Value: yaml.MapSlice{
+					{Key: "server", Value: "https://kubernetes.default.svc"},
+				}},
+				{Key: "syncPolicy", Value: yaml.MapSlice{
+					{Key: "automated", Value: yaml.MapSlice{
+						{Key: "prune", Value: true},
+						{Key: "selfHeal", Value: true},
+					}},
+				}},
+			}},
+		})
+	}
+
+	return result
+}
+
+// fluxKustomizations returns a GitRepository for the repository, and a Kustomization per phase.
+// Each Kustomization depends on the one of the phase before it, so Flux waits for it to be ready.
+func fluxKustomizations(phases []string, options Options) []interface{} {
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = "flux-system"
+	}
+
+	result := []interface{}{
+		yaml.MapSlice{
+			{Key: "apiVersion", Value: "source.toolkit.fluxcd.io/v1beta1"},
+			{Key: "kind", Value: "GitRepository"},
+			{Key: "metadata", Value: yaml.MapSlice{
+				{Key: "name", Value: options.Name},
+				{Key: "namespace", Value: namespace},
+			}},
+			{Key: "spec", Value: yaml.MapSlice{
+				{Key: "interval", Value: "1m"},
+				{Key: "url", Value: options.RepoURL},
+				{Key: "ref", Value: yaml.MapSlice{
+					{Key: "branch", Value: options.Revision},
+				}},
+			}},
+		},
+	}
+
+	for i, phase := range phases {
+		spec := yaml.MapSlice{
+			{Key: "interval", Value: "10m"},
+			{Key: "path", Value: "./" + path.Clean(path.Join(options.Path, phase))},
+			{Key: "prune", Value: true},
+			{Key: "sourceRef", Value: yaml.MapSlice{
+				{Key: "kind", Value: "GitRepository"},
+				{Key: "name", Value: options.Name},
+			}},
+		}
+
+		if i > 0 {
+			spec = append(spec, yaml.MapItem{Key: "dependsOn", Value: []yaml.MapSlice{
+				{{Key: "name", Value: objectName(options, phases[i-1])}},
+			}})
+		}
+
+		result = append(result, yaml.MapSlice{
+			{Key: "apiVersion", Value: "kustomize.toolkit.fluxcd.io/v1beta1"},
+			{Key: "kind", Value: "Kustomization"},
+			{Key: "metadata", Value: yaml.MapSlice{
+				{Key: "name", Value: objectName(options, phase)},
+				{Key: "namespace", Value: namespace},
+			}},
+			{Key: "spec", Value: spec},
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,56 @@
+package gitops
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+var options = Options{
+	Name:     "onepanel",
+	RepoURL:  "https://github.com/example/deployments.git",
+	Path:     "clusters/production",
+	Revision: "main",
+}
+
+func TestWrite_ArgoCD(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+
+	paths, err := Write(fSys, "/output", ToolArgoCD, []string{"application-base", "application"}, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"argocd-applications/applications.yaml", "argocd.yaml"}, paths)
+
+	content, err := fSys.ReadFile(filepath.Join("/output", "argocd.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "name: onepanel\n")
+	assert.Contains(t, string(content), "path: clusters/production/argocd-applications")
+
+	content, err = fSys.ReadFile(filepath.Join("/output", "argocd-applications", "applications.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "argocd.argoproj.io/sync-wave: \"1\"")
+	assert.Contains(t, string(content), "path: clusters/production/application-base")
+}
+
+func TestWrite_Flux(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+
+	paths, err := Write(fSys, "/output", ToolFlux, []string{"application-base", "application"}, options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"flux.yaml"}, paths)
+
+	content, err := fSys.ReadFile(filepath.Join("/output", paths[0]))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "kind: GitRepository")
+	assert.Contains(t, string(content), "path: ./clusters/production/application\n")
+	assert.Contains(t, string(content), "dependsOn:\n  - name: onepanel-application-base")
+}
+
+func TestWrite_UnknownTool(t *testing.T) {
+	_, err := Write(filesys.MakeFsInMemory(), "/output", "jenkins", []string{"application"}, options)
+
+	assert.NotNil(t, err)
+}
@@ -3,6 +3,7 @@ module github.com/onepanelio/cli
 go 1.15
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9 // indirect
 	github.com/ghodss/yaml v1.0.0
 	github.com/iancoleman/strcase v0.1.3
 	github.com/minio/minio-go/v6 v6.0.57
@@ -16,8 +17,7 @@ require (
 	gopkg.in/yaml.v2 v2.4.0
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 	k8s.io/apimachinery v0.21.1
-	k8s.io/cli-runtime v0.21.1
 	k8s.io/client-go v0.21.1
-	k8s.io/kubectl v0.21.1
 	sigs.k8s.io/kustomize/api v0.8.10
+	sigs.k8s.io/kustomize/kyaml v0.10.20
 )
@@ -0,0 +1,293 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/onepanelio/cli/export"
+	"github.com/onepanelio/cli/internal/resourceutil"
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// ChartHeader is the first line of the Chart.yaml Write generates.
+// It marks the directory as generated, so the templates can safely be replaced.
+const ChartHeader = "# Generated by opctl build --format helm. Changes will be overwritten."
+
+// The params of Values are set to these placeholders when building the resources of a chart.
+// Every value derived from them, like URLs, *.svc.cluster.local hosts and RoleBinding subjects, then has the placeholder,
+// which Write replaces with the template of the value.
+const (
+	DomainPlaceholder    = "opctl-helm-domain.placeholder"
+	FqdnPlaceholder      = "opctl-helm-fqdn.placeholder"
+	NamespacePlaceholder = "opctl-helm-namespace-placeholder"
+)
+
+// ParamPlaceholders returns the placeholders to build the resources of a chart with, keyed by the params they replace
+func ParamPlaceholders() map[string]string {
+	return map[string]string{
+		"application.domain":           DomainPlaceholder,
+		"application.fqdn":             FqdnPlaceholder,
+		"application.defaultNamespace": NamespacePlaceholder,
+	}
+}
+
+// templatePlaceholders replaces the placeholders with the templates of their values.
+// The URLs of the UI config escape dots, as in opctl-helm-fqdn\.placeholder, so the escaped forms are templated too.
+var templatePlaceholders = strings.NewReplacer(
+	DomainPlaceholder, "{{ .Values.domain }}",
+	FqdnPlaceholder, "{{ .Values.fqdn }}",
+	NamespacePlaceholder, "{{ .Values.namespace }}",
+	escapeDots(DomainPlaceholder), `{{ .Values.domain | replace "." "\\." }}`,
+	escapeDots(FqdnPlaceholder), `{{ .Values.fqdn | replace "." "\\." }}`,
+)
+
+// escapeDots escapes the dots of s with a backslash, the way the build escapes the URLs of the UI config
+func escapeDots(s string) string {
+	return strings.ReplaceAll(s, ".", `\.`)
+}
+
+// Values are the params exposed in the values.yaml of the chart, instead of being part of the templates
+type Values struct {
+	Domain         string `yaml:"domain"`
+	Fqdn           string `yaml:"fqdn"`
+	Namespace      string `yaml:"namespace"`
+	CoreImageTag   string `yaml:"coreImageTag"`
+	CoreUIImageTag string `yaml:"coreUIImageTag"`
+}
+
+// ChartOptions describe the chart to write
+type ChartOptions struct {
+	Name             string
+	CLIVersion       string
+	ManifestsVersion string
+	Values           Values
+}
+
+// chart is the content of Chart.yaml
+type chart struct {
+	ApiVersion  string            `yaml:"apiVersion"`
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description"`
+	Type        string            `yaml:"type"`
+	Version     string            `yaml:"version"`
+	AppVersion  string            `yaml:"appVersion"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// escapeTemplates makes {{ and }} that are already in the resources, like in Argo workflow templates, render as is
+var escapeTemplates = strings.NewReplacer("{{", `{{ "{{" }}`, "}}", `{{ "}}" }}`)
+
+// chartVersion returns cliVersion if it is a valid semantic version, as Helm requires, and 0.0.0-dev otherwise
+func chartVersion(cliVersion string) string {
+	if _, err := version.ParseSemantic(cliVersion); err != nil {
+		return "0.0.0-dev"
+	}
+
+	return strings.TrimPrefix(cliVersion, "v")
+}
+
+// valueReplacement replaces value, when it is not part of a longer word, with a template of the key in values.yaml
+type valueReplacement struct {
+	pattern  *regexp.Regexp
+	template string
+}
+
+func newValueReplacement(prefix, value, key string) *valueReplacement {
+	if value == "" {
+		return nil
+	}
+
+	return &valueReplacement{
+		pattern:  regexp.MustCompile(`(^|[^A-Za-z0-9-])` + regexp.QuoteMeta(prefix+value) + `($|[^A-Za-z0-9-])`),
+		template: fmt.Sprintf("${1}%v{{ .Values.%v }}${2}", prefix, key),
+	}
+}
+
+// templateValues replaces the placeholders and the core image tags in content with templates
+func templateValues(content string, values Values) string {
+	replacements := []*valueReplacement{
+		newValueReplacement("onepanel/core:", values.CoreImageTag, "coreImageTag"),
+		newValueReplacement("onepanel/core-ui:", values.CoreUIImageTag, "coreUIImageTag"),
+	}
+
+	for _, replacement := range replacements {
+		if replacement == nil {
+			continue
+		}
+
+		// Matches can't overlap, so run twice to catch values right next to each other
+		for i := 0; i < 2; i++ {
+			content = replacement.pattern.ReplaceAllString(content, replacement.template)
+		}
+	}
+
+	return templatePlaceholders.Replace(content)
+}
+
+// hasPlaceholder returns true if content has any of the placeholders
+func hasPlaceholder(content string) bool {
+	return templatePlaceholders.Replace(content) != content
+}
+
+// decodeSecretPlaceholders moves the data of a Secret that has placeholders, once decoded, to stringData.
+// Base64 encoded placeholders can't be templated, stringData can, and the API server encodes it.
+func decodeSecretPlaceholders(r *resource.Resource) {
+	if r.GetKind() != "Secret" {
+		return
+	}
+
+	node := r.YNode()
+	data := resourceutil.MappingValue(node, "data")
+	if data == nil || data.Kind != yaml3.MappingNode {
+		return
+	}
+
+	stringData := resourceutil.MappingValue(node, "stringData")
+	kept := make([]*yaml3.Node, 0)
+	for i := 0; i+1 < len(data.Content); i += 2 {
+		key, value := data.Content[i], data.Content[i+1]
+
+		decoded, err := base64.StdEncoding.DecodeString(value.Value)
+		if err != nil || !hasPlaceholder(string(decoded)) {
+			kept = append(kept, key, value)
+			continue
+		}
+
+		if stringData == nil {
+			stringData = &yaml3.Node{Kind: yaml3.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: "stringData"}, stringData)
+		}
+		stringData.Content = append(stringData.Content, key, &yaml3.Node{Kind: yaml3.ScalarNode, Tag: "!!str", Value: string(decoded)})
+	}
+	data.Content = kept
+}
+
+// Write writes rm as a Helm chart to dir.
+// Each resource is a template under templates, at the same path export.Write uses.
+// rm is expected to be built with ParamPlaceholders, the placeholders are replaced with the templates of options.Values,
+// which are written to values.yaml. Resources in options.Values.Namespace, and the Namespace itself, are templated too.
+func Write(fSys filesys.FileSystem, dir string, rm resmap.ResMap, options ChartOptions) ([]string, error) {
+	chartPath := filepath.Join(dir, "Chart.yaml")
+	if fSys.Exists(chartPath) {
+		content, err := fSys.ReadFile(chartPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.HasPrefix(content, []byte(ChartHeader)) {
+			return nil, fmt.Errorf("%v was not generated by opctl, refusing to overwrite it", chartPath)
+		}
+	}
+
+	if err := fSys.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+
+	templatesDir := filepath.Join(dir, "templates")
+	if err := fSys.RemoveAll(templatesDir); err != nil && fSys.Exists(templatesDir) {
+		return nil, err
+	}
+
+	paths := make([]string, 0)
+	written := make(map[string]bool)
+	for _, r := range rm.Resources() {
+		// The files are named after the namespace of values.yaml, rather than its placeholder
+		if options.Values.Namespace != "" {
+			if r.GetNamespace() == NamespacePlaceholder {
+				if err := r.SetNamespace(options.Values.Namespace); err != nil {
+					return nil, err
+				}
+			}
+
+			if r.GetKind() == "Namespace" && r.GetName() == NamespacePlaceholder {
+				if err := r.SetName(options.Values.Namespace); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		path := filepath.Join("templates", export.FilePath(r))
+		if written[path] {
+			path = filepath.Join("templates", export.GroupFilePath(r))
+		}
+		if written[path] {
+			return nil, fmt.Errorf("more than one resource would be written to %v", path)
+		}
+
+		if options.Values.Namespace != "" {
+			if r.GetNamespace() == options.Values.Namespace {
+				if err := r.SetNamespace(NamespacePlaceholder); err != nil {
+					return nil, err
+				}
+			}
+
+			if r.GetKind() == "Namespace" && r.GetName() == options.Values.Namespace {
+				if err := r.SetName(NamespacePlaceholder); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		decodeSecretPlaceholders(r)
+
+		content, err := r.AsYAML()
+		if err != nil {
+			return nil, err
+		}
+
+		templated := templateValues(escapeTemplates.Replace(string(content)), options.Values)
+
+		if err := fSys.MkdirAll(filepath.Join(dir, filepath.Dir(path))); err != nil {
+			return nil, err
+		}
+
+		if err := fSys.WriteFile(filepath.Join(dir, path), []byte(templated)); err != nil {
+			return nil, err
+		}
+
+		written[path] = true
+		paths = append(paths, path)
+	}
+
+	valuesData, err := yaml.Marshal(options.Values)
+	if err != nil {
+		return nil, err
+	}
+	if err := fSys.WriteFile(filepath.Join(dir, "values.yaml"), valuesData); err != nil {
+		return nil, err
+	}
+
+	c := chart{
+		ApiVersion:  "v2",
+		Name:        options.Name,
+		Description: "Onepanel, rendered by opctl",
+		Type:        "application",
+		Version:     chartVersion(options.CLIVersion),
+		AppVersion:  options.ManifestsVersion,
+		Annotations: map[string]string{
+			"onepanel.io/cli-version":       options.CLIVersion,
+			"onepanel.io/manifests-version": options.ManifestsVersion,
+		},
+	}
+	chartData, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := fSys.WriteFile(chartPath, append([]byte(ChartHeader+"\n"), chartData...)); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
@@ -0,0 +1,151 @@
+package helm
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// resources are built with ParamPlaceholders
+const resources = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: opctl-helm-namespace-placeholder
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: onepanel
+  namespace: opctl-helm-namespace-placeholder
+data:
+  url: https://opctl-helm-fqdn.placeholder/api
+  storage: sys-storage-opctl-helm-namespace-placeholder.opctl-helm-domain.placeholder
+  endpoint: minio-gateway.opctl-helm-namespace-placeholder.svc.cluster.local:9000
+  literal: example.com
+  image: onepanel/core:v1.0.0
+  workflow: "{{inputs.parameters.name}}"
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: onepanel
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: onepanel
+subjects:
+- kind: ServiceAccount
+  name: onepanel
+  namespace: opctl-helm-namespace-placeholder
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: onepanel
+  namespace: opctl-helm-namespace-placeholder
+data:
+  url: aHR0cHM6Ly9vcGN0bC1oZWxtLWZxZG4ucGxhY2Vob2xkZXI=
+  password: cGFzc3dvcmQ=
+`
+
+func buildResMap(t *testing.T, content string) resmap.ResMap {
+	fSys := filesys.MakeFsInMemory()
+	assert.Nil(t, fSys.WriteFile("/build/resources.yaml", []byte(content)))
+	assert.Nil(t, fSys.WriteFile("/build/kustomization.yaml", []byte("resources:\n- resources.yaml\n")))
+
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, "/build")
+	assert.Nil(t, err)
+
+	return rm
+}
+
+func TestWrite(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	dir := "/chart"
+
+	paths, err := Write(fSys, dir, buildResMap(t, resources), ChartOptions{
+		Name:             "onepanel",
+		CLIVersion:       "v1.0.0",
+		ManifestsVersion: "v1.0.1",
+		Values: Values{
+			Domain:       "example.com",
+			Fqdn:         "app.example.com",
+			Namespace:    "onepanel",
+			CoreImageTag: "v1.0.0",
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(paths))
+
+	content, err := fSys.ReadFile(filepath.Join(dir, "templates", "onepanel", "configmap-onepanel.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "namespace: {{ .Values.namespace }}")
+	assert.Contains(t, string(content), "url: https://{{ .Values.fqdn }}/api")
+	assert.Contains(t, string(content), "storage: sys-storage-{{ .Values.namespace }}.{{ .Values.domain }}")
+	assert.Contains(t, string(content), "endpoint: minio-gateway.{{ .Values.namespace }}.svc.cluster.local:9000")
+	assert.Contains(t, string(content), "literal: example.com", "only values derived from the params are templated")
+	assert.Contains(t, string(content), "image: onepanel/core:{{ .Values.coreImageTag }}")
+	assert.Contains(t, string(content), `{{ "{{" }}inputs.parameters.name{{ "}}" }}`)
+
+	content, err = fSys.ReadFile(filepath.Join(dir, "templates", "_cluster", "clusterrolebinding-onepanel.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "namespace: {{ .Values.namespace }}")
+
+	content, err = fSys.ReadFile(filepath.Join(dir, "templates", "onepanel", "secret-onepanel.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "password: cGFzc3dvcmQ=")
+	assert.Contains(t, string(content), "stringData:\n  url: https://{{ .Values.fqdn }}")
+	assert.NotContains(t, string(content), "placeholder")
+
+	content, err = fSys.ReadFile(filepath.Join(dir, "values.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "fqdn: app.example.com")
+
+	content, err = fSys.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "version: 1.0.0")
+	assert.Contains(t, string(content), "appVersion: v1.0.1")
+}
+
+func TestWrite_RefusesUnknownChart(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	dir := "/chart"
+	assert.Nil(t, fSys.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: other\n")))
+
+	_, err := Write(fSys, dir, buildResMap(t, resources), ChartOptions{Name: "onepanel"})
+
+	assert.NotNil(t, err)
+}
+
+func TestWrite_UIConfigMap(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	dir := "/chart"
+
+	// The build escapes the URLs of the UI config
+	ui := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: onepanel-ui
+  namespace: opctl-helm-namespace-placeholder
+data:
+  applicationApiUrl: https\:\/\/opctl-helm-fqdn\.placeholder\/api
+  applicationApiWsUrl: wss\:\/\/opctl-helm-fqdn\.placeholder\/api
+`
+
+	_, err := Write(fSys, dir, buildResMap(t, ui), ChartOptions{
+		Name:   "onepanel",
+		Values: Values{Domain: "example.com", Fqdn: "app.example.com", Namespace: "onepanel"},
+	})
+	assert.Nil(t, err)
+
+	content, err := fSys.ReadFile(filepath.Join(dir, "templates", "onepanel", "configmap-onepanel-ui.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `applicationApiUrl: https\:\/\/{{ .Values.fqdn | replace "." "\\." }}\/api`)
+	assert.Contains(t, string(content), `applicationApiWsUrl: wss\:\/\/{{ .Values.fqdn | replace "." "\\." }}\/api`)
+	assert.NotContains(t, string(content), "placeholder")
+}
@@ -0,0 +1,111 @@
+package images
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultDomain is the registry of images without one, like nginx or onepanel/core
+const DefaultDomain = "docker.io"
+
+// referencePattern matches an image reference: [domain/]path[:tag][@digest]
+var referencePattern = regexp.MustCompile(`^(?:([a-zA-Z0-9.-]+(?::[0-9]+)?)/)?([a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*)(?::([\w][\w.-]{0,127}))?(?:@([a-z0-9]+:[a-fA-F0-9]{32,}))?$`)
+
+// Reference is a parsed container image reference, like gcr.io/project/image:tag
+type Reference struct {
+	Domain string // registry, empty if the reference has none
+	Path   string // repository in the registry, like onepanel/core
+	Tag    string
+	Digest string // like sha256:...
+}
+
+// ParseReference parses image. An error is returned if it is not an image reference,
+// for example when it is a variable like {{inputs.parameters.image}}.
+func ParseReference(image string) (*Reference, error) {
+	match := referencePattern.FindStringSubmatch(image)
+	if match == nil {
+		return nil, fmt.Errorf("'%v' is not an image reference", image)
+	}
+
+	ref := &Reference{
+		Domain: match[1],
+		Path:   match[2],
+		Tag:    match[3],
+		Digest: match[4],
+	}
+
+	// Only a first component with a '.' or ':', or localhost, is a registry. Otherwise, like in onepanel/core, it is part of the path.
+	if ref.Domain != "" && !strings.ContainsAny(ref.Domain, ".:") && ref.Domain != "localhost" {
+		if strings.ToLower(ref.Domain) != ref.Domain {
+			return nil, fmt.Errorf("'%v' is not an image reference", image)
+		}
+		ref.Path = ref.Domain + "/" + ref.Path
+		ref.Domain = ""
+	}
+
+	return ref, nil
+}
+
+// Name returns the image without the tag or digest, as written
+func (r *Reference) Name() string {
+	if r.Domain == "" {
+		return r.Path
+	}
+
+	return r.Domain + "/" + r.Path
+}
+
+// FullName returns the image without the tag or digest, with the default registry and library path filled in,
+// like docker.io/library/nginx for nginx
+func (r *Reference) FullName() string {
+	domain := r.Domain
+	if r.isDockerHub() {
+		domain = DefaultDomain
+	}
+
+	path := r.Path
+	if domain == DefaultDomain && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+
+	return domain + "/" + path
+}
+
+// isDockerHub returns true if the image is on Docker Hub, whether its registry is written or not
+func (r *Reference) isDockerHub() bool {
+	switch r.Domain {
+	case "", DefaultDomain, "index.docker.io", "registry-1.docker.io":
+		return true
+	}
+
+	return false
+}
+
+// mirrorPath returns the path of the image in a mirror. Official Docker Hub images have no library/ path,
+// so nginx and docker.io/library/nginx are the same image in the mirror.
+func (r *Reference) mirrorPath() string {
+	if r.isDockerHub() {
+		return strings.TrimPrefix(r.Path, "library/")
+	}
+
+	return r.Path
+}
+
+// Suffix returns the tag and digest part of the reference, like :v1.0.0
+func (r *Reference) Suffix() string {
+	suffix := ""
+	if r.Tag != "" {
+		suffix += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		suffix += "@" + r.Digest
+	}
+
+	return suffix
+}
+
+// String returns the reference as written
+func (r *Reference) String() string {
+	return r.Name() + r.Suffix()
+}
@@ -0,0 +1,388 @@
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Media types of the manifests the registry client accepts
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// dockerHubRegistry is where the registry API of docker.io is
+const dockerHubRegistry = "registry-1.docker.io"
+
+// Credentials are a username and password for a registry
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Descriptor points at a blob or manifest, see the OCI image spec
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Platform is the os and architecture of an image in a manifest list
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// Manifest is an image manifest, or a manifest list when Manifests is set
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+	Manifests     []Descriptor `json:"manifests,omitempty"`
+}
+
+// Client talks to the registry API, v2, of the registries images are in.
+// It authenticates with basic auth, or with the bearer tokens most public registries require.
+type Client struct {
+	HTTPClient *http.Client
+	// Credentials are by registry host, like docker.io or registry.example.com:5000
+	Credentials map[string]Credentials
+
+	tokens map[string]string // bearer tokens by registry host and repository
+}
+
+// NewClient returns a registry client using credentials
+func NewClient(credentials map[string]Credentials) *Client {
+	if credentials == nil {
+		credentials = make(map[string]Credentials)
+	}
+
+	return &Client{
+		HTTPClient:  http.DefaultClient,
+		Credentials: credentials,
+		tokens:      make(map[string]string),
+	}
+}
+
+// LoadDockerCredentials reads the registry credentials docker login saved in the docker config file at path.
+// If the file does not exist, there are no credentials.
+func LoadDockerCredentials(path string) (map[string]Credentials, error) {
+	result := make(map[string]Credentials)
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("unable to read %v: %v", path, err.Error())
+	}
+
+	for host, auth := range config.Auths {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			continue
+		}
+
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		// docker login stores Docker Hub as a url
+		host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+		host = strings.SplitN(host, "/", 2)[0]
+		if host == "index.docker.io" {
+			host = DefaultDomain
+		}
+
+		result[host] = Credentials{Username: parts[0], Password: parts[1]}
+	}
+
+	return result, nil
+}
+
+// DefaultDockerConfigPath returns the path of the docker config file, ~/.docker/config.json, or the one in DOCKER_CONFIG
+func DefaultDockerConfigPath() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// domain returns the registry host of ref
+func domain(ref *Reference) string {
+	if ref.Domain == "" {
+		return DefaultDomain
+	}
+
+	return ref.Domain
+}
+
+// repository returns the path of ref in its registry, with library/ for official Docker Hub images
+func repository(ref *Reference) string {
+	if domain(ref) == DefaultDomain && !strings.Contains(ref.Path, "/") {
+		return "library/" + ref.Path
+	}
+
+	return ref.Path
+}
+
+// baseURL returns the url of the registry API of ref.
+// Registries on localhost are accessed over http, like the registry a developer runs for testing.
+func baseURL(ref *Reference) string {
+	host := domain(ref)
+	if host == DefaultDomain {
+		host = dockerHubRegistry
+	}
+
+	scheme := "https"
+	hostname := strings.SplitN(host, ":", 2)[0]
+	if hostname == "localhost" || hostname == "127.0.0.1" {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%v://%v/v2/%v", scheme, host, repository(ref))
+}
+
+// ResolveDigest returns the digest of the manifest the tag of ref points to.
+// For multi-platform images, this is the digest of the manifest list.
+func (c *Client) ResolveDigest(ctx context.Context, ref *Reference) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+
+	_, _, digest, err := c.FetchManifest(ctx, ref, manifestReference(ref))
+	return digest, err
+}
+
+// manifestReference returns the digest of ref, or its tag, latest if it has none
+func manifestReference(ref *Reference) string {
+	if ref.Digest != "" {
+		return ref.Digest
+	}
+	if ref.Tag != "" {
+		return ref.Tag
+	}
+
+	return "latest"
+}
+
+// FetchManifest returns the manifest of ref with the tag or digest reference, along with its media type and digest
+func (c *Client) FetchManifest(ctx context.Context, ref *Reference, reference string) (content []byte, mediaType string, digest string, err error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL(ref)+"/manifests/"+reference, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	request.Header.Set("Accept", strings.Join([]string{MediaTypeDockerManifestList, MediaTypeOCIIndex, MediaTypeDockerManifest, MediaTypeOCIManifest}, ", "))
+
+	response, err := c.do(ref, request)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer response.Body.Close()
+
+	content, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	sum := sha256.Sum256(content)
+	digest = "sha256:" + hex.EncodeToString(sum[:])
+	if strings.HasPrefix(reference, "sha256:") && reference != digest {
+		return nil, "", "", fmt.Errorf("the manifest of %v does not match its digest %v", ref.String(), reference)
+	}
+
+	mediaType = strings.SplitN(response.Header.Get("Content-Type"), ";", 2)[0]
+
+	return content, mediaType, digest, nil
+}
+
+// FetchBlob returns the content of the blob with digest in the repository of ref. The caller closes it.
+func (c *Client) FetchBlob(ctx context.Context, ref *Reference, digest string) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL(ref)+"/blobs/"+digest, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.do(ref, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Body, nil
+}
+
+// do sends request, authenticating if the registry asks to
+func (c *Client) do(ref *Reference, request *http.Request) (*http.Response, error) {
+	host := domain(ref)
+	tokenKey := host + "/" + repository(ref)
+	if token, ok := c.tokens[tokenKey]; ok {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := c.HTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized {
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+
+		if err := c.authenticate(host, tokenKey, challenge, request); err != nil {
+			return nil, err
+		}
+
+		response, err = c.HTTPClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("%v %v: %v", request.Method, request.URL.String(), response.Status)
+	}
+
+	return response, nil
+}
+
+// authenticate sets the Authorization header of request for the challenge of the registry.
+// The token is requested with the context of request.
+func (c *Client) authenticate(host, tokenKey, challenge string, request *http.Request) error {
+	credentials, hasCredentials := c.Credentials[host]
+
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	switch scheme {
+	case "basic":
+		if !hasCredentials {
+			return fmt.Errorf("%v requires credentials, run docker login %v", host, host)
+		}
+		request.SetBasicAuth(credentials.Username, credentials.Password)
+		return nil
+	case "bearer":
+		params := parseChallenge(challenge)
+		tokenURL, err := url.Parse(params["realm"])
+		if err != nil || params["realm"] == "" {
+			return fmt.Errorf("%v sent an invalid authentication challenge: %v", host, challenge)
+		}
+
+		query := tokenURL.Query()
+		if service, ok := params["service"]; ok {
+			query.Set("service", service)
+		}
+		if scope, ok := params["scope"]; ok {
+			query.Set("scope", scope)
+		}
+		tokenURL.RawQuery = query.Encode()
+
+		tokenRequest, err := http.NewRequestWithContext(request.Context(), http.MethodGet, tokenURL.String(), nil)
+		if err != nil {
+			return err
+		}
+		if hasCredentials {
+			tokenRequest.SetBasicAuth(credentials.Username, credentials.Password)
+		}
+
+		response, err := c.HTTPClient.Do(tokenRequest)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("unable to get a token for %v: %v", host, response.Status)
+		}
+
+		token := struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+		if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+			return err
+		}
+		if token.Token == "" {
+			token.Token = token.AccessToken
+		}
+
+		c.tokens[tokenKey] = token.Token
+		request.Header.Set("Authorization", "Bearer "+token.Token)
+		return nil
+	}
+
+	return fmt.Errorf("%v requires an unsupported authentication: %v", host, challenge)
+}
+
+// parseChallenge returns the parameters of a WWW-Authenticate header, like realm="https://auth.docker.io/token"
+func parseChallenge(challenge string) map[string]string {
+	result := make(map[string]string)
+
+	parts := strings.SplitN(challenge, " ", 2)
+	if len(parts) != 2 {
+		return result
+	}
+
+	// Values are quoted, and may have commas, like scope="repository:a/b:pull,push"
+	rest := parts[1]
+	for rest != "" {
+		equals := strings.Index(rest, "=")
+		if equals < 0 {
+			break
+		}
+		key := strings.TrimSpace(strings.TrimLeft(rest[:equals], ", "))
+		rest = rest[equals+1:]
+
+		value := ""
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				end = len(rest)
+			}
+			value = rest[:end]
+			rest = rest[end:]
+		}
+
+		result[key] = value
+	}
+
+	return result
+}
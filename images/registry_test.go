@@ -0,0 +1,148 @@
+package images
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func digestOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newTestRegistry starts a registry serving team/app:v1, a multi-platform image, that requires a bearer token
+func newTestRegistry(t *testing.T) (*httptest.Server, map[string][]byte) {
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layer := []byte("layer content")
+	manifest, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		Config:        Descriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: digestOf(config), Size: int64(len(config))},
+		Layers:        []Descriptor{{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: digestOf(layer), Size: int64(len(layer))}},
+	})
+	assert.Nil(t, err)
+	index, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIIndex,
+		Manifests: []Descriptor{
+			{MediaType: MediaTypeOCIManifest, Digest: "sha256:" + strings.Repeat("0", 64), Size: 1, Platform: &Platform{OS: "linux", Architecture: "arm64"}},
+			{MediaType: MediaTypeOCIManifest, Digest: digestOf(manifest), Size: int64(len(manifest)), Platform: &Platform{OS: "linux", Architecture: "amd64"}},
+		},
+	})
+	assert.Nil(t, err)
+
+	blobs := map[string][]byte{
+		digestOf(config):   config,
+		digestOf(layer):    layer,
+		digestOf(manifest): manifest,
+		digestOf(index):    index,
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "user" || password != "secret" || r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"test-token"}`)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%v/token",service="test",scope="repository:team/app:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/v2/team/app/manifests/v1":
+			w.Header().Set("Content-Type", MediaTypeOCIIndex)
+			w.Write(index)
+		case r.URL.Path == "/v2/team/app/manifests/"+digestOf(manifest):
+			w.Header().Set("Content-Type", MediaTypeOCIManifest)
+			w.Write(manifest)
+		case strings.HasPrefix(r.URL.Path, "/v2/team/app/blobs/"):
+			content, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/team/app/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(content)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return server, blobs
+}
+
+func TestClient_ResolveDigest(t *testing.T) {
+	server, _ := newTestRegistry(t)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	ref, err := ParseReference(host + "/team/app:v1")
+	assert.Nil(t, err)
+
+	client := NewClient(map[string]Credentials{host: {Username: "user", Password: "secret"}})
+	digest, err := client.ResolveDigest(context.Background(), ref)
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(digest, "sha256:"))
+
+	_, err = NewClient(nil).ResolveDigest(context.Background(), ref)
+	assert.NotNil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.ResolveDigest(ctx, ref)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+}
+
+func TestClient_Save(t *testing.T) {
+	server, blobs := newTestRegistry(t)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	client := NewClient(map[string]Credentials{host: {Username: "user", Password: "secret"}})
+	output := &bytes.Buffer{}
+	err := client.Save(context.Background(), output, []string{host + "/team/app:v1"}, &Platform{OS: "linux", Architecture: "amd64"})
+	assert.Nil(t, err)
+
+	entries := make(map[string][]byte)
+	reader := tar.NewReader(output)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+
+		content, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err)
+		entries[header.Name] = content
+	}
+
+	assert.Contains(t, entries, "oci-layout")
+	assert.Contains(t, entries, "manifest.json")
+	assert.Equal(t, blobs[digestOf([]byte("layer content"))], entries[blobPath(digestOf([]byte("layer content")))])
+	assert.Contains(t, string(entries["index.json"]), host+"/team/app:v1")
+	assert.Contains(t, string(entries["manifest.json"]), `"RepoTags":["`+host+`/team/app:v1"]`)
+	assert.Equal(t, 6, len(entries))
+}
@@ -0,0 +1,125 @@
+package images
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/onepanelio/cli/internal/resourceutil"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// configMapImageLine matches an image field in yaml embedded in a ConfigMap, like the executor of the Argo workflow controller
+var configMapImageLine = regexp.MustCompile(`(?m)^\s*(?:-\s+)?["']?image["']?\s*:\s*["']?([^\s"'#]+)`)
+
+// imageField is an image reference in a resource. It is the part of the scalar node from start to end.
+type imageField struct {
+	node       *yaml.Node
+	start, end int
+}
+
+func (f *imageField) value() string {
+	return f.node.Value[f.start:f.end]
+}
+
+// setValue replaces the image reference. The node is updated from the end, so fields in the same node stay valid
+// when they are set in reverse order.
+func (f *imageField) setValue(image string) {
+	f.node.Value = f.node.Value[:f.start] + image + f.node.Value[f.end:]
+}
+
+// findImageFields returns the image references of r:
+// every image field with a string value, like the ones of containers and init containers,
+// and in ConfigMaps, the values of keys ending with image that have a repository path, a tag or a digest,
+// and image fields in embedded yaml.
+// CustomResourceDefinitions are skipped, they have no images.
+func findImageFields(r *resource.Resource) []*imageField {
+	switch r.GetKind() {
+	case "CustomResourceDefinition":
+		return nil
+	case "ConfigMap":
+		return findConfigMapImageFields(r.YNode())
+	}
+
+	result := make([]*imageField, 0)
+	resourceutil.WalkMappings(r.YNode(), func(mapping *yaml.Node) {
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			key, value := mapping.Content[i], mapping.Content[i+1]
+			if key.Value != "image" || value.Kind != yaml.ScalarNode {
+				continue
+			}
+
+			if _, err := ParseReference(value.Value); err != nil {
+				continue
+			}
+
+			result = append(result, &imageField{node: value, start: 0, end: len(value.Value)})
+		}
+	})
+
+	return result
+}
+
+func findConfigMapImageFields(node *yaml.Node) []*imageField {
+	result := make([]*imageField, 0)
+
+	data := resourceutil.MappingValue(node, "data")
+	if data == nil || data.Kind != yaml.MappingNode {
+		return result
+	}
+
+	for i := 0; i+1 < len(data.Content); i += 2 {
+		key, value := data.Content[i], data.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		if strings.HasSuffix(strings.ToLower(key.Value), "image") {
+			trimmed := strings.TrimSpace(value.Value)
+			if !hasImageSeparator(trimmed) {
+				continue
+			}
+			if _, err := ParseReference(trimmed); err == nil {
+				start := strings.Index(value.Value, trimmed)
+				result = append(result, &imageField{node: value, start: start, end: start + len(trimmed)})
+			}
+			continue
+		}
+
+		for _, match := range configMapImageLine.FindAllStringSubmatchIndex(value.Value, -1) {
+			if _, err := ParseReference(value.Value[match[2]:match[3]]); err != nil {
+				continue
+			}
+
+			result = append(result, &imageField{node: value, start: match[2], end: match[3]})
+		}
+	}
+
+	return result
+}
+
+// hasImageSeparator returns true if value has a repository path, a tag or a digest.
+// Any word parses as an image reference, so values like true or enabled, of keys like useCustomImage, are not images.
+func hasImageSeparator(value string) bool {
+	return strings.ContainsAny(value, "/:@")
+}
+
+// List returns the unique image references in rm, sorted
+func List(rm resmap.ResMap) []string {
+	unique := make(map[string]bool)
+	for _, r := range rm.Resources() {
+		for _, field := range findImageFields(r) {
+			unique[field.value()] = true
+		}
+	}
+
+	result := make([]string, 0, len(unique))
+	for image := range unique {
+		result = append(result, image)
+	}
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,120 @@
+package images
+
+import (
+	"strings"
+
+	"github.com/onepanelio/cli/internal/resourceutil"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// Rewriter points images at a registry mirror
+type Rewriter struct {
+	// Registry is the registry, and optional path, images are moved to. For example, registry.example.com/onepanel
+	Registry string
+	// Overrides maps an image name, without a tag, to the name to use instead.
+	// The name can be written as in the resources, like nginx, or in full, like docker.io/library/nginx.
+	Overrides map[string]string
+}
+
+// NewRewriter returns a Rewriter moving images to registry, except for the overridden ones
+func NewRewriter(registry string, overrides map[string]string) *Rewriter {
+	return &Rewriter{
+		Registry:  strings.TrimSuffix(registry, "/"),
+		Overrides: overrides,
+	}
+}
+
+// Rewrite returns the image in the mirror. The registry of the image is replaced, keeping the path, tag and digest.
+// For example, with registry.example.com, gcr.io/project/image:v1 is registry.example.com/project/image:v1.
+// Official Docker Hub images are moved without library/, nginx and docker.io/library/nginx are both registry.example.com/nginx.
+// Images that are already in the mirror, or are not image references, are returned as is.
+func (r *Rewriter) Rewrite(image string) string {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return image
+	}
+
+	if override, ok := r.Overrides[ref.Name()]; ok {
+		return override + ref.Suffix()
+	}
+	if override, ok := r.Overrides[ref.FullName()]; ok {
+		return override + ref.Suffix()
+	}
+
+	if strings.HasPrefix(ref.Name(), r.Registry+"/") {
+		return image
+	}
+
+	return r.Registry + "/" + ref.mirrorPath() + ref.Suffix()
+}
+
+// RewriteResMap rewrites every image in rm, see Rewrite and findImageFields
+func (r *Rewriter) RewriteResMap(rm resmap.ResMap) {
+	for _, res := range rm.Resources() {
+		fields := findImageFields(res)
+
+		// In reverse, so the positions of earlier fields in the same node stay valid
+		for i := len(fields) - 1; i >= 0; i-- {
+			fields[i].setValue(r.Rewrite(fields[i].value()))
+		}
+	}
+}
+
+// AddPullSecrets adds the secrets to the imagePullSecrets of every pod spec and ServiceAccount in rm.
+// Pods created at runtime, like the ones of Argo workflows, use the secrets of their ServiceAccount.
+func AddPullSecrets(rm resmap.ResMap, secrets []string) {
+	if len(secrets) == 0 {
+		return
+	}
+
+	for _, res := range rm.Resources() {
+		switch res.GetKind() {
+		case "CustomResourceDefinition":
+			continue
+		case "ServiceAccount":
+			addPullSecrets(res.YNode(), secrets)
+			continue
+		}
+
+		resourceutil.WalkMappings(res.YNode(), func(mapping *yaml.Node) {
+			if containers := resourceutil.MappingValue(mapping, "containers"); containers != nil && containers.Kind == yaml.SequenceNode {
+				addPullSecrets(mapping, secrets)
+			}
+		})
+	}
+}
+
+// addPullSecrets adds the secrets missing from the imagePullSecrets of the mapping node
+func addPullSecrets(mapping *yaml.Node, secrets []string) {
+	pullSecrets := resourceutil.MappingValue(mapping, "imagePullSecrets")
+	if pullSecrets == nil {
+		pullSecrets = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "imagePullSecrets"}, pullSecrets)
+	}
+	if pullSecrets.Kind != yaml.SequenceNode {
+		return
+	}
+
+	existing := make(map[string]bool)
+	for _, item := range pullSecrets.Content {
+		if name := resourceutil.MappingValue(item, "name"); name != nil {
+			existing[name.Value] = true
+		}
+	}
+
+	for _, secret := range secrets {
+		if existing[secret] {
+			continue
+		}
+
+		pullSecrets.Content = append(pullSecrets.Content, &yaml.Node{
+			Kind: yaml.MappingNode,
+			Tag:  "!!map",
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name"},
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: secret},
+			},
+		})
+	}
+}
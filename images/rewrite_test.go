@@ -0,0 +1,96 @@
+package images
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+const resources = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: core
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox
+      containers:
+      - name: core
+        image: onepanel/core:v1.0.0
+      - name: sidecar
+        image: gcr.io/project/sidecar@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: workflow-controller-configmap
+data:
+  executorImage: argoproj/argoexec:v2.12.9
+  useCustomImage: "true"
+  config: |
+    executor:
+      image: argoproj/argoexec:v2.12.9
+      imagePullPolicy: IfNotPresent
+    template: "{{inputs.parameters.image}}"
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: default
+`
+
+func buildResMap(t *testing.T, content string) resmap.ResMap {
+	fSys := filesys.MakeFsInMemory()
+	assert.Nil(t, fSys.WriteFile("/build/resources.yaml", []byte(content)))
+	assert.Nil(t, fSys.WriteFile("/build/kustomization.yaml", []byte("resources:\n- resources.yaml\n")))
+
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, "/build")
+	assert.Nil(t, err)
+
+	return rm
+}
+
+func TestRewriter_Rewrite(t *testing.T) {
+	rewriter := NewRewriter("registry.example.com/mirror/", map[string]string{
+		"docker.io/library/busybox": "registry.example.com/base/busybox",
+	})
+
+	assert.Equal(t, "registry.example.com/mirror/onepanel/core:v1.0.0", rewriter.Rewrite("onepanel/core:v1.0.0"))
+	assert.Equal(t, "registry.example.com/mirror/project/image:v1", rewriter.Rewrite("gcr.io/project/image:v1"))
+	assert.Equal(t, "registry.example.com/mirror/nginx", rewriter.Rewrite("localhost:5000/nginx"))
+	assert.Equal(t, "registry.example.com/base/busybox:1.32", rewriter.Rewrite("busybox:1.32"))
+	assert.Equal(t, "registry.example.com/base/busybox:1.32", rewriter.Rewrite("index.docker.io/busybox:1.32"))
+	assert.Equal(t, "registry.example.com/mirror/nginx:1", rewriter.Rewrite("registry.example.com/mirror/nginx:1"))
+	assert.Equal(t, "registry.example.com/mirror/nginx:1", rewriter.Rewrite("nginx:1"))
+	assert.Equal(t, "registry.example.com/mirror/nginx:1", rewriter.Rewrite("docker.io/library/nginx:1"))
+	assert.Equal(t, "registry.example.com/mirror/nginx:1", rewriter.Rewrite("index.docker.io/library/nginx:1"))
+	assert.Equal(t, "registry.example.com/mirror/library/nginx:1", rewriter.Rewrite("quay.io/library/nginx:1"))
+	assert.Equal(t, "registry.example.com/mirror/argoproj/argoexec:v2", rewriter.Rewrite("docker.io/argoproj/argoexec:v2"))
+	assert.Equal(t, "$(image)", rewriter.Rewrite("$(image)"))
+}
+
+func TestRewriter_RewriteResMap(t *testing.T) {
+	rm := buildResMap(t, resources)
+
+	NewRewriter("registry.example.com", nil).RewriteResMap(rm)
+	AddPullSecrets(rm, []string{"mirror-credentials"})
+
+	content, err := rm.AsYaml()
+	assert.Nil(t, err)
+	result := string(content)
+
+	assert.Contains(t, result, "image: registry.example.com/busybox\n")
+	assert.Contains(t, result, "image: registry.example.com/onepanel/core:v1.0.0\n")
+	assert.Contains(t, result, "image: registry.example.com/project/sidecar@sha256:")
+	assert.Contains(t, result, "executorImage: registry.example.com/argoproj/argoexec:v2.12.9\n")
+	assert.Contains(t, result, "useCustomImage: \"true\"\n", "values without a path, tag or digest are not images")
+	assert.Contains(t, result, "  image: registry.example.com/argoproj/argoexec:v2.12.9\n")
+	assert.Contains(t, result, "{{inputs.parameters.image}}")
+	assert.Equal(t, 2, strings.Count(result, "- name: mirror-credentials"))
+}
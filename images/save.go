@@ -0,0 +1,232 @@
+package images
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParsePlatform parses a platform written as os/architecture[/variant], like linux/amd64
+func ParsePlatform(platform string) (*Platform, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("'%v' is not a platform, like linux/amd64", platform)
+	}
+
+	result := &Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		result.Variant = parts[2]
+	}
+
+	return result, nil
+}
+
+// matches returns true if p is other. A platform without a variant matches any variant.
+func (p *Platform) matches(other *Platform) bool {
+	if other == nil {
+		return false
+	}
+
+	return p.OS == other.OS && p.Architecture == other.Architecture && (p.Variant == "" || p.Variant == other.Variant)
+}
+
+// imageLayout is the part of an OCI layout for the index.json file
+type imageLayout struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// dockerManifest is an entry of the manifest.json file docker load reads
+type dockerManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// tarWriter writes the images to a tar file, writing each blob once
+type tarWriter struct {
+	client  *Client
+	tar     *tar.Writer
+	written map[string]bool
+}
+
+// Save writes images to w as a tar file with an OCI image layout. For multi-platform images, only platform is saved.
+// The tar file also has the manifest.json of docker save, so it can be loaded with docker load as well.
+func (c *Client) Save(ctx context.Context, w io.Writer, images []string, platform *Platform) error {
+	t := &tarWriter{
+		client:  c,
+		tar:     tar.NewWriter(w),
+		written: make(map[string]bool),
+	}
+
+	layout := imageLayout{SchemaVersion: 2, Manifests: make([]Descriptor, 0)}
+	dockerManifests := make([]dockerManifest, 0)
+
+	for _, image := range images {
+		ref, err := ParseReference(image)
+		if err != nil {
+			return err
+		}
+
+		descriptor, manifest, err := t.writeImage(ctx, ref, platform)
+		if err != nil {
+			return fmt.Errorf("unable to save %v: %v", image, err.Error())
+		}
+
+		descriptor.Annotations = map[string]string{
+			"org.opencontainers.image.ref.name": ref.String(),
+			"io.containerd.image.name":          ref.String(),
+		}
+		layout.Manifests = append(layout.Manifests, *descriptor)
+
+		entry := dockerManifest{
+			Config:   blobPath(manifest.Config.Digest),
+			RepoTags: make([]string, 0),
+			Layers:   make([]string, 0),
+		}
+		if ref.Tag != "" {
+			entry.RepoTags = append(entry.RepoTags, ref.Name()+":"+ref.Tag)
+		}
+		for _, layer := range manifest.Layers {
+			entry.Layers = append(entry.Layers, blobPath(layer.Digest))
+		}
+		dockerManifests = append(dockerManifests, entry)
+	}
+
+	index, err := json.Marshal(layout)
+	if err != nil {
+		return err
+	}
+	if err := t.writeFile("index.json", index); err != nil {
+		return err
+	}
+
+	dockerManifestData, err := json.Marshal(dockerManifests)
+	if err != nil {
+		return err
+	}
+	if err := t.writeFile("manifest.json", dockerManifestData); err != nil {
+		return err
+	}
+
+	if err := t.writeFile("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return err
+	}
+
+	return t.tar.Close()
+}
+
+// blobPath returns the path of the blob with digest in the layout
+func blobPath(digest string) string {
+	return "blobs/" + strings.Replace(digest, ":", "/", 1)
+}
+
+// writeImage writes the manifest, config and layers of ref, returning the descriptor of the manifest
+func (t *tarWriter) writeImage(ctx context.Context, ref *Reference, platform *Platform) (*Descriptor, *Manifest, error) {
+	content, mediaType, digest, err := t.client.FetchManifest(ctx, ref, manifestReference(ref))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, nil, err
+	}
+	if mediaType == "" {
+		mediaType = manifest.MediaType
+	}
+
+	if mediaType == MediaTypeDockerManifestList || mediaType == MediaTypeOCIIndex || len(manifest.Manifests) > 0 {
+		var selected *Descriptor
+		for i := range manifest.Manifests {
+			if platform.matches(manifest.Manifests[i].Platform) {
+				selected = &manifest.Manifests[i]
+				break
+			}
+		}
+		if selected == nil {
+			return nil, nil, fmt.Errorf("there is no image for %v/%v", platform.OS, platform.Architecture)
+		}
+
+		content, mediaType, digest, err = t.client.FetchManifest(ctx, ref, selected.Digest)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		manifest = &Manifest{}
+		if err := json.Unmarshal(content, manifest); err != nil {
+			return nil, nil, err
+		}
+		if mediaType == "" {
+			mediaType = selected.MediaType
+		}
+	}
+
+	if mediaType != MediaTypeDockerManifest && mediaType != MediaTypeOCIManifest {
+		return nil, nil, fmt.Errorf("unsupported manifest type '%v'", mediaType)
+	}
+
+	if !t.written[digest] {
+		if err := t.writeFile(blobPath(digest), content); err != nil {
+			return nil, nil, err
+		}
+		t.written[digest] = true
+	}
+
+	for _, blob := range append([]Descriptor{manifest.Config}, manifest.Layers...) {
+		if err := t.writeBlob(ctx, ref, blob); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(content))}, manifest, nil
+}
+
+// writeBlob streams the blob from the registry to the tar file, checking its digest
+func (t *tarWriter) writeBlob(ctx context.Context, ref *Reference, blob Descriptor) error {
+	if t.written[blob.Digest] {
+		return nil
+	}
+
+	if !strings.HasPrefix(blob.Digest, "sha256:") {
+		return fmt.Errorf("unsupported digest %v", blob.Digest)
+	}
+
+	reader, err := t.client.FetchBlob(ctx, ref, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := t.tar.WriteHeader(&tar.Header{Name: blobPath(blob.Digest), Mode: 0644, Size: blob.Size}); err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	if _, err := io.CopyN(t.tar, io.TeeReader(reader, hash), blob.Size); err != nil {
+		return fmt.Errorf("unable to download %v: %v", blob.Digest, err.Error())
+	}
+
+	if actual := "sha256:" + hex.EncodeToString(hash.Sum(nil)); actual != blob.Digest {
+		return fmt.Errorf("blob %v has digest %v", blob.Digest, actual)
+	}
+
+	t.written[blob.Digest] = true
+
+	return nil
+}
+
+func (t *tarWriter) writeFile(name string, content []byte) error {
+	if err := t.tar.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		return err
+	}
+
+	_, err := t.tar.Write(content)
+
+	return err
+}
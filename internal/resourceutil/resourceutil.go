@@ -0,0 +1,47 @@
+// Package resourceutil has the helpers the packages checking and rewriting the resources of a build share
+package resourceutil
+
+import (
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// Describe returns the kind, namespace and name of r, like Deployment onepanel/core
+func Describe(r *resource.Resource) string {
+	name := r.GetName()
+	if r.GetNamespace() != "" {
+		name = r.GetNamespace() + "/" + name
+	}
+
+	return r.GetKind() + " " + name
+}
+
+// WalkMappings calls visit for every mapping node under node, including node
+func WalkMappings(node *yaml.Node, visit func(mapping *yaml.Node)) {
+	if node == nil {
+		return
+	}
+
+	if node.Kind == yaml.MappingNode {
+		visit(node)
+	}
+
+	for _, child := range node.Content {
+		WalkMappings(child, visit)
+	}
+}
+
+// MappingValue returns the value of key in the mapping node, or nil if there is none
+func MappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
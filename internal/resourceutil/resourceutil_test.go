@@ -0,0 +1,39 @@
+package resourceutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+func TestDescribe(t *testing.T) {
+	factory := resource.NewFactory(nil)
+
+	r, err := factory.FromBytes([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n  namespace: onepanel\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "ConfigMap onepanel/settings", Describe(r))
+
+	r, err = factory.FromBytes([]byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: onepanel\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Namespace onepanel", Describe(r))
+}
+
+func TestWalkMappings_MappingValue(t *testing.T) {
+	node := &yaml.Node{}
+	assert.Nil(t, yaml.Unmarshal([]byte("spec:\n  containers:\n  - name: core\n  - name: sidecar\n"), node))
+
+	names := make([]string, 0)
+	WalkMappings(node, func(mapping *yaml.Node) {
+		if name := MappingValue(mapping, "name"); name != nil {
+			names = append(names, name.Value)
+		}
+	})
+	assert.Equal(t, []string{"core", "sidecar"}, names)
+
+	assert.Nil(t, MappingValue(node, "spec"), "a document node is not a mapping")
+	assert.NotNil(t, MappingValue(node.Content[0], "spec"))
+	assert.Nil(t, MappingValue(node.Content[0], "status"))
+	assert.Nil(t, MappingValue(nil, "spec"))
+}
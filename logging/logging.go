@@ -0,0 +1,196 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is how detailed a message is. Messages of a level are shown when the level of the logger is at, or below, it.
+type Level int
+
+// Levels, from the most to the least detailed
+const (
+	// LevelTrace is for every Kubernetes API call
+	LevelTrace Level = iota
+	// LevelDebug is for details that help find out why something failed
+	LevelDebug
+	// LevelInfo is for progress, the default
+	LevelInfo
+	LevelWarning
+	LevelError
+)
+
+// String returns the name of the level, as shown in the log file
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "trace"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarning:
+		return "warning"
+	case LevelError:
+		return "error"
+	}
+
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Logger writes messages to the console, and optionally to a file, each with their own level
+type Logger struct {
+	mu           sync.Mutex
+	console      io.Writer
+	consoleLevel Level
+	file         io.WriteCloser
+	fileLevel    Level
+	redact       func(string) string
+	now          func() time.Time
+}
+
+// NewLogger returns a logger writing messages of level, and above, to console
+func NewLogger(console io.Writer, level Level) *Logger {
+	return &Logger{
+		console:      console,
+		consoleLevel: level,
+		now:          time.Now,
+	}
+}
+
+// SetFile makes the logger write messages of level, and above, to file as well, with a timestamp.
+// The previous file, if any, is closed.
+func (l *Logger) SetFile(file io.WriteCloser, level Level) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var err error
+	if l.file != nil {
+		err = l.file.Close()
+	}
+	l.file = file
+	l.fileLevel = level
+
+	return err
+}
+
+// SetRedact makes the logger pass every message through redact before writing it, like redact.Redactor.String
+func (l *Logger) SetRedact(redact func(string) string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.redact = redact
+}
+
+// Enabled is true if messages of level are written anywhere
+func (l *Logger) Enabled(level Level) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return level >= l.consoleLevel || (l.file != nil && level >= l.fileLevel)
+}
+
+// Logf writes a message of level
+func (l *Logger) Logf(level Level, format string, a ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	writeConsole := level >= l.consoleLevel
+	writeFile := l.file != nil && level >= l.fileLevel
+	if !writeConsole && !writeFile {
+		return
+	}
+
+	message := strings.TrimRight(fmt.Sprintf(format, a...), "\n")
+	if l.redact != nil {
+		message = l.redact(message)
+	}
+
+	if writeConsole {
+		prefix := ""
+		if level != LevelInfo {
+			prefix = "[" + level.String() + "] "
+		}
+		fmt.Fprintf(l.console, "%v%v\n", prefix, message)
+	}
+
+	if writeFile {
+		fmt.Fprintf(l.file, "%v %-7v %v\n", l.now().UTC().Format(time.RFC3339), level.String(), message)
+	}
+}
+
+// Close closes the file of the logger, if any
+func (l *Logger) Close() error {
+	return l.SetFile(nil, LevelTrace)
+}
+
+// Writer returns a writer logging each line written to it as a message of level, for output of libraries like kubectl
+func (l *Logger) Writer(level Level) io.Writer {
+	return &lineWriter{logger: l, level: level}
+}
+
+// lineWriter logs complete lines, keeping partial ones until they are finished
+type lineWriter struct {
+	logger  *Logger
+	level   Level
+	mu      sync.Mutex
+	partial string
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	lines := strings.Split(w.partial+string(p), "\n")
+	w.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		w.logger.Logf(w.level, "%v", line)
+	}
+
+	return len(p), nil
+}
+
+// std is the logger of the CLI, configured by the root command
+var std = NewLogger(os.Stderr, LevelInfo)
+
+// Default returns the logger of the CLI
+func Default() *Logger {
+	return std
+}
+
+// SetLevel sets the level of the console of the logger of the CLI
+func SetLevel(level Level) {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+
+	std.consoleLevel = level
+}
+
+// Tracef logs a message of LevelTrace
+func Tracef(format string, a ...interface{}) {
+	std.Logf(LevelTrace, format, a...)
+}
+
+// Debugf logs a message of LevelDebug
+func Debugf(format string, a ...interface{}) {
+	std.Logf(LevelDebug, format, a...)
+}
+
+// Infof logs a message of LevelInfo
+func Infof(format string, a ...interface{}) {
+	std.Logf(LevelInfo, format, a...)
+}
+
+// Warningf logs a message of LevelWarning
+func Warningf(format string, a ...interface{}) {
+	std.Logf(LevelWarning, format, a...)
+}
+
+// Errorf logs a message of LevelError
+func Errorf(format string, a ...interface{}) {
+	std.Logf(LevelError, format, a...)
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestLogger_Logf(t *testing.T) {
+	console := &bytes.Buffer{}
+	file := &bufferCloser{}
+
+	logger := NewLogger(console, LevelInfo)
+	logger.now = func() time.Time { return time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC) }
+	assert.Nil(t, logger.SetFile(file, LevelTrace))
+	logger.SetRedact(func(message string) string { return strings.Replace(message, "supersecret", "REDACTED", -1) })
+
+	logger.Logf(LevelDebug, "checking %v", "namespaces")
+	logger.Logf(LevelInfo, "Building...")
+	logger.Logf(LevelError, "unable to connect with supersecret\n")
+
+	assert.Equal(t, "Building...\n[error] unable to connect with REDACTED\n", console.String())
+	assert.Equal(t, "2021-06-01T10:00:00Z debug   checking namespaces\n"+
+		"2021-06-01T10:00:00Z info    Building...\n"+
+		"2021-06-01T10:00:00Z error   unable to connect with REDACTED\n", file.String())
+
+	assert.Nil(t, logger.Close())
+	assert.True(t, file.closed)
+	assert.False(t, logger.Enabled(LevelDebug))
+}
+
+func TestLogger_Writer(t *testing.T) {
+	console := &bytes.Buffer{}
+	logger := NewLogger(console, LevelInfo)
+
+	writer := logger.Writer(LevelInfo)
+	writer.Write([]byte("deployment.apps/core conf"))
+	writer.Write([]byte("igured\nservice/core unchanged\n"))
+
+	assert.Equal(t, "deployment.apps/core configured\nservice/core unchanged\n", console.String())
+}
+
+func TestTraceRoundTripper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	console := &bytes.Buffer{}
+	client := &http.Client{Transport: NewTraceRoundTripper(NewLogger(console, LevelTrace), http.DefaultTransport)}
+
+	response, err := client.Get(server.URL + "/api/v1/namespaces/onepanel?limit=1")
+	assert.Nil(t, err)
+	response.Body.Close()
+
+	assert.True(t, strings.HasPrefix(console.String(), "[trace] GET /api/v1/namespaces/onepanel?limit=1 404 ("), console.String())
+}
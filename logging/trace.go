@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"net/http"
+	"time"
+)
+
+// traceRoundTripper logs every request made through it at LevelTrace
+type traceRoundTripper struct {
+	logger *Logger
+	next   http.RoundTripper
+}
+
+// NewTraceRoundTripper returns a round tripper logging the verb, resource, status and latency of each request at LevelTrace.
+// It is meant for the transport of Kubernetes clients, see rest.Config.Wrap.
+func NewTraceRoundTripper(logger *Logger, next http.RoundTripper) http.RoundTripper {
+	return &traceRoundTripper{logger: logger, next: next}
+}
+
+// TraceKubernetesAPI wraps the transport of a Kubernetes client, to log its calls to the logger of the CLI
+func TraceKubernetesAPI(next http.RoundTripper) http.RoundTripper {
+	return NewTraceRoundTripper(std, next)
+}
+
+func (t *traceRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !t.logger.Enabled(LevelTrace) {
+		return t.next.RoundTrip(request)
+	}
+
+	start := time.Now()
+	response, err := t.next.RoundTrip(request)
+	latency := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		t.logger.Logf(LevelTrace, "%v %v error: %v (%v)", request.Method, request.URL.RequestURI(), err.Error(), latency)
+		return response, err
+	}
+
+	t.logger.Logf(LevelTrace, "%v %v %v (%v)", request.Method, request.URL.RequestURI(), response.StatusCode, latency)
+
+	return response, err
+}
@@ -3,9 +3,10 @@ package manifest
 import (
 	"fmt"
 	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/logging"
 	"github.com/onepanelio/cli/util"
-	"log"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -135,13 +136,35 @@ func (b *Builder) AddCommonComponents(skipComponents ...string) error {
 func (b *Builder) GetOverlayComponents() []*OverlayedComponent {
 	result := make([]*OverlayedComponent, 0)
 
-	for key := range b.overlayedComponents {
+	for _, key := range b.sortedComponentPaths() {
 		result = append(result, b.overlayedComponents[key])
 	}
 
 	return result
 }
 
+// sortedComponentPaths returns the paths of the added components, sorted, so the build output is the same between runs
+func (b *Builder) sortedComponentPaths() []string {
+	keys := make([]string, 0, len(b.overlayedComponents))
+	for key := range b.overlayedComponents {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// sortedOverlayKeys returns the keys of overlays, sorted
+func sortedOverlayKeys(overlays map[string]*Overlay) []string {
+	keys := make([]string, 0, len(overlays))
+	for key := range overlays {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
 // AddOverlayContender adds potential overlays to the components being considered
 func (b *Builder) AddOverlayContender(contenders ...string) {
 	for _, contender := range contenders {
@@ -152,7 +175,7 @@ func (b *Builder) AddOverlayContender(contenders ...string) {
 func (b *Builder) Build() error {
 	// Go through each overlay contender and component, and add the overlays
 	for _, overlayContender := range b.overlayContenders {
-		for key := range b.manifest.overlays {
+		for _, key := range sortedOverlayKeys(b.manifest.overlays) {
 			overlay := b.manifest.overlays[key]
 			if _, ok := b.overlayedComponents[overlay.component.path]; !ok {
 				continue
@@ -177,7 +200,7 @@ func (b *Builder) GetYamls() []*util.DynamicYaml {
 	for _, path := range filePaths {
 		temp, err := util.LoadDynamicYamlFromFile(path)
 		if err != nil {
-			log.Printf("[error] LoadDynamicYaml %v. Error %v", path, err.Error())
+			logging.Errorf("LoadDynamicYaml %v. Error %v", path, err.Error())
 			continue
 		}
 
@@ -193,7 +216,7 @@ func (b *Builder) GetYamls() []*util.DynamicYaml {
 func (b *Builder) GetVarsFilePaths() []string {
 	vars := make([]string, 0)
 
-	for key := range b.overlayedComponents {
+	for _, key := range b.sortedComponentPaths() {
 		overlayComponent := b.overlayedComponents[key]
 		vars = append(vars, overlayComponent.component.VarsFilePath())
 
@@ -208,7 +231,7 @@ func (b *Builder) GetVarsFilePaths() []string {
 		fullPath := filepath.Join(b.manifest.path, path)
 		exists, err := files.Exists(fullPath)
 		if err != nil {
-			log.Printf("[error] files.Exists(%v) %v", path, err.Error())
+			logging.Errorf("files.Exists(%v) %v", path, err.Error())
 			continue
 		}
 		if !exists {
@@ -0,0 +1,194 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/github"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// CompatibilityFileName is the file, at the root of a manifest release, that declares
+// which CLI versions are able to use the release, for example:
+//
+//	cli:
+//	  minVersion: 0.18.0
+//	  maxVersion: 0.19.3
+const CompatibilityFileName = "compatibility.yaml"
+
+const (
+	// Compatible means the CLI version is within the range declared by the manifests
+	Compatible = "yes"
+	// Incompatible means the CLI version is outside the range declared by the manifests
+	Incompatible = "no"
+	// CompatibilityUnknown means the manifests declare no range, or the CLI version can't be compared, as with dev builds.
+	CompatibilityUnknown = "unknown"
+)
+
+// CLICompatibility is the range of CLI versions, inclusive, a manifest release works with.
+// Either end may be left blank to leave the range open.
+type CLICompatibility struct {
+	MinVersion string `yaml:"minVersion"`
+	MaxVersion string `yaml:"maxVersion"`
+}
+
+// Compatibility is the content of a manifest release's compatibility.yaml
+type Compatibility struct {
+	CLI CLICompatibility `yaml:"cli"`
+}
+
+// ParseCompatibility loads a Compatibility from the content of a compatibility.yaml file
+func ParseCompatibility(data []byte) (*Compatibility, error) {
+	compatibility := &Compatibility{}
+	if err := yaml.Unmarshal(data, compatibility); err != nil {
+		return nil, err
+	}
+
+	if compatibility.CLI.MinVersion != "" {
+		if _, err := version.ParseGeneric(compatibility.CLI.MinVersion); err != nil {
+			return nil, fmt.Errorf("%v: cli.minVersion: %v", CompatibilityFileName, err.Error())
+		}
+	}
+
+	if compatibility.CLI.MaxVersion != "" {
+		if _, err := version.ParseGeneric(compatibility.CLI.MaxVersion); err != nil {
+			return nil, fmt.Errorf("%v: cli.maxVersion: %v", CompatibilityFileName, err.Error())
+		}
+	}
+
+	return compatibility, nil
+}
+
+// LoadCompatibility reads the compatibility declaration of the manifests located at manifestRoot.
+// If the manifests don't declare one, nil is returned with no error.
+func LoadCompatibility(manifestRoot string) (*Compatibility, error) {
+	path := filepath.Join(manifestRoot, CompatibilityFileName)
+	exists, err := files.Exists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCompatibility(data)
+}
+
+// GetGithubCompatibility fetches the compatibility declaration of the manifests release with the given tag.
+// "latest" is resolved to the latest release. If the release doesn't declare one, nil is returned with no error.
+func GetGithubCompatibility(ctx context.Context, tag string) (*Compatibility, error) {
+	githubAPI, err := github.New(GithubManifestsRepositoryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if tag == "latest" {
+		release, err := githubAPI.GetLatestRelease(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tag = release.TagName
+	}
+
+	data, err := GetReleaseCompatibilityData(ctx, githubAPI, tag, CompatibilityCacheDir())
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	return ParseCompatibility(data)
+}
+
+// CompatibilityCacheDir is where the compatibility declarations of releases are cached, by tag.
+// Empty if there is no cache directory for the user.
+func CompatibilityCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(cacheDir, "opctl", "compatibility")
+}
+
+// GetReleaseCompatibilityData returns the content of the compatibility.yaml of the release with the given tag.
+// It is empty if the release doesn't declare one.
+// Release tags don't move, so the content is cached in cacheDir, if set, to save calls to the rate limited Github API.
+func GetReleaseCompatibilityData(ctx context.Context, githubAPI *github.Github, tag, cacheDir string) ([]byte, error) {
+	cachePath := ""
+	if cacheDir != "" {
+		cachePath = filepath.Join(cacheDir, url.PathEscape(tag)+".yaml")
+		if data, err := ioutil.ReadFile(cachePath); err == nil {
+			return data, nil
+		}
+	}
+
+	data, err := githubAPI.GetFileContent(ctx, CompatibilityFileName, tag)
+	if err == github.ErrNotFound {
+		data, err = []byte{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		// The cache only saves calls, the declaration is returned even if it can't be written
+		if err := os.MkdirAll(cacheDir, 0755); err == nil {
+			files.WriteFileAtomic(cachePath, data, 0644)
+		}
+	}
+
+	return data, nil
+}
+
+// Status returns Compatible, Incompatible or CompatibilityUnknown for the given CLI version.
+// A nil Compatibility, meaning no declaration, is always CompatibilityUnknown.
+func (c *Compatibility) Status(cliVersion string) string {
+	if c == nil || (c.CLI.MinVersion == "" && c.CLI.MaxVersion == "") {
+		return CompatibilityUnknown
+	}
+
+	current, err := version.ParseGeneric(cliVersion)
+	if err != nil {
+		return CompatibilityUnknown
+	}
+
+	if c.CLI.MinVersion != "" && current.LessThan(version.MustParseGeneric(c.CLI.MinVersion)) {
+		return Incompatible
+	}
+
+	if c.CLI.MaxVersion != "" && version.MustParseGeneric(c.CLI.MaxVersion).LessThan(current) {
+		return Incompatible
+	}
+
+	return Compatible
+}
+
+// String returns a human friendly version of the range, like ">= 0.18.0, <= 0.19.3"
+func (c *Compatibility) String() string {
+	if c == nil || (c.CLI.MinVersion == "" && c.CLI.MaxVersion == "") {
+		return "-"
+	}
+
+	if c.CLI.MinVersion == "" {
+		return "<= " + c.CLI.MaxVersion
+	}
+
+	if c.CLI.MaxVersion == "" {
+		return ">= " + c.CLI.MinVersion
+	}
+
+	return fmt.Sprintf(">= %v, <= %v", c.CLI.MinVersion, c.CLI.MaxVersion)
+}
@@ -0,0 +1,80 @@
+package manifest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/onepanelio/cli/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCompatibility(t *testing.T) {
+	compatibility, err := ParseCompatibility([]byte("cli:\n  minVersion: 0.18.0\n  maxVersion: 0.19.3\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, ">= 0.18.0, <= 0.19.3", compatibility.String())
+
+	compatibility, err = ParseCompatibility([]byte{})
+	assert.Nil(t, err)
+	assert.Equal(t, "-", compatibility.String())
+
+	_, err = ParseCompatibility([]byte("cli:\n  minVersion: latest\n"))
+	assert.NotNil(t, err)
+}
+
+func TestCompatibility_Status(t *testing.T) {
+	tests := []struct {
+		name          string
+		compatibility *Compatibility
+		cliVersion    string
+		want          string
+	}{
+		{"no declaration", nil, "0.19.0", CompatibilityUnknown},
+		{"within range", &Compatibility{CLI: CLICompatibility{MinVersion: "0.18.0", MaxVersion: "0.19.3"}}, "0.19.0", Compatible},
+		{"range is inclusive", &Compatibility{CLI: CLICompatibility{MinVersion: "0.18.0", MaxVersion: "0.19.3"}}, "0.19.3", Compatible},
+		{"too old", &Compatibility{CLI: CLICompatibility{MinVersion: "0.18.0"}}, "0.17.9", Incompatible},
+		{"too new", &Compatibility{CLI: CLICompatibility{MaxVersion: "0.19.3"}}, "0.20.0", Incompatible},
+		{"dev build", &Compatibility{CLI: CLICompatibility{MinVersion: "0.18.0"}}, "dev", CompatibilityUnknown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, test.compatibility.Status(test.cliVersion))
+		})
+	}
+}
+
+func TestGetReleaseCompatibilityData(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Query().Get("ref") == "v0.19.0" {
+			w.Write([]byte("cli:\n  minVersion: 0.19.0\n"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	cacheDir, err := ioutil.TempDir("", "opctl-compatibility")
+	assert.Nil(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	githubAPI, err := github.New(server.URL)
+	assert.Nil(t, err)
+
+	for i := 0; i < 2; i++ {
+		data, err := GetReleaseCompatibilityData(context.Background(), githubAPI, "v0.19.0", cacheDir)
+		assert.Nil(t, err)
+		assert.Equal(t, "cli:\n  minVersion: 0.19.0\n", string(data))
+
+		data, err = GetReleaseCompatibilityData(context.Background(), githubAPI, "v0.17.0", cacheDir)
+		assert.Nil(t, err)
+		assert.Empty(t, data)
+	}
+
+	assert.Equal(t, 2, requests, "a tag is only fetched once")
+}
@@ -0,0 +1,170 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/onepanelio/cli/files"
+)
+
+// SourceLayered refers to cli_config.yaml value,
+// manifestSources:
+//   - github:
+//   - directory:
+//
+// This indicates manifests are merged, in order, from several sources.
+const SourceLayered = "layered"
+
+// LayeredSource merges the manifests of several sources into one manifest directory.
+// Each layer is moved into its own directory first. Then, in order, layers are merged so that
+// a component base or overlay directory in a later layer replaces the one from earlier layers,
+// and any other file in a later layer, such as under vars, replaces the earlier file.
+type LayeredSource struct {
+	layers      []Source
+	moved       bool   // true if MoveToDirectory has been called
+	destination string // the directory to move the manifest files to
+}
+
+// CreateLayeredSource creates a LayeredSource. The first layer is the base, usually the onepanel manifests.
+func CreateLayeredSource(layers ...Source) (*LayeredSource, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("a layered source needs at least one layer")
+	}
+
+	source := &LayeredSource{
+		layers: layers,
+		moved:  false,
+	}
+
+	return source, nil
+}
+
+// GetSourceType returns the string name of LayeredSource.
+func (l *LayeredSource) GetSourceType() string {
+	return SourceLayered
+}
+
+// GetTag returns the tag of the first layer, which the other layers build on.
+func (l *LayeredSource) GetTag() string {
+	return l.layers[0].GetTag()
+}
+
+// Layers returns the sources that are merged, in order.
+func (l *LayeredSource) Layers() []Source {
+	return l.layers
+}
+
+func (l *LayeredSource) getManifestPath(directoryPath string) string {
+	return filepath.Join(directoryPath, "layered")
+}
+
+func (l *LayeredSource) GetManifestPath() (string, error) {
+	if !l.moved {
+		return "", fmt.Errorf("files not yet moved. Unable to get manifest path")
+	}
+
+	return l.getManifestPath(l.destination), nil
+}
+
+// MoveToDirectory moves every layer into directoryPath/layers/<index> and merges them into directoryPath/layered.
+// The merged directory is always recreated, so changes to any layer are picked up.
+func (l *LayeredSource) MoveToDirectory(ctx context.Context, directoryPath string) error {
+	l.destination = directoryPath
+
+	finalManifestPath := l.getManifestPath(directoryPath)
+	if err := os.RemoveAll(finalManifestPath); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(finalManifestPath, os.ModePerm); err != nil {
+		return err
+	}
+
+	for i, layer := range l.layers {
+		if err := layer.MoveToDirectory(ctx, filepath.Join(directoryPath, "layers", strconv.Itoa(i))); err != nil {
+			return err
+		}
+
+		layerPath, err := layer.GetManifestPath()
+		if err != nil {
+			return err
+		}
+
+		if err := mergeManifestLayer(layerPath, finalManifestPath); err != nil {
+			return fmt.Errorf("merging manifests layer %v: %v", i, err.Error())
+		}
+	}
+
+	l.moved = true
+
+	return nil
+}
+
+// isComponentOrOverlayDirectory returns true if relativePath is a component base, like istio/base,
+// or an overlay, like istio/overlays/gke
+func isComponentOrOverlayDirectory(relativePath string) bool {
+	parts := strings.Split(relativePath, string(os.PathSeparator))
+	last := len(parts) - 1
+
+	if parts[last] == "base" && last > 0 {
+		return true
+	}
+
+	return last > 0 && parts[last-1] == "overlays"
+}
+
+// mergeManifestLayer copies the manifest at layerPath onto the manifest at destination.
+// Component base and overlay directories replace the existing ones as a whole, so no stale resources are left behind.
+// Other files replace the existing file, if any.
+func mergeManifestLayer(layerPath, destination string) error {
+	return filepath.Walk(layerPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(layerPath, path)
+		if err != nil {
+			return err
+		}
+
+		if relativePath == "." {
+			return nil
+		}
+
+		if info.Name() == ".git" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		destinationPath := filepath.Join(destination, relativePath)
+
+		if !info.IsDir() {
+			// Skip symlinks, as CopyDir does.
+			if info.Mode()&os.ModeSymlink != 0 {
+				return nil
+			}
+
+			return files.CopyFile(path, destinationPath)
+		}
+
+		if isComponentOrOverlayDirectory(relativePath) {
+			if err := os.RemoveAll(destinationPath); err != nil {
+				return err
+			}
+
+			if err := files.CopyDir(path, destinationPath); err != nil {
+				return err
+			}
+
+			return filepath.SkipDir
+		}
+
+		return os.MkdirAll(destinationPath, os.ModePerm)
+	})
+}
@@ -0,0 +1,90 @@
+package manifest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onepanelio/cli/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFiles(t *testing.T, root string, contents map[string]string) {
+	for path, content := range contents {
+		fullPath := filepath.Join(root, path)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(fullPath), os.ModePerm))
+		assert.Nil(t, ioutil.WriteFile(fullPath, []byte(content), 0644))
+	}
+}
+
+func Test_isComponentOrOverlayDirectory(t *testing.T) {
+	tests := []struct {
+		relativePath string
+		want         bool
+	}{
+		{"istio", false},
+		{"istio/base", true},
+		{"istio/overlays", false},
+		{"istio/overlays/gke", true},
+		{"common/istio/base", true},
+		{"base", false},
+		{"vars", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.relativePath, func(t *testing.T) {
+			assert.Equal(t, test.want, isComponentOrOverlayDirectory(filepath.FromSlash(test.relativePath)))
+		})
+	}
+}
+
+func TestLayeredSource_MoveToDirectory(t *testing.T) {
+	root, err := ioutil.TempDir("", "opctl-layered")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+
+	onepanel := filepath.Join(root, "onepanel")
+	writeTestFiles(t, onepanel, map[string]string{
+		"istio/base/kustomization.yaml":         "resources: []\n",
+		"istio/overlays/gke/kustomization.yaml": "resources:\n- old.yaml\n",
+		"istio/overlays/gke/old.yaml":           "kind: Old\n",
+		"vars/onepanel-config-map-hidden.env":   "a=1\n",
+	})
+
+	custom := filepath.Join(root, "custom")
+	writeTestFiles(t, custom, map[string]string{
+		"istio/overlays/gke/kustomization.yaml": "resources:\n- new.yaml\n",
+		"istio/overlays/gke/new.yaml":           "kind: New\n",
+		"foo/base/kustomization.yaml":           "resources: []\n",
+		"vars/onepanel-config-map-hidden.env":   "a=2\n",
+	})
+
+	first, err := CreateDirectorySource(onepanel, true)
+	assert.Nil(t, err)
+	second, err := CreateDirectorySource(custom, true)
+	assert.Nil(t, err)
+
+	source, err := CreateLayeredSource(first, second)
+	assert.Nil(t, err)
+	assert.Nil(t, source.MoveToDirectory(context.Background(), filepath.Join(root, ".onepanel")))
+
+	manifestPath, err := source.GetManifestPath()
+	assert.Nil(t, err)
+
+	exists := func(path string) bool {
+		found, err := files.Exists(filepath.Join(manifestPath, path))
+		assert.Nil(t, err)
+		return found
+	}
+
+	assert.True(t, exists("istio/base/kustomization.yaml"), "base of the first layer is kept")
+	assert.True(t, exists("istio/overlays/gke/new.yaml"))
+	assert.False(t, exists("istio/overlays/gke/old.yaml"), "an overlay replaces the earlier one as a whole")
+	assert.True(t, exists("foo/base/kustomization.yaml"), "a component is added")
+
+	content, err := ioutil.ReadFile(filepath.Join(manifestPath, "vars/onepanel-config-map-hidden.env"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a=2\n", string(content))
+}
@@ -0,0 +1,376 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/onepanelio/cli/files"
+	"github.com/onepanelio/cli/template"
+	"github.com/onepanelio/cli/util"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// LintError is an issue that breaks the build of the manifests
+	LintError = "error"
+	// LintWarning is an issue that does not break the build, but is likely a mistake
+	LintWarning = "warning"
+)
+
+// LintIssue is a problem found in the manifests
+type LintIssue struct {
+	Severity string // LintError or LintWarning
+	Path     string // path of the file or directory, relative to the manifests root
+	Line     int    // line in the file, 0 if not applicable
+	Message  string
+}
+
+// String returns the issue in a "severity: path:line: message" format
+func (l *LintIssue) String() string {
+	location := l.Path
+	if l.Line > 0 {
+		location = fmt.Sprintf("%v:%v", l.Path, l.Line)
+	}
+
+	return fmt.Sprintf("%v: %v: %v", l.Severity, location, l.Message)
+}
+
+// LintOptions are the variables the CLI provides to the manifests, besides the ones declared by them
+type LintOptions struct {
+	InjectedVars []string // variables build adds to the params, like applicationCoreImageTag
+	ConsumedVars []string // variables build reads from the params, so they are used even if no manifest references them
+}
+
+// kustomization is the part of a kustomization.yaml file the linter needs
+type kustomization struct {
+	Vars []struct {
+		Name string `yaml:"name"`
+	} `yaml:"vars"`
+}
+
+// linter collects what is defined and referenced in the manifests
+type linter struct {
+	root    string
+	options LintOptions
+	issues  []*LintIssue
+
+	declaredVars map[string]string // vars.yaml keys, in lowerCamelCase, to the path declaring them
+	definedVars  map[string]bool   // every variable that may be referenced
+	usedVars     map[string]bool
+	defaultVars  []string // paths of default-vars.yaml files
+	references   map[string][]template.Reference
+}
+
+// Lint checks the manifests at root for problems that would otherwise only show up when running kustomize,
+// or silently produce broken yaml. Issues are returned sorted by path and line.
+func Lint(root string, options LintOptions) ([]*LintIssue, error) {
+	exists, err := files.Exists(root)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("'%v' does not exist", root)
+	}
+
+	l := &linter{
+		root:         root,
+		options:      options,
+		issues:       make([]*LintIssue, 0),
+		declaredVars: make(map[string]string),
+		definedVars:  make(map[string]bool),
+		usedVars:     make(map[string]bool),
+		defaultVars:  make([]string, 0),
+		references:   make(map[string][]template.Reference),
+	}
+
+	if err := l.lintStructure(); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadFiles(); err != nil {
+		return nil, err
+	}
+
+	l.lintDefaultVars()
+	l.lintReferences()
+	l.lintUnusedVars()
+
+	sort.Slice(l.issues, func(i, j int) bool {
+		if l.issues[i].Path != l.issues[j].Path {
+			return l.issues[i].Path < l.issues[j].Path
+		}
+
+		if l.issues[i].Line != l.issues[j].Line {
+			return l.issues[i].Line < l.issues[j].Line
+		}
+
+		return l.issues[i].Message < l.issues[j].Message
+	})
+
+	return l.issues, nil
+}
+
+func (l *linter) addIssue(severity, path string, line int, message string, args ...interface{}) {
+	l.issues = append(l.issues, &LintIssue{
+		Severity: severity,
+		Path:     path,
+		Line:     line,
+		Message:  fmt.Sprintf(message, args...),
+	})
+}
+
+// nonComponentDirectories are the top level directories of the manifests that hold no component
+var nonComponentDirectories = map[string]bool{
+	"vars":    true,
+	"configs": true,
+}
+
+// lintStructure checks each component has a base, and each overlay belongs to a component with a base.
+// Top level directories that have no component at all, like a component whose base directory is missing, are flagged too.
+func (l *linter) lintStructure() error {
+	m, err := LoadManifest(l.root)
+	if err != nil {
+		return err
+	}
+
+	topLevelComponents := make(map[string]bool)
+	for path := range m.components {
+		topLevelComponents[strings.Split(path, string(os.PathSeparator))[0]] = true
+	}
+
+	entries, err := ioutil.ReadDir(l.root)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || nonComponentDirectories[entry.Name()] {
+			continue
+		}
+
+		if !topLevelComponents[entry.Name()] {
+			l.addIssue(LintError, entry.Name(), 0, "component has no base, '%v' does not exist", filepath.Join(entry.Name(), "base", "kustomization.yaml"))
+		}
+	}
+
+	for _, component := range m.components {
+		base := component.PathWithBase()
+		baseExists, err := files.Exists(filepath.Join(l.root, base))
+		if err != nil {
+			return err
+		}
+
+		if !baseExists {
+			for _, overlay := range component.Overlays() {
+				l.addIssue(LintError, overlay.Path(), 0, "overlay has no component, '%v' does not exist", base)
+			}
+			continue
+		}
+
+		if err := l.lintKustomizationExists(base); err != nil {
+			return err
+		}
+
+		for _, overlay := range component.Overlays() {
+			if err := l.lintKustomizationExists(overlay.Path()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *linter) lintKustomizationExists(directory string) error {
+	exists, err := files.Exists(filepath.Join(l.root, directory, "kustomization.yaml"))
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		l.addIssue(LintError, directory, 0, "missing kustomization.yaml")
+	}
+
+	return nil
+}
+
+// loadFiles goes through every file, loading the variables they define and the references they make
+func (l *linter) loadFiles() error {
+	for _, name := range l.options.InjectedVars {
+		l.definedVars[name] = true
+	}
+
+	for _, name := range l.options.ConsumedVars {
+		l.usedVars[name] = true
+	}
+
+	return filepath.Walk(l.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Name() == ".git" && info.IsDir() {
+			return filepath.SkipDir
+		}
+
+		if info.IsDir() || info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(l.root, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Name() == "vars.yaml":
+			l.loadVarsFile(relativePath, content)
+		case info.Name() == "default-vars.yaml":
+			l.defaultVars = append(l.defaultVars, relativePath)
+			l.loadMappingKeys(relativePath, content)
+		case info.Name() == "kustomization.yaml":
+			l.loadKustomizeVars(relativePath, content)
+		case strings.HasSuffix(info.Name(), ".env"):
+			l.loadEnvFile(content)
+		}
+
+		if references := template.FindReferences(content); len(references) > 0 {
+			l.references[relativePath] = references
+		}
+
+		return nil
+	})
+}
+
+func (l *linter) loadVarsFile(relativePath string, content []byte) {
+	vars, err := util.LoadDynamicYamlFromString(string(content))
+	if err != nil {
+		l.addIssue(LintError, relativePath, 0, "invalid yaml: %v", err.Error())
+		return
+	}
+
+	vars.FlattenRequiredDefault()
+
+	for key := range vars.Flatten(util.LowerCamelCaseFlatMapKeyFormatter) {
+		// Items of lists, like applicationNodePoolOptions[0]Name, are only referenced through the list, with $json
+		if strings.Contains(key, "[") {
+			continue
+		}
+
+		l.definedVars[key] = true
+		if _, ok := l.declaredVars[key]; !ok {
+			l.declaredVars[key] = relativePath
+		}
+	}
+
+	// Lists and maps, like applicationNodePoolOptions, may be referenced with $json
+	structured, err := vars.FlattenToStructured(util.LowerCamelCaseFlatMapKeyFormatter)
+	if err != nil {
+		l.addIssue(LintError, relativePath, 0, "invalid yaml: %v", err.Error())
+		return
+	}
+	for key, value := range structured {
+		l.definedVars[key] = true
+		if _, isList := value.([]interface{}); isList {
+			if _, ok := l.declaredVars[key]; !ok {
+				l.declaredVars[key] = relativePath
+			}
+		}
+	}
+}
+
+// loadMappingKeys defines the keys of a default-vars.yaml file. Their values are checked after all files are loaded.
+func (l *linter) loadMappingKeys(relativePath string, content []byte) {
+	mapping, err := util.LoadDynamicYamlFromString(string(content))
+	if err != nil {
+		l.addIssue(LintError, relativePath, 0, "invalid yaml: %v", err.Error())
+		return
+	}
+
+	for key := range mapping.Flatten(util.LowerCamelCaseFlatMapKeyFormatter) {
+		l.definedVars[key] = true
+	}
+}
+
+func (l *linter) loadKustomizeVars(relativePath string, content []byte) {
+	k := &kustomization{}
+	if err := yaml.Unmarshal(content, k); err != nil {
+		l.addIssue(LintError, relativePath, 0, "invalid yaml: %v", err.Error())
+		return
+	}
+
+	for _, kustomizeVar := range k.Vars {
+		l.definedVars[kustomizeVar.Name] = true
+	}
+}
+
+func (l *linter) loadEnvFile(content []byte) {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if index := strings.Index(line, "="); index > 0 {
+			l.definedVars[line[:index]] = true
+		}
+	}
+}
+
+// lintDefaultVars checks the values of default-vars.yaml files point to known variables
+func (l *linter) lintDefaultVars() {
+	for _, relativePath := range l.defaultVars {
+		mapping, err := util.LoadDynamicYamlFromFile(filepath.Join(l.root, relativePath))
+		if err != nil {
+			continue
+		}
+
+		for key, pair := range mapping.Flatten(util.LowerCamelCaseFlatMapKeyFormatter) {
+			valueKey := util.LowerCamelCaseStringFormat(pair.Value.Value, ".")
+			l.usedVars[valueKey] = true
+
+			if !l.definedVars[valueKey] {
+				l.addIssue(LintError, relativePath, pair.Value.Line, "%v maps to '%v', which is not defined in any vars.yaml", key, pair.Value.Value)
+			}
+		}
+	}
+}
+
+// lintReferences checks every variable reference resolves, and every referenced file exists
+func (l *linter) lintReferences() {
+	for relativePath, references := range l.references {
+		for _, reference := range references {
+			if reference.IsFile() {
+				filePath := filepath.Join(l.root, filepath.Dir(relativePath), reference.Name)
+				if exists, err := files.Exists(filePath); err != nil || !exists {
+					l.addIssue(LintError, relativePath, reference.Line, "%v, the file does not exist", reference.String())
+				}
+				continue
+			}
+
+			l.usedVars[reference.Name] = true
+
+			if !l.definedVars[reference.Name] && !reference.HasDefault() {
+				l.addIssue(LintError, relativePath, reference.Line, "%v is not defined in any vars.yaml", reference.String())
+			}
+		}
+	}
+}
+
+// lintUnusedVars flags vars.yaml keys no file references
+func (l *linter) lintUnusedVars() {
+	for key, relativePath := range l.declaredVars {
+		if !l.usedVars[key] {
+			l.addIssue(LintWarning, relativePath, 0, "%v is not used by any manifest", key)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lintFixture is a small manifests tree with no issues
+var lintFixture = map[string]string{
+	"vars/onepanel-config-map-hidden.env":        "applicationCloudApiPath=/api\n",
+	"common/application/base/vars.yaml":          "application:\n  domain:\n    default: example.com\n",
+	"common/application/base/kustomization.yaml": "resources:\n- config.yaml\n",
+	"common/application/base/config.yaml":        "domain: $(applicationDomain)\napi: $(applicationCloudApiPath)\ntag: $(applicationCoreImageTag)\n",
+	"istio/base/kustomization.yaml":              "resources: []\n",
+	"istio/overlays/gke/kustomization.yaml":      "resources: []\n",
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		removed []string
+		want    []string
+	}{
+		{
+			name: "no issues",
+			want: []string{},
+		},
+		{
+			name:  "component without base",
+			files: map[string]string{"cert-manager/overlays/gke/kustomization.yaml": "resources: []\n"},
+			want:  []string{"error: cert-manager/overlays/gke: overlay has no component, 'cert-manager/base' does not exist"},
+		},
+		{
+			name:  "top level directory without base",
+			files: map[string]string{"argo/deployment.yaml": "kind: Deployment\n"},
+			want:  []string{"error: argo: component has no base, 'argo/base/kustomization.yaml' does not exist"},
+		},
+		{
+			name:    "base without kustomization.yaml",
+			removed: []string{"istio/base/kustomization.yaml"},
+			want:    []string{"error: istio/base: missing kustomization.yaml"},
+		},
+		{
+			name:  "unresolved reference",
+			files: map[string]string{"istio/base/gateway.yaml": "host: $(applicationDomian)\nport: $default(port, 443)\nscript: $file(missing.sh)\n"},
+			want: []string{
+				"error: istio/base/gateway.yaml:1: $(applicationDomian) is not defined in any vars.yaml",
+				"error: istio/base/gateway.yaml:3: $file(missing.sh), the file does not exist",
+			},
+		},
+		{
+			name:  "unused variable",
+			files: map[string]string{"istio/base/vars.yaml": "istio:\n  gateway:\n    default: ingress\n"},
+			want:  []string{"warning: istio/base/vars.yaml: istioGateway is not used by any manifest"},
+		},
+		{
+			name: "list referenced with json",
+			files: map[string]string{
+				"istio/base/vars.yaml":   "metalLb:\n  addresses:\n  - 192.168.99.100-192.168.99.110\n",
+				"istio/base/config.yaml": "addresses: $json(metalLbAddresses)\n",
+			},
+			want: []string{},
+		},
+		{
+			name: "default vars",
+			files: map[string]string{
+				"istio/base/default-vars.yaml": "host: application.domain\nport: application.port\n",
+			},
+			want: []string{"error: istio/base/default-vars.yaml:2: port maps to 'application.port', which is not defined in any vars.yaml"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, err := ioutil.TempDir("", "opctl-lint")
+			assert.Nil(t, err)
+			defer os.RemoveAll(root)
+
+			writeTestFiles(t, root, lintFixture)
+			writeTestFiles(t, root, test.files)
+			for _, path := range test.removed {
+				assert.Nil(t, os.Remove(filepath.Join(root, path)))
+			}
+
+			issues, err := Lint(root, LintOptions{
+				InjectedVars: []string{"applicationCoreImageTag"},
+				ConsumedVars: []string{"applicationDomain"},
+			})
+			assert.Nil(t, err)
+
+			actual := make([]string, 0)
+			for _, issue := range issues {
+				actual = append(actual, issue.String())
+			}
+			assert.Equal(t, test.want, actual)
+		})
+	}
+}
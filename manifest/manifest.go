@@ -2,8 +2,8 @@ package manifest
 
 import (
 	"fmt"
+	"github.com/onepanelio/cli/logging"
 	"github.com/onepanelio/cli/util"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -54,7 +54,7 @@ func LoadManifest(manifestRoot string) (*Manifest, error) {
 
 		relativePath, relErr := filepath.Rel(manifestRoot, path)
 		if relErr != nil {
-			log.Printf("Relative Path: err %v", relErr)
+			logging.Errorf("Relative Path: err %v", relErr)
 			return relErr
 		}
 
@@ -203,7 +203,10 @@ func Validate(manifest *util.DynamicYaml) error {
 		return fmt.Errorf("application.fqdn does not end in application.domain")
 	}
 
-	flatMap := manifest.FlattenToKeyValue(util.AppendDotFlatMapKeyFormatter)
+	flatMap, err := manifest.FlattenToKeyValue(util.AppendDotFlatMapKeyFormatter)
+	if err != nil {
+		return err
+	}
 	mapKeys := []string{}
 	for key := range flatMap {
 		mapKeys = append(mapKeys, key)
@@ -1,10 +1,12 @@
 package manifest
 
 import (
+	"context"
 	"fmt"
+	"github.com/onepanelio/cli/bundle"
 	"github.com/onepanelio/cli/files"
 	"github.com/onepanelio/cli/github"
-	"log"
+	"github.com/onepanelio/cli/logging"
 	"os"
 	"strings"
 )
@@ -20,10 +22,19 @@ const (
 	//  directory:
 	// This indicates manifests should be retrieved from some local directory.
 	SourceDirectory = "directory"
+	// SourceBundle refers to cli_config.yaml value,
+	// manifestSource:
+	//  bundle:
+	// This indicates manifests should be retrieved from a bundle made by opctl bundle create.
+	SourceBundle = "bundle"
+
+	// GithubManifestsRepositoryURL is the Github API url of the onepanelio/manifests repository
+	GithubManifestsRepositoryURL = "https://api.github.com/repos/onepanelio/manifests"
 )
 
 type Source interface {
-	MoveToDirectory(destinationPath string) error
+	// MoveToDirectory puts the manifests under destinationPath. Downloads are canceled along with ctx.
+	MoveToDirectory(ctx context.Context, destinationPath string) error
 	// Get the resulting manifest path. Should only be called after MoveToDirectory
 	GetManifestPath() (string, error)
 	GetTag() string
@@ -58,9 +69,9 @@ func (g *GithubSource) GetTag() string {
 	return g.tag
 }
 
-func (g *GithubSource) getTagDownloadUrl() (string, error) {
+func (g *GithubSource) getTagDownloadUrl(ctx context.Context) (string, error) {
 	if g.release == nil {
-		githubApi, err := github.New("https://api.github.com/repos/onepanelio/manifests")
+		githubApi, err := github.New(GithubManifestsRepositoryURL)
 		if err != nil {
 			return "", err
 		}
@@ -68,12 +79,12 @@ func (g *GithubSource) getTagDownloadUrl() (string, error) {
 		release := &github.Release{}
 
 		if g.tag == "latest" {
-			release, err = githubApi.GetLatestRelease()
+			release, err = githubApi.GetLatestRelease(ctx)
 			if err != nil {
 				return "", err
 			}
 		} else {
-			release, err = githubApi.GetReleaseByTag(g.tag)
+			release, err = githubApi.GetReleaseByTag(ctx, g.tag)
 			if err != nil {
 				return "", err
 			}
@@ -97,7 +108,7 @@ func (g *GithubSource) GetManifestPath() (string, error) {
 	return g.getManifestPath(g.destination), nil
 }
 
-func (g *GithubSource) MoveToDirectory(directoryPath string) error {
+func (g *GithubSource) MoveToDirectory(ctx context.Context, directoryPath string) error {
 	g.destination = directoryPath
 
 	tempManifestsPath := ".temp_manifests"
@@ -105,11 +116,11 @@ func (g *GithubSource) MoveToDirectory(directoryPath string) error {
 	defer func() {
 		_, err := files.DeleteIfExists(tempManifestsPath)
 		if err != nil {
-			log.Printf("[error] Deleting %v: %v", tempManifestsPath, err.Error())
+			logging.Errorf("Deleting %v: %v", tempManifestsPath, err.Error())
 		}
 	}()
 
-	sourceUrl, err := g.getTagDownloadUrl()
+	sourceUrl, err := g.getTagDownloadUrl(ctx)
 	if err != nil {
 		return err
 	}
@@ -130,8 +141,8 @@ func (g *GithubSource) MoveToDirectory(directoryPath string) error {
 		return err
 	}
 
-	if err := files.DownloadFile(tempManifestsPath, sourceUrl); err != nil {
-		log.Printf("[error] Downloading %v: error %v", sourceUrl, err.Error())
+	if err := files.DownloadFile(ctx, tempManifestsPath, sourceUrl); err != nil {
+		logging.Errorf("Downloading %v: error %v", sourceUrl, err.Error())
 		return err
 	}
 
@@ -200,7 +211,7 @@ func (d *DirectorySource) GetManifestPath() (string, error) {
 	return d.getManifestPath(d.destination), nil
 }
 
-func (d *DirectorySource) MoveToDirectory(directoryPath string) error {
+func (d *DirectorySource) MoveToDirectory(ctx context.Context, directoryPath string) error {
 	d.destination = directoryPath
 
 	finalManifestPath := d.getManifestPath(directoryPath)
@@ -227,3 +238,111 @@ func (d *DirectorySource) MoveToDirectory(directoryPath string) error {
 
 	return err
 }
+
+// BundleSource extracts the manifests of a bundle made by opctl bundle create. No network access is needed.
+type BundleSource struct {
+	bundlePath    string
+	metadata      *bundle.Metadata
+	id            string // bundle.ID of the bundle, so the manifests of another bundle are never taken from the cache
+	overrideCache bool   // if true, will override the local cached files.
+	moved         bool   // true if MoveToDirectory has been called
+	destination   string // the directory to move the manifest files to
+}
+
+// CreateBundleSource reads the metadata of the bundle at bundlePath, returning a Source for its manifests
+func CreateBundleSource(bundlePath string, overrideCache bool) (*BundleSource, error) {
+	metadata, err := bundle.ReadMetadata(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := bundle.ID(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &BundleSource{
+		bundlePath:    bundlePath,
+		metadata:      metadata,
+		id:            id,
+		overrideCache: overrideCache,
+		moved:         false,
+	}
+
+	return source, nil
+}
+
+// GetSourceType returns the string name of BundleSource.
+func (b *BundleSource) GetSourceType() string {
+	return SourceBundle
+}
+
+// GetTag returns the tag of the manifests the bundle was created with
+func (b *BundleSource) GetTag() string {
+	if b.metadata.ManifestsVersion == "unknown" {
+		return ""
+	}
+
+	return b.metadata.ManifestsVersion
+}
+
+// getManifestPath returns the directory of the manifests of this bundle, like bundle-v0.21.0-0123456789ab.
+// Bundles created from the same manifests version, but with other files, are extracted to other directories.
+func (b *BundleSource) getManifestPath(directoryPath string) string {
+	name := SourceBundle
+	if tag := b.GetTag(); tag != "" {
+		name += "-" + tag
+	}
+
+	return directoryPath + string(os.PathSeparator) + name + "-" + b.id[:12]
+}
+
+func (b *BundleSource) GetManifestPath() (string, error) {
+	if !b.moved {
+		return "", fmt.Errorf("files not yet moved. Unable to get manifest path")
+	}
+
+	return b.getManifestPath(b.destination), nil
+}
+
+// MoveToDirectory extracts the manifests of the bundle, verifying their checksums.
+// They are extracted to a temporary directory first, so the cache only ever has complete manifests.
+func (b *BundleSource) MoveToDirectory(ctx context.Context, directoryPath string) error {
+	b.destination = directoryPath
+
+	finalManifestPath := b.getManifestPath(directoryPath)
+
+	cacheExists, err := files.Exists(finalManifestPath)
+	if err != nil {
+		return err
+	}
+
+	if !b.overrideCache && cacheExists {
+		b.moved = true
+		return nil
+	}
+
+	extractPath := finalManifestPath + ".partial"
+	if err := os.RemoveAll(extractPath); err != nil {
+		return err
+	}
+
+	if err := bundle.Extract(b.bundlePath, bundle.ManifestsDirectory, extractPath); err != nil {
+		if removeErr := os.RemoveAll(extractPath); removeErr != nil {
+			logging.Errorf("Deleting %v: %v", extractPath, removeErr.Error())
+		}
+		return err
+	}
+
+	if err := os.RemoveAll(finalManifestPath); err != nil {
+		return err
+	}
+
+	if err := os.Rename(extractPath, finalManifestPath); err != nil {
+		return err
+	}
+
+	b.moved = true
+
+	return nil
+}
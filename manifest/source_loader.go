@@ -3,7 +3,6 @@ package manifest
 import (
 	"fmt"
 	"io/ioutil"
-	"os"
 
 	"github.com/onepanelio/cli/config"
 	"github.com/onepanelio/cli/files"
@@ -12,11 +11,15 @@ import (
 
 type SourceConfig struct {
 	ManifestSourceConfig ManifestSourceConfig `yaml:"manifestSource"`
+	// ManifestSources are layered, in order, into a single manifest. Later layers add or override
+	// components and overlays of earlier ones. If set, ManifestSourceConfig is ignored.
+	ManifestSources []ManifestSourceConfig `yaml:"manifestSources,omitempty"`
 }
 
 type ManifestSourceConfig struct {
 	Github    *GithubSourceConfig    `yaml:"github,omitempty"`
 	Directory *DirectorySourceConfig `yaml:"directory,omitempty"`
+	Bundle    *BundleSourceConfig    `yaml:"bundle,omitempty"`
 }
 
 type GithubSourceConfig struct {
@@ -29,6 +32,11 @@ type DirectorySourceConfig struct {
 	OverrideCache *bool  `yaml:"overrideCache,omitempty"` // default is false
 }
 
+type BundleSourceConfig struct {
+	Path          string `yaml:"path"`
+	OverrideCache *bool  `yaml:"overrideCache,omitempty"` // default is false
+}
+
 // This will override the file that already exists at path
 func CreateGithubSourceConfigFile(path string) error {
 	_, err := files.DeleteIfExists(path)
@@ -48,15 +56,30 @@ func CreateGithubSourceConfigFile(path string) error {
 	}
 
 	data, err := yaml.Marshal(sourceConfig)
-
-	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 
-	_, err = file.Write(data)
+	return files.WriteFileAtomic(path, data, 0644)
+}
+
+// CreateBundleSourceConfigFile writes a config file at path using the bundle at bundlePath as the manifest source.
+// This will override the file that already exists at path
+func CreateBundleSourceConfigFile(path, bundlePath string) error {
+	sourceConfig := SourceConfig{
+		ManifestSourceConfig: ManifestSourceConfig{
+			Bundle: &BundleSourceConfig{
+				Path: bundlePath,
+			},
+		},
+	}
+
+	data, err := yaml.Marshal(sourceConfig)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return files.WriteFileAtomic(path, data, 0644)
 }
 
 // Loads and creates the manifest directory in the toPath directory from a config file, configFilePath.
@@ -80,15 +103,49 @@ func LoadManifestSourceFromFileConfig(configFilePath string) (source Source, err
 		return nil, err
 	}
 
-	if config.ManifestSourceConfig.Github != nil {
-		return loadGithubSource(config.ManifestSourceConfig.Github)
+	if len(config.ManifestSources) != 0 {
+		layers := make([]Source, 0)
+		for i := range config.ManifestSources {
+			layer, err := loadSource(&config.ManifestSources[i])
+			if err != nil {
+				return nil, err
+			}
+			if layer == nil {
+				return nil, fmt.Errorf("%v is badly formatted. manifestSources[%v] has no Source Config", configFilePath, i)
+			}
+
+			layers = append(layers, layer)
+		}
+
+		return CreateLayeredSource(layers...)
 	}
 
-	if config.ManifestSourceConfig.Directory != nil {
-		return loadDirectorySource(config.ManifestSourceConfig.Directory)
+	source, err = loadSource(&config.ManifestSourceConfig)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, fmt.Errorf("%v is badly formatted. No Source Config found", configFilePath)
 	}
 
-	return nil, fmt.Errorf("%v is badly formatted. No Source Config found", configFilePath)
+	return source, nil
+}
+
+// loadSource creates the Source described by config. If config describes no Source, nil is returned.
+func loadSource(config *ManifestSourceConfig) (source Source, err error) {
+	if config.Github != nil {
+		return loadGithubSource(config.Github)
+	}
+
+	if config.Directory != nil {
+		return loadDirectorySource(config.Directory)
+	}
+
+	if config.Bundle != nil {
+		return loadBundleSource(config.Bundle)
+	}
+
+	return nil, nil
 }
 
 func loadGithubSource(config *GithubSourceConfig) (source Source, err error) {
@@ -113,3 +170,12 @@ func loadDirectorySource(config *DirectorySourceConfig) (source Source, err erro
 
 	return CreateDirectorySource(config.From, *config.OverrideCache)
 }
+
+func loadBundleSource(config *BundleSourceConfig) (source Source, err error) {
+	if config.OverrideCache == nil {
+		overrideCache := false
+		config.OverrideCache = &overrideCache
+	}
+
+	return CreateBundleSource(config.Path, *config.OverrideCache)
+}
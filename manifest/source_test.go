@@ -0,0 +1,66 @@
+package manifest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onepanelio/cli/bundle"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestBundle writes a bundle of the manifests with contents to bundlePath
+func writeTestBundle(t *testing.T, bundlePath string, contents map[string]string) {
+	manifests, err := ioutil.TempDir("", "manifests")
+	assert.Nil(t, err)
+	defer os.RemoveAll(manifests)
+	writeTestFiles(t, manifests, contents)
+
+	file, err := os.Create(bundlePath)
+	assert.Nil(t, err)
+	defer file.Close()
+
+	writer, err := bundle.NewWriter(file, &bundle.Metadata{ManifestsVersion: "v1.0.0"})
+	assert.Nil(t, err)
+	assert.Nil(t, writer.AddDirectory(bundle.ManifestsDirectory, manifests))
+	assert.Nil(t, writer.Close())
+}
+
+func TestBundleSource_MoveToDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-source")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cache := filepath.Join(dir, "cache")
+	firstPath := filepath.Join(dir, "first.tgz")
+	secondPath := filepath.Join(dir, "second.tgz")
+	writeTestBundle(t, firstPath, map[string]string{"vars.yaml": "first: {}\n"})
+	writeTestBundle(t, secondPath, map[string]string{"vars.yaml": "second: {}\n"})
+
+	first, err := CreateBundleSource(firstPath, false)
+	assert.Nil(t, err)
+	assert.Nil(t, first.MoveToDirectory(context.Background(), cache))
+	firstManifests, err := first.GetManifestPath()
+	assert.Nil(t, err)
+
+	// A bundle of the same manifests version, but with other files, is not taken from the cache
+	second, err := CreateBundleSource(secondPath, false)
+	assert.Nil(t, err)
+	assert.Nil(t, second.MoveToDirectory(context.Background(), cache))
+	secondManifests, err := second.GetManifestPath()
+	assert.Nil(t, err)
+	assert.NotEqual(t, firstManifests, secondManifests)
+
+	content, err := ioutil.ReadFile(filepath.Join(secondManifests, "vars.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "second: {}\n", string(content))
+
+	content, err = ioutil.ReadFile(filepath.Join(firstManifests, "vars.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "first: {}\n", string(content))
+
+	_, err = os.Stat(secondManifests + ".partial")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,128 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+// Format is the format commands print their results in
+type Format string
+
+// Formats of the output
+const (
+	// FormatText is the human friendly output of each command, the default
+	FormatText Format = "text"
+	// FormatJSON prints one json document per command
+	FormatJSON Format = "json"
+	// FormatYAML prints one yaml document per command, with the same fields as FormatJSON
+	FormatYAML Format = "yaml"
+)
+
+// Formats returns the names of the supported formats
+func Formats() []string {
+	return []string{string(FormatText), string(FormatJSON), string(FormatYAML)}
+}
+
+// ParseFormat returns the format named value
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(strings.ToLower(value)); format {
+	case FormatText, FormatJSON, FormatYAML:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unknown output format '%v', expected %v", value, strings.Join(Formats(), ", "))
+}
+
+// Structured is true if the format is meant for programs, and not people
+func (f Format) Structured() bool {
+	return f == FormatJSON || f == FormatYAML
+}
+
+// Write writes document to w in format. Text is specific to each command, so it is not supported.
+func Write(w io.Writer, format Format, document interface{}) error {
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case FormatJSON:
+		content = append(content, '\n')
+	case FormatYAML:
+		content, err = yaml.JSONToYAML(content)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%v documents are not supported", format)
+	}
+
+	_, err = w.Write(content)
+
+	return err
+}
+
+// Results of a resource
+const (
+	ResultApplied = "applied"
+	ResultDeleted = "deleted"
+	// ResultNotFound is for resources that were already gone when deleting them
+	ResultNotFound = "notFound"
+	ResultFailed   = "failed"
+	// ResultSkipped is for resources that were not applied, as something before them failed, or the command was stopped
+	ResultSkipped = "skipped"
+)
+
+// Resource identifies a Kubernetes resource in a document, along with what a command did with it
+type Resource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Phase is the deployment phase of the resource, for apply
+	Phase  string `json:"phase,omitempty"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Conflicts are the fields apply could not set, as they are owned by another field manager
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// ResourcesFromYaml returns the resources of content, a multi document yaml, in order
+func ResourcesFromYaml(content []byte) ([]Resource, error) {
+	nodes, err := kio.FromBytes(content)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]Resource, 0, len(nodes))
+	for _, node := range nodes {
+		meta, err := node.GetMeta()
+		if err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, Resource{
+			APIVersion: meta.APIVersion,
+			Kind:       meta.Kind,
+			Namespace:  meta.Namespace,
+			Name:       meta.Name,
+		})
+	}
+
+	return resources, nil
+}
+
+// SetResult sets the result of each resource, and err, if any
+func SetResult(resources []Resource, result string, err error) {
+	for i := range resources {
+		resources[i].Result = result
+		if err != nil {
+			resources[i].Error = err.Error()
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("JSON")
+	assert.Nil(t, err)
+	assert.Equal(t, FormatJSON, format)
+	assert.True(t, format.Structured())
+	assert.False(t, FormatText.Structured())
+
+	_, err = ParseFormat("xml")
+	assert.NotNil(t, err)
+}
+
+func TestWrite(t *testing.T) {
+	document := struct {
+		Ready bool   `json:"ready"`
+		URL   string `json:"url"`
+	}{Ready: true, URL: "https://onepanel.example.com"}
+
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, Write(buffer, FormatJSON, document))
+	assert.Equal(t, "{\n  \"ready\": true,\n  \"url\": \"https://onepanel.example.com\"\n}\n", buffer.String())
+
+	buffer.Reset()
+	assert.Nil(t, Write(buffer, FormatYAML, document))
+	assert.Equal(t, "ready: true\nurl: https://onepanel.example.com\n", buffer.String())
+
+	assert.NotNil(t, Write(buffer, FormatText, document))
+}
+
+func TestResourcesFromYaml(t *testing.T) {
+	resources, err := ResourcesFromYaml([]byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: onepanel
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: core
+  namespace: onepanel
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, []Resource{
+		{APIVersion: "v1", Kind: "Namespace", Name: "onepanel"},
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "onepanel", Name: "core"},
+	}, resources)
+
+	SetResult(resources, ResultFailed, errors.New("connection refused"))
+	assert.Equal(t, ResultFailed, resources[1].Result)
+	assert.Equal(t, "connection refused", resources[1].Error)
+}
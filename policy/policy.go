@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/internal/resourceutil"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// Finding is a resource that violates a rule
+type Finding struct {
+	Rule     string
+	Severity string
+	Resource string
+	Message  string
+}
+
+// Error is returned when resources violate rules with the error severity
+type Error struct {
+	Findings []Finding
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v policy violations with the %v severity:\n%v", len(e.Findings), config.PolicySeverityError, report(e.Findings))
+}
+
+// Engine checks built resources against the rules, and keeps what it finds for the report
+type Engine struct {
+	rules      []Rule
+	severities map[string]string // by rule name
+	exclude    []config.PolicyExclusion
+	Findings   []Finding
+}
+
+// NewEngine returns an engine with the built-in rules, using the severities and exclusions of policy, which may be nil
+func NewEngine(policy *config.Policy) (*Engine, error) {
+	engine := &Engine{
+		rules:      Rules(),
+		severities: make(map[string]string),
+		Findings:   make([]Finding, 0),
+	}
+
+	names := make([]string, 0)
+	for _, rule := range engine.rules {
+		names = append(names, rule.Name)
+		engine.severities[rule.Name] = rule.DefaultSeverity
+	}
+
+	if policy == nil {
+		return engine, nil
+	}
+
+	for name, severity := range policy.Rules {
+		if _, ok := engine.severities[name]; !ok {
+			return nil, fmt.Errorf("unknown policy rule '%v', expected one of %v", name, strings.Join(names, ", "))
+		}
+		engine.severities[name] = severity
+	}
+
+	for _, exclusion := range policy.Exclude {
+		if _, ok := engine.severities[exclusion.Rule]; !ok {
+			return nil, fmt.Errorf("unknown policy rule '%v' in exclude, expected one of %v", exclusion.Rule, strings.Join(names, ", "))
+		}
+	}
+	engine.exclude = policy.Exclude
+
+	return engine, nil
+}
+
+// excluded returns true if an exclusion stops rule from checking r
+func (e *Engine) excluded(rule string, r *resource.Resource) bool {
+	for _, exclusion := range e.exclude {
+		if exclusion.Rule == rule &&
+			(exclusion.Kind == "" || exclusion.Kind == r.GetKind()) &&
+			(exclusion.Namespace == "" || exclusion.Namespace == r.GetNamespace()) &&
+			(exclusion.Name == "" || exclusion.Name == r.GetName()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check checks the resources of rm, adding what it finds to Findings.
+// If a rule with the error severity is violated, an *Error with those violations is returned.
+func (e *Engine) Check(rm resmap.ResMap) error {
+	errors := make([]Finding, 0)
+
+	for _, r := range rm.Resources() {
+		for _, rule := range e.rules {
+			severity := e.severities[rule.Name]
+			if severity == config.PolicySeverityOff || e.excluded(rule.Name, r) {
+				continue
+			}
+
+			for _, message := range rule.check(r) {
+				finding := Finding{
+					Rule:     rule.Name,
+					Severity: severity,
+					Resource: resourceutil.Describe(r),
+					Message:  message,
+				}
+
+				e.Findings = append(e.Findings, finding)
+				if finding.Severity == config.PolicySeverityError {
+					errors = append(errors, finding)
+				}
+			}
+		}
+	}
+
+	if len(errors) != 0 {
+		return &Error{Findings: errors}
+	}
+
+	return nil
+}
+
+// Report returns the findings as a table, errors first, or an empty string if there are none
+func (e *Engine) Report() string {
+	if len(e.Findings) == 0 {
+		return ""
+	}
+
+	counts := make(map[string]int)
+	for _, finding := range e.Findings {
+		counts[finding.Severity]++
+	}
+
+	return fmt.Sprintf("Policy report: %v errors, %v warnings\n%v", counts[config.PolicySeverityError], counts[config.PolicySeverityWarning], report(e.Findings))
+}
+
+// report returns findings as a table, errors first
+func report(findings []Finding) string {
+	sorted := append([]Finding{}, findings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Severity == config.PolicySeverityError && sorted[j].Severity != config.PolicySeverityError
+	})
+
+	builder := &strings.Builder{}
+	w := tabwriter.NewWriter(builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tRULE\tRESOURCE\tMESSAGE")
+	for _, finding := range sorted {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", finding.Severity, finding.Rule, finding.Resource, finding.Message)
+	}
+	w.Flush()
+
+	return builder.String()
+}
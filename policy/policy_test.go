@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/onepanelio/cli/config"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+const resources = `apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: fluentd
+  namespace: logging
+spec:
+  template:
+    spec:
+      containers:
+      - name: fluentd
+        image: fluent/fluentd
+        securityContext:
+          privileged: true
+        volumeMounts:
+        - name: logs
+          mountPath: /var/log
+      volumes:
+      - name: logs
+        hostPath:
+          path: /var/log
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: core
+  namespace: onepanel
+spec:
+  template:
+    spec:
+      containers:
+      - name: core
+        image: onepanel/core:v1.0.0
+        resources:
+          limits:
+            memory: 1Gi
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: istio-ingressgateway
+  namespace: istio-system
+spec:
+  type: LoadBalancer
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: minio
+  namespace: onepanel
+spec:
+  type: LoadBalancer
+`
+
+func buildResMap(t *testing.T, content string) resmap.ResMap {
+	fSys := filesys.MakeFsInMemory()
+	assert.Nil(t, fSys.WriteFile("/build/resources.yaml", []byte(content)))
+	assert.Nil(t, fSys.WriteFile("/build/kustomization.yaml", []byte("resources:\n- resources.yaml\n")))
+
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, "/build")
+	assert.Nil(t, err)
+
+	return rm
+}
+
+func TestEngine_Check(t *testing.T) {
+	engine, err := NewEngine(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, engine.Check(buildResMap(t, resources)))
+
+	rules := make([]string, 0)
+	for _, finding := range engine.Findings {
+		assert.Equal(t, config.PolicySeverityWarning, finding.Severity)
+		rules = append(rules, finding.Rule)
+	}
+	assert.Equal(t, []string{RuleResourceLimits, RulePrivileged, RuleHostPath, RuleLatestTag, RuleLoadBalancerService}, rules)
+	assert.Equal(t, "Service onepanel/minio", engine.Findings[4].Resource)
+}
+
+func TestEngine_Check_Severities(t *testing.T) {
+	engine, err := NewEngine(&config.Policy{
+		Rules: map[string]string{
+			RuleLatestTag:      config.PolicySeverityError,
+			RuleResourceLimits: config.PolicySeverityOff,
+		},
+		Exclude: []config.PolicyExclusion{
+			{Rule: RuleHostPath, Kind: "DaemonSet", Namespace: "logging"},
+		},
+	})
+	assert.Nil(t, err)
+
+	err = engine.Check(buildResMap(t, resources))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(err.(*Error).Findings))
+	assert.Equal(t, "container fluentd uses fluent/fluentd, with the latest tag", err.(*Error).Findings[0].Message)
+	assert.Equal(t, 3, len(engine.Findings))
+	assert.Contains(t, engine.Report(), "Policy report: 1 errors, 2 warnings")
+
+	_, err = NewEngine(&config.Policy{Rules: map[string]string{"no-such-rule": config.PolicySeverityError}})
+	assert.NotNil(t, err)
+}
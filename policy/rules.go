@@ -0,0 +1,185 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/onepanelio/cli/config"
+	"github.com/onepanelio/cli/images"
+	"github.com/onepanelio/cli/internal/resourceutil"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// Names of the built-in rules
+const (
+	RuleResourceLimits      = "resource-limits"
+	RulePrivileged          = "privileged"
+	RuleHostPath            = "host-path"
+	RuleLatestTag           = "latest-tag"
+	RuleLoadBalancerService = "loadbalancer-service"
+)
+
+// Rule checks each built resource
+type Rule struct {
+	Name            string
+	Description     string
+	DefaultSeverity string
+	// check returns why r violates the rule, if it does
+	check func(r *resource.Resource) []string
+}
+
+// Rules returns the built-in rules
+func Rules() []Rule {
+	return []Rule{
+		{
+			Name:            RuleResourceLimits,
+			Description:     "Containers have resource limits",
+			DefaultSeverity: config.PolicySeverityWarning,
+			check:           checkResourceLimits,
+		},
+		{
+			Name:            RulePrivileged,
+			Description:     "Containers are not privileged",
+			DefaultSeverity: config.PolicySeverityWarning,
+			check:           checkPrivileged,
+		},
+		{
+			Name:            RuleHostPath,
+			Description:     "Pods do not mount hostPath volumes",
+			DefaultSeverity: config.PolicySeverityWarning,
+			check:           checkHostPath,
+		},
+		{
+			Name:            RuleLatestTag,
+			Description:     "Images have a tag other than latest, or a digest",
+			DefaultSeverity: config.PolicySeverityWarning,
+			check:           checkLatestTag,
+		},
+		{
+			Name:            RuleLoadBalancerService,
+			Description:     "Services of type LoadBalancer are only in istio-system",
+			DefaultSeverity: config.PolicySeverityWarning,
+			check:           checkLoadBalancerService,
+		},
+	}
+}
+
+// forEachContainer calls visit with each container and init container of the pod specs in r
+func forEachContainer(r *resource.Resource, visit func(container *yaml.Node, name string)) {
+	forEachPodSpec(r, func(podSpec *yaml.Node) {
+		for _, key := range []string{"initContainers", "containers"} {
+			containers := resourceutil.MappingValue(podSpec, key)
+			if containers == nil || containers.Kind != yaml.SequenceNode {
+				continue
+			}
+
+			for _, container := range containers.Content {
+				name := ""
+				if nameNode := resourceutil.MappingValue(container, "name"); nameNode != nil {
+					name = nameNode.Value
+				}
+				visit(container, name)
+			}
+		}
+	})
+}
+
+// forEachPodSpec calls visit with each pod spec in r, the mappings with a list of containers.
+// CustomResourceDefinitions are skipped, their schemas have containers too.
+func forEachPodSpec(r *resource.Resource, visit func(podSpec *yaml.Node)) {
+	if r.GetKind() == "CustomResourceDefinition" {
+		return
+	}
+
+	resourceutil.WalkMappings(r.YNode(), func(mapping *yaml.Node) {
+		if containers := resourceutil.MappingValue(mapping, "containers"); containers != nil && containers.Kind == yaml.SequenceNode {
+			visit(mapping)
+		}
+	})
+}
+
+func checkResourceLimits(r *resource.Resource) []string {
+	result := make([]string, 0)
+	forEachContainer(r, func(container *yaml.Node, name string) {
+		limits := resourceutil.MappingValue(resourceutil.MappingValue(container, "resources"), "limits")
+		if limits == nil || limits.Kind != yaml.MappingNode || len(limits.Content) == 0 {
+			result = append(result, fmt.Sprintf("container %v has no resource limits", name))
+		}
+	})
+
+	return result
+}
+
+func checkPrivileged(r *resource.Resource) []string {
+	result := make([]string, 0)
+	forEachContainer(r, func(container *yaml.Node, name string) {
+		privileged := resourceutil.MappingValue(resourceutil.MappingValue(container, "securityContext"), "privileged")
+		if privileged != nil && privileged.Value == "true" {
+			result = append(result, fmt.Sprintf("container %v is privileged", name))
+		}
+	})
+
+	return result
+}
+
+func checkHostPath(r *resource.Resource) []string {
+	result := make([]string, 0)
+	forEachPodSpec(r, func(podSpec *yaml.Node) {
+		volumes := resourceutil.MappingValue(podSpec, "volumes")
+		if volumes == nil || volumes.Kind != yaml.SequenceNode {
+			return
+		}
+
+		for _, volume := range volumes.Content {
+			hostPath := resourceutil.MappingValue(volume, "hostPath")
+			if hostPath == nil {
+				continue
+			}
+
+			name, path := "", ""
+			if nameNode := resourceutil.MappingValue(volume, "name"); nameNode != nil {
+				name = nameNode.Value
+			}
+			if pathNode := resourceutil.MappingValue(hostPath, "path"); pathNode != nil {
+				path = pathNode.Value
+			}
+			result = append(result, fmt.Sprintf("volume %v mounts %v of the host", name, path))
+		}
+	})
+
+	return result
+}
+
+func checkLatestTag(r *resource.Resource) []string {
+	result := make([]string, 0)
+	forEachContainer(r, func(container *yaml.Node, name string) {
+		image := resourceutil.MappingValue(container, "image")
+		if image == nil {
+			return
+		}
+
+		ref, err := images.ParseReference(image.Value)
+		if err != nil || ref.Digest != "" {
+			return
+		}
+
+		if ref.Tag == "" || ref.Tag == "latest" {
+			result = append(result, fmt.Sprintf("container %v uses %v, with the latest tag", name, image.Value))
+		}
+	})
+
+	return result
+}
+
+func checkLoadBalancerService(r *resource.Resource) []string {
+	if r.GetKind() != "Service" || r.GetNamespace() == "istio-system" {
+		return nil
+	}
+
+	serviceType := resourceutil.MappingValue(resourceutil.MappingValue(r.YNode(), "spec"), "type")
+	if serviceType == nil || serviceType.Value != "LoadBalancer" {
+		return nil
+	}
+
+	return []string{"Service of type LoadBalancer outside istio-system"}
+}
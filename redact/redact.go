@@ -0,0 +1,171 @@
+package redact
+
+import (
+	"encoding/base64"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/onepanelio/cli/internal/resourceutil"
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+// Placeholder replaces the redacted values
+const Placeholder = "REDACTED"
+
+// minLength is the length generated values need to be redacted. Shorter values, like true, would redact unrelated text.
+// Configured secrets are redacted whatever their length, as a short password is still a password.
+const minLength = 6
+
+// Redactor replaces secret values, and their base64 encoding, with Placeholder
+type Redactor struct {
+	values []string // longest first, so a value containing another is redacted whole
+}
+
+// NewRedactor returns a redactor for values
+func NewRedactor(values ...string) *Redactor {
+	r := &Redactor{}
+	r.Add(values...)
+
+	return r
+}
+
+// Add adds values to redact, whatever their length. Empty values are ignored.
+func (r *Redactor) Add(values ...string) {
+	r.add(0, values)
+}
+
+// AddGenerated adds values opctl generated to redact. Values shorter than 6 characters are ignored.
+func (r *Redactor) AddGenerated(values ...string) {
+	r.add(minLength, values)
+}
+
+// add adds values of at least length characters to redact
+func (r *Redactor) add(length int, values []string) {
+	existing := make(map[string]bool)
+	for _, value := range r.values {
+		existing[value] = true
+	}
+
+	for _, value := range values {
+		if value == "" || len(value) < length {
+			continue
+		}
+
+		for _, variant := range []string{value, base64.StdEncoding.EncodeToString([]byte(value))} {
+			if !existing[variant] {
+				existing[variant] = true
+				r.values = append(r.values, variant)
+			}
+		}
+	}
+
+	sort.SliceStable(r.values, func(i, j int) bool {
+		return len(r.values[i]) > len(r.values[j])
+	})
+}
+
+// String returns s with the values replaced wherever they appear, for logs
+func (r *Redactor) String(s string) string {
+	for _, value := range r.values {
+		s = strings.ReplaceAll(s, value, Placeholder)
+	}
+
+	return s
+}
+
+// ResMap redacts the data and stringData of the Secrets in rm, and the values anywhere in rm where they are whole tokens
+func (r *Redactor) ResMap(rm resmap.ResMap) {
+	for _, res := range rm.Resources() {
+		node := res.YNode()
+
+		if res.GetKind() == "Secret" {
+			for _, key := range []string{"data", "stringData"} {
+				data := resourceutil.MappingValue(node, key)
+				if data == nil || data.Kind != yaml.MappingNode {
+					continue
+				}
+
+				for i := 1; i < len(data.Content); i += 2 {
+					data.Content[i].Value = Placeholder
+					data.Content[i].Style = 0
+				}
+			}
+		}
+
+		r.node(node)
+	}
+}
+
+// node redacts the values from the scalars under node, where they are whole tokens. Keys of mappings are kept.
+func (r *Redactor) node(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		for _, value := range r.values {
+			node.Value = replaceTokens(node.Value, value)
+		}
+		return
+	}
+
+	for i, child := range node.Content {
+		// Keys of mappings are at even indexes
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+
+		r.node(child)
+	}
+}
+
+// The characters a token of a scalar starts after, and ends before. A value is only redacted from resources where it is
+// a whole token, like in password=value, an embedded "key": "value" or the password of user:value@host,
+// so a short secret like minio does not redact image names, hosts or keys that contain it.
+const (
+	tokenStarts = " \t\r\n\"'=,;:"
+	tokenEnds   = " \t\r\n\"'=,;@"
+)
+
+// replaceTokens returns s with value replaced where it is a whole token
+func replaceTokens(s, value string) string {
+	result := strings.Builder{}
+	written := 0
+	for from := 0; from < len(s); {
+		i := strings.Index(s[from:], value)
+		if i < 0 {
+			break
+		}
+
+		start, end := from+i, from+i+len(value)
+		if (start == 0 || strings.IndexByte(tokenStarts, s[start-1]) >= 0) && (end == len(s) || strings.IndexByte(tokenEnds, s[end]) >= 0) {
+			result.WriteString(s[written:start])
+			result.WriteString(Placeholder)
+			written, from = end, end
+			continue
+		}
+
+		from = start + 1
+	}
+	result.WriteString(s[written:])
+
+	return result.String()
+}
+
+// writer redacts what is written to it
+type writer struct {
+	redactor *Redactor
+	w        io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := w.w.Write([]byte(w.redactor.String(string(p)))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Writer returns a writer that redacts what is written to it before writing it to w, for logs.
+// Values are only redacted when written whole, in one call, as log does.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &writer{redactor: r, w: w}
+}
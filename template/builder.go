@@ -1,11 +1,14 @@
 package template
 
 import (
+	"fmt"
 	"github.com/onepanelio/cli/config"
 	"github.com/onepanelio/cli/files"
-	"log"
+	"github.com/onepanelio/cli/logging"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sigs.k8s.io/kustomize/api/filesys"
 	"sort"
 	"strings"
 )
@@ -60,13 +63,114 @@ type VarItem struct {
 }
 
 type Kustomize struct {
-	ApiVersion       string          `yaml:"apiVersion"`
-	Kind             string          `yaml:"kind"`
-	Resources        []string        `yaml:"resources"`
-	Configurations   []string        `yaml:"configurations"`
-	ConfigMapItems   []ConfigMapItem `yaml:"configMapGenerator"`
-	GeneratorOptions GeneratorItem   `yaml:"generatorOptions"`
-	Vars             []VarItem       `yaml:"vars"`
+	ApiVersion            string                 `yaml:"apiVersion"`
+	Kind                  string                 `yaml:"kind"`
+	Resources             []string               `yaml:"resources"`
+	Configurations        []string               `yaml:"configurations"`
+	ConfigMapItems        []ConfigMapItem        `yaml:"configMapGenerator"`
+	GeneratorOptions      GeneratorItem          `yaml:"generatorOptions"`
+	Vars                  []VarItem              `yaml:"vars"`
+	PatchesStrategicMerge []string               `yaml:"patchesStrategicMerge,omitempty"`
+	PatchesJson6902       []config.PatchJson6902 `yaml:"patchesJson6902,omitempty"`
+	Images                []config.Image         `yaml:"images,omitempty"`
+	CommonLabels          map[string]string      `yaml:"commonLabels,omitempty"`
+	CommonAnnotations     map[string]string      `yaml:"commonAnnotations,omitempty"`
+	Namespace             string                 `yaml:"namespace,omitempty"`
+}
+
+// localDirectory is where files from outside of the manifests are copied to, relative to the kustomization root
+const localDirectory = "local"
+
+// extraDirectory is where resource directories from outside of the manifests are copied to, relative to the kustomization root
+const extraDirectory = "extra"
+
+// Localize copies any file or resource directory the kustomization references by absolute path on disk,
+// such as user patches and extra components, under root in fSys.
+// The references are updated to the copied path, relative to root.
+// kustomize only loads files under the kustomization root, so this is required before running it.
+func (k *Kustomize) Localize(fSys filesys.FileSystem, root string) error {
+	for i, resource := range k.Resources {
+		if !filepath.IsAbs(resource) {
+			continue
+		}
+
+		localPath := filepath.Join(extraDirectory, filepath.Base(resource))
+		destination := filepath.Join(root, localPath)
+		if fSys.Exists(destination) {
+			return fmt.Errorf("unable to copy %v, %v already exists", resource, destination)
+		}
+
+		if err := files.CopyDirToFileSystem(resource, fSys, destination); err != nil {
+			return err
+		}
+		k.Resources[i] = localPath
+	}
+
+	copied := 0
+	localize := func(path string) (string, error) {
+		if !filepath.IsAbs(path) {
+			return path, nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		copied++
+		localPath := filepath.Join(localDirectory, fmt.Sprintf("%v-%v", copied, filepath.Base(path)))
+		if err := fSys.WriteFile(filepath.Join(root, localPath), content); err != nil {
+			return "", err
+		}
+
+		return localPath, nil
+	}
+
+	for i, patch := range k.PatchesStrategicMerge {
+		localPath, err := localize(patch)
+		if err != nil {
+			return err
+		}
+		k.PatchesStrategicMerge[i] = localPath
+	}
+
+	for i := range k.PatchesJson6902 {
+		localPath, err := localize(k.PatchesJson6902[i].Path)
+		if err != nil {
+			return err
+		}
+		k.PatchesJson6902[i].Path = localPath
+	}
+
+	return nil
+}
+
+// SourcePaths returns the files and directories, relative to the kustomization root, the kustomization is built from.
+// For a resource like istio/overlays/gke, the whole istio directory is included, as overlays use the base.
+func (k *Kustomize) SourcePaths() []string {
+	paths := make([]string, 0)
+
+	for _, resource := range k.Resources {
+		parts := strings.Split(filepath.Clean(resource), string(os.PathSeparator))
+		for i, part := range parts {
+			if part == "base" || part == "overlays" {
+				parts = parts[:i]
+				break
+			}
+		}
+		paths = append(paths, strings.Join(parts, string(os.PathSeparator)))
+	}
+
+	for _, configMapItem := range k.ConfigMapItems {
+		paths = append(paths, configMapItem.Envs...)
+	}
+
+	paths = append(paths, k.PatchesStrategicMerge...)
+	for _, patch := range k.PatchesJson6902 {
+		paths = append(paths, patch.Path)
+	}
+
+	return paths
 }
 
 type Builder struct {
@@ -136,7 +240,7 @@ func (b *Builder) Build() error {
 
 		relativePath, relErr := filepath.Rel(commonComponentsRoot, path)
 		if relErr != nil {
-			log.Printf("Relative Path: err %v", relErr)
+			logging.Errorf("Relative Path: err %v", relErr)
 			return relErr
 		}
 
@@ -176,7 +280,7 @@ func (b *Builder) Build() error {
 
 		relativePath, relErr := filepath.Rel(root, path)
 		if relErr != nil {
-			log.Printf("Relative Path: err %v", relErr)
+			logging.Errorf("Relative Path: err %v", relErr)
 			return relErr
 		}
 
@@ -229,7 +333,14 @@ func (b *Builder) Template() Kustomize {
 func (b *Builder) flattenSources() []Source {
 	sources := make([]Source, 0)
 
+	// Sorted, so sources with the same order are always in the same place in the kustomization
+	keys := make([]string, 0, len(b.Sources))
 	for key := range b.Sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
 		for i := range b.Sources[key] {
 			source := b.Sources[key][i]
 			sources = append(sources, source)
@@ -0,0 +1,77 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onepanelio/cli/config"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+func TestKustomize_Localize_patches(t *testing.T) {
+	patchesDir, err := ioutil.TempDir("", "opctl-patches")
+	assert.Nil(t, err)
+	defer os.RemoveAll(patchesDir)
+
+	strategicMerge := filepath.Join(patchesDir, "replicas.yaml")
+	assert.Nil(t, ioutil.WriteFile(strategicMerge, []byte("kind: Deployment\n"), 0644))
+	json6902 := filepath.Join(patchesDir, "patch.yaml")
+	assert.Nil(t, ioutil.WriteFile(json6902, []byte("- op: remove\n"), 0644))
+
+	k := &Kustomize{
+		Resources:             []string{"common/application/base"},
+		PatchesStrategicMerge: []string{strategicMerge, "in-manifests.yaml"},
+		PatchesJson6902:       []config.PatchJson6902{{Path: json6902}},
+	}
+
+	fSys := filesys.MakeFsInMemory()
+	assert.Nil(t, k.Localize(fSys, "/manifests"))
+
+	assert.Equal(t, []string{"common/application/base"}, k.Resources)
+	assert.Equal(t, []string{filepath.Join("local", "1-replicas.yaml"), "in-manifests.yaml"}, k.PatchesStrategicMerge)
+	assert.Equal(t, filepath.Join("local", "2-patch.yaml"), k.PatchesJson6902[0].Path)
+
+	content, err := fSys.ReadFile("/manifests/local/1-replicas.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "kind: Deployment\n", string(content))
+
+	content, err = fSys.ReadFile("/manifests/local/2-patch.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "- op: remove\n", string(content))
+}
+
+func TestKustomize_Localize_missingPatch(t *testing.T) {
+	k := &Kustomize{
+		PatchesStrategicMerge: []string{"/does/not/exist.yaml"},
+	}
+
+	assert.NotNil(t, k.Localize(filesys.MakeFsInMemory(), "/manifests"))
+}
+
+func TestKustomize_Localize_extraComponents(t *testing.T) {
+	componentDir, err := ioutil.TempDir("", "opctl-extra")
+	assert.Nil(t, err)
+	defer os.RemoveAll(componentDir)
+
+	component := filepath.Join(componentDir, "monitoring")
+	assert.Nil(t, os.MkdirAll(filepath.Join(component, "base"), os.ModePerm))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(component, "base", "kustomization.yaml"), []byte("resources: []\n"), 0644))
+
+	k := &Kustomize{
+		Resources: []string{"common/application/base", component},
+	}
+
+	fSys := filesys.MakeFsInMemory()
+	assert.Nil(t, k.Localize(fSys, "/manifests"))
+	assert.Equal(t, []string{"common/application/base", filepath.Join("extra", "monitoring")}, k.Resources)
+
+	content, err := fSys.ReadFile("/manifests/extra/monitoring/base/kustomization.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, "resources: []\n", string(content))
+
+	k.Resources = []string{component}
+	assert.NotNil(t, k.Localize(fSys, "/manifests"), "an existing extra directory is not overwritten")
+}
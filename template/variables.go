@@ -0,0 +1,500 @@
+package template
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+// The functions a manifest can reference a variable with.
+const (
+	FunctionValue   = ""        // $(name) is the value. Integers are quoted so they stay strings.
+	FunctionRaw     = "raw"     // $raw(name) is the value as is
+	FunctionBase64  = "base64"  // $base64(name) is the base64 encoded value
+	FunctionDefault = "default" // $default(name, value) is $(name), or value if the variable is not set
+	FunctionSha256  = "sha256"  // $sha256(name) is the hex encoded sha256 of the value, useful to roll pods when it changes
+	FunctionJSON    = "json"    // $json(name) is the value as json, including structured params like application.nodePool
+	FunctionQuote   = "quote"   // $quote(name) is the value as a double quoted string
+	FunctionFile    = "file"    // $file(path) is the content of the file, relative to the file referencing it, indented to the column of the reference
+)
+
+// VariableFunctions are the supported ways to reference a variable in the manifests.
+var VariableFunctions = []string{
+	FunctionValue,
+	FunctionRaw,
+	FunctionBase64,
+	FunctionDefault,
+	FunctionSha256,
+	FunctionJSON,
+	FunctionQuote,
+	FunctionFile,
+}
+
+// Reference is a variable reference found in a manifest file, like $(applicationDomain)
+type Reference struct {
+	Function string // the function used, "" for $(name)
+	Name     string // the variable name, or the path for $file(path)
+	Default  string // the value of $default(name, value)
+	Line     int    // the line the reference is on, starting at 1
+	Start    int    // the index of the $ in the content
+	End      int    // the index after the closing )
+}
+
+// String returns the reference as it is written in the manifests
+func (r Reference) String() string {
+	if r.Function == FunctionDefault {
+		return fmt.Sprintf("$%v(%v, %v)", r.Function, r.Name, r.Default)
+	}
+
+	return fmt.Sprintf("$%v(%v)", r.Function, r.Name)
+}
+
+// IsFile returns true if the reference is to a file, rather than a variable
+func (r Reference) IsFile() bool {
+	return r.Function == FunctionFile
+}
+
+// HasDefault returns true if the reference has a value to use when the variable is not set
+func (r Reference) HasDefault() bool {
+	return r.Function == FunctionDefault
+}
+
+// isVariableFunction returns true if function is one of VariableFunctions
+func isVariableFunction(function string) bool {
+	for _, known := range VariableFunctions {
+		if function == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isFunctionNameCharacter returns true if c may be part of a function name, like base64
+func isFunctionNameCharacter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+// isVariableNameCharacter returns true if c may be part of a variable name
+func isVariableNameCharacter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-' || c == '.'
+}
+
+// isValidName returns true if name may be referenced with function
+func isValidName(function, name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		if !isVariableNameCharacter(name[i]) && !(function == FunctionFile && name[i] == '/') {
+			return false
+		}
+	}
+
+	// Kubernetes environment variable references, like $(POD_NAME), are left to Kubernetes
+	if function != FunctionFile && strings.ToLower(name[:1]) != name[:1] {
+		return false
+	}
+
+	return true
+}
+
+// unquote removes matching single or double quotes around value, if any
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
+
+// FindReferences scans content once and returns every variable reference in it, in order.
+// Names starting with an uppercase letter, like $(POD_NAME), are Kubernetes environment variable references and are skipped,
+// as are references escaped with $$.
+func FindReferences(content []byte) []Reference {
+	references := make([]Reference, 0)
+	line := 1
+
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			line++
+			continue
+		}
+
+		if content[i] != '$' {
+			continue
+		}
+
+		// $$(name) is the Kubernetes escape for $(name)
+		if i+1 < len(content) && content[i+1] == '$' {
+			i++
+			continue
+		}
+
+		openIndex := i + 1
+		for openIndex < len(content) && isFunctionNameCharacter(content[openIndex]) {
+			openIndex++
+		}
+		if openIndex >= len(content) || content[openIndex] != '(' {
+			continue
+		}
+
+		function := string(content[i+1 : openIndex])
+		if !isVariableFunction(function) {
+			continue
+		}
+
+		closeIndex := openIndex + 1
+		for closeIndex < len(content) && content[closeIndex] != ')' && content[closeIndex] != '\n' {
+			closeIndex++
+		}
+		if closeIndex >= len(content) || content[closeIndex] != ')' {
+			continue
+		}
+
+		reference := Reference{
+			Function: function,
+			Name:     string(content[openIndex+1 : closeIndex]),
+			Line:     line,
+			Start:    i,
+			End:      closeIndex + 1,
+		}
+
+		if function == FunctionDefault {
+			commaIndex := strings.Index(reference.Name, ",")
+			if commaIndex < 0 {
+				continue
+			}
+			reference.Default = unquote(strings.TrimSpace(reference.Name[commaIndex+1:]))
+			reference.Name = strings.TrimSpace(reference.Name[:commaIndex])
+		}
+
+		if !isValidName(function, reference.Name) {
+			continue
+		}
+
+		references = append(references, reference)
+
+		i = closeIndex
+	}
+
+	return references
+}
+
+// UnresolvedReference is a variable reference with no value, and the file it is in
+type UnresolvedReference struct {
+	Path      string
+	Reference Reference
+}
+
+// UnresolvedReferencesError is returned when rendering finds references with no value
+type UnresolvedReferencesError struct {
+	References []UnresolvedReference
+}
+
+// Error lists every unresolved reference, with its file and line
+func (u *UnresolvedReferencesError) Error() string {
+	lines := make([]string, 0)
+	for _, unresolved := range u.References {
+		lines = append(lines, fmt.Sprintf("  %v:%v: %v", unresolved.Path, unresolved.Reference.Line, unresolved.Reference.String()))
+	}
+
+	return "unresolved variables, make sure they are set in your params.yaml:\n" + strings.Join(lines, "\n")
+}
+
+// Renderer substitutes variable references in the manifests with their values
+type Renderer struct {
+	Values      map[string]interface{} // variable values, keyed by their lowerCamelCase name
+	Structured  map[string]interface{} // maps and lists from the params, keyed by their lowerCamelCase name, for $json
+	Passthrough map[string]bool        // names that are left as is, like kustomize vars, which kustomize substitutes
+
+	fileSystem filesys.FileSystem // where $file reads from
+	originals  map[string][]byte  // file content before rendering, keyed by path, for $file
+}
+
+// NewRenderer creates a Renderer for the values
+func NewRenderer(values map[string]interface{}) *Renderer {
+	return &Renderer{
+		Values:      values,
+		Structured:  make(map[string]interface{}),
+		Passthrough: make(map[string]bool),
+		fileSystem:  filesys.MakeFsOnDisk(),
+		originals:   make(map[string][]byte),
+	}
+}
+
+// formatValue returns the value used for $(name) and for $raw(name).
+// Integers are quoted for $(name) so they stay strings in yaml.
+func formatValue(value interface{}) (quoted, raw string, err error) {
+	switch typedValue := value.(type) {
+	case bool:
+		raw = strconv.FormatBool(typedValue)
+		return raw, raw, nil
+	case int:
+		raw = strconv.Itoa(typedValue)
+		return "\"" + raw + "\"", raw, nil
+	case string:
+		return typedValue, typedValue, nil
+	}
+
+	return "", "", fmt.Errorf("unsupported value type %T", value)
+}
+
+// readFile returns the content of the file at path, as it was before rendering
+func (r *Renderer) readFile(path string) ([]byte, error) {
+	if content, ok := r.originals[filepath.Clean(path)]; ok {
+		return content, nil
+	}
+
+	return r.fileSystem.ReadFile(path)
+}
+
+// evaluate returns what reference is replaced with. ok is false if the variable is not set.
+// path is the file the reference is in.
+func (r *Renderer) evaluate(path string, reference Reference) (result string, ok bool, err error) {
+	if reference.IsFile() {
+		content, err := r.readFile(filepath.Join(filepath.Dir(path), reference.Name))
+		if err != nil {
+			return "", false, err
+		}
+
+		return string(content), true, nil
+	}
+
+	// Structured params are only used by $json, and by $sha256 if there is no plain value
+	structured, isStructured := r.Structured[reference.Name]
+	if reference.Function == FunctionJSON && isStructured {
+		data, err := json.Marshal(structured)
+		if err != nil {
+			return "", false, err
+		}
+
+		return string(data), true, nil
+	}
+
+	value, ok := r.Values[reference.Name]
+	if !ok {
+		if reference.HasDefault() {
+			return reference.Default, true, nil
+		}
+
+		if reference.Function == FunctionSha256 && isStructured {
+			data, err := json.Marshal(structured)
+			if err != nil {
+				return "", false, err
+			}
+
+			return fmt.Sprintf("%x", sha256.Sum256(data)), true, nil
+		}
+
+		return "", false, nil
+	}
+
+	quoted, raw, err := formatValue(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	switch reference.Function {
+	case FunctionValue, FunctionDefault:
+		return quoted, true, nil
+	case FunctionRaw:
+		return raw, true, nil
+	case FunctionBase64:
+		return base64.StdEncoding.EncodeToString([]byte(raw)), true, nil
+	case FunctionSha256:
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(raw))), true, nil
+	case FunctionQuote:
+		data, err := json.Marshal(raw)
+		return string(data), true, err
+	case FunctionJSON:
+		data, err := json.Marshal(value)
+		return string(data), true, err
+	}
+
+	return "", false, fmt.Errorf("unknown function %v", reference.Function)
+}
+
+// Render substitutes the references in content, the content of the file at path, in one pass.
+// References with no value, that are not passed through, are left as is and returned.
+func (r *Renderer) Render(path string, content []byte) (rendered []byte, unresolved []Reference, err error) {
+	references := FindReferences(content)
+	unresolved = make([]Reference, 0)
+	if len(references) == 0 {
+		return content, unresolved, nil
+	}
+
+	result := bytes.Buffer{}
+	last := 0
+	for _, reference := range references {
+		value, ok, err := r.evaluate(path, reference)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %v: %v: %v", reference.Line, reference.String(), err.Error())
+		}
+
+		if !ok {
+			if !r.Passthrough[reference.Name] {
+				unresolved = append(unresolved, reference)
+			}
+			continue
+		}
+
+		if reference.IsFile() {
+			value = indentLines(value, indentation(content, reference.Start))
+		}
+
+		result.Write(content[last:reference.Start])
+		result.WriteString(value)
+		last = reference.End
+	}
+	result.Write(content[last:])
+
+	return result.Bytes(), unresolved, nil
+}
+
+// indentation returns the whitespace that lines up with the column of index in content
+func indentation(content []byte, index int) string {
+	lineStart := bytes.LastIndexByte(content[:index], '\n') + 1
+
+	return strings.Map(func(character rune) rune {
+		if character == '\t' {
+			return character
+		}
+		return ' '
+	}, string(content[lineStart:index]))
+}
+
+// indentLines prefixes every line of value, except the first one and empty lines, with indentation.
+// Multi-line $file content then stays under the key it is the value of, like a block scalar.
+func indentLines(value, indentation string) string {
+	if indentation == "" || !strings.Contains(value, "\n") {
+		return value
+	}
+
+	lines := strings.Split(value, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indentation + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// RenderDirectory substitutes the references in every file under root in fSys, in place.
+// Variables defined by kustomization.yaml files under root are passed through.
+// Unresolved references in the files and directories of strictPaths, relative to root, are returned as an *UnresolvedReferencesError.
+// Other files may belong to components that aren't used, so their unresolved references are ignored.
+func (r *Renderer) RenderDirectory(fSys filesys.FileSystem, root string, strictPaths []string) error {
+	r.fileSystem = fSys
+
+	filePaths := make([]string, 0)
+	err := fSys.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		content, err := fSys.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		r.originals[filepath.Clean(path)] = content
+
+		if info.Name() == "kustomization.yaml" {
+			if err := r.passthroughKustomizeVars(path, content); err != nil {
+				return err
+			}
+		}
+
+		filePaths = append(filePaths, path)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	unresolvedError := &UnresolvedReferencesError{
+		References: make([]UnresolvedReference, 0),
+	}
+	for _, path := range filePaths {
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		content := r.originals[filepath.Clean(path)]
+		rendered, unresolved, err := r.Render(path, content)
+		if err != nil {
+			return fmt.Errorf("%v: %v", relativePath, err.Error())
+		}
+
+		if isUnderAny(relativePath, strictPaths) {
+			for _, reference := range unresolved {
+				unresolvedError.References = append(unresolvedError.References, UnresolvedReference{
+					Path:      relativePath,
+					Reference: reference,
+				})
+			}
+		}
+
+		if bytes.Equal(rendered, content) {
+			continue
+		}
+
+		if err := fSys.WriteFile(path, rendered); err != nil {
+			return err
+		}
+	}
+
+	if len(unresolvedError.References) > 0 {
+		return unresolvedError
+	}
+
+	return nil
+}
+
+// passthroughKustomizeVars adds the variables defined in the kustomization file at path to the Passthrough
+func (r *Renderer) passthroughKustomizeVars(path string, content []byte) error {
+	k := &struct {
+		Vars []VarItem `yaml:"vars"`
+	}{}
+	if err := yaml.Unmarshal(content, k); err != nil {
+		return fmt.Errorf("%v: %v", path, err.Error())
+	}
+
+	for _, kustomizeVar := range k.Vars {
+		r.Passthrough[kustomizeVar.Name] = true
+	}
+
+	return nil
+}
+
+// isUnderAny returns true if relativePath is one of paths, or is under one of them
+func isUnderAny(relativePath string, paths []string) bool {
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if relativePath == path || strings.HasPrefix(relativePath, path+string(os.PathSeparator)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,166 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+func TestFindReferences(t *testing.T) {
+	content := []byte("a: $(applicationDomain)\nb: $raw(port) $base64(secret)\nc: $(POD_NAME) $$(escaped) $unknown(x) $() $(open\n")
+
+	references := FindReferences(content)
+
+	assert.Equal(t, 3, len(references))
+	assert.Equal(t, Reference{Function: FunctionValue, Name: "applicationDomain", Line: 1, Start: 3, End: 23}, references[0])
+	assert.Equal(t, "raw", references[1].Function)
+	assert.Equal(t, "port", references[1].Name)
+	assert.Equal(t, 2, references[1].Line)
+	assert.Equal(t, "base64", references[2].Function)
+	assert.Equal(t, "secret", references[2].Name)
+}
+
+func TestRenderer_Render(t *testing.T) {
+	renderer := NewRenderer(map[string]interface{}{
+		"domain":   "example.com",
+		"port":     8080,
+		"insecure": true,
+	})
+	renderer.Passthrough["kustomizeVar"] = true
+
+	rendered, unresolved, err := renderer.Render("deployment.yaml", []byte("a: $(domain)\nb: $(port)\nc: $raw(port)\nd: $base64(domain)\ne: $(insecure)\nf: $(kustomizeVar) $(typo)\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "a: example.com\nb: \"8080\"\nc: 8080\nd: ZXhhbXBsZS5jb20=\ne: true\nf: $(kustomizeVar) $(typo)\n", string(rendered))
+	assert.Equal(t, 1, len(unresolved))
+	assert.Equal(t, "typo", unresolved[0].Name)
+	assert.Equal(t, 6, unresolved[0].Line)
+}
+
+func TestFindReferences_Functions(t *testing.T) {
+	content := []byte("a: $default(replicas, 3) $default(tag, 'latest') $default(missingComma)\nb: $file(scripts/run.sh) $sha256(config) $json(pool) $quote(name)\n")
+
+	references := FindReferences(content)
+
+	assert.Equal(t, 6, len(references))
+	assert.Equal(t, "replicas", references[0].Name)
+	assert.Equal(t, "3", references[0].Default)
+	assert.Equal(t, "latest", references[1].Default)
+	assert.True(t, references[2].IsFile())
+	assert.Equal(t, "scripts/run.sh", references[2].Name)
+	assert.Equal(t, FunctionSha256, references[3].Function)
+	assert.Equal(t, FunctionJSON, references[4].Function)
+	assert.Equal(t, FunctionQuote, references[5].Function)
+}
+
+func TestRenderer_Render_Functions(t *testing.T) {
+	root, err := ioutil.TempDir("", "render")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "run.sh"), []byte("echo hi"), 0644))
+
+	renderer := NewRenderer(map[string]interface{}{
+		"replicas":  2,
+		"name":      `say "hi"`,
+		"config":    "abc",
+		"poolLabel": "pool",
+	})
+	renderer.Structured["pool"] = map[string]interface{}{"label": "pool"}
+
+	rendered, unresolved, err := renderer.Render(filepath.Join(root, "deployment.yaml"), []byte(
+		"a: $default(replicas, 1)\nb: $default(missing, 1)\nc: $quote(name)\nd: $sha256(config)\ne: $json(pool)\nf: $json(replicas)\ng: $file(run.sh)\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(unresolved))
+	assert.Equal(t, "a: \"2\"\nb: 1\nc: \"say \\\"hi\\\"\"\n"+
+		"d: ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad\n"+
+		"e: {\"label\":\"pool\"}\nf: 2\ng: echo hi\n", string(rendered))
+}
+
+func TestRenderer_Render_UnsupportedType(t *testing.T) {
+	renderer := NewRenderer(map[string]interface{}{
+		"list": []string{"a"},
+	})
+
+	_, _, err := renderer.Render("deployment.yaml", []byte("a: $(list)"))
+
+	assert.NotNil(t, err)
+}
+
+func TestRenderer_RenderDirectory(t *testing.T) {
+	fSys := filesys.MakeFsInMemory()
+	root := "/manifests"
+
+	writeFile := func(path, content string) {
+		assert.Nil(t, fSys.WriteFile(filepath.Join(root, path), []byte(content)))
+	}
+	writeFile(filepath.Join("used", "base", "deployment.yaml"), "a: $(domain)\nb: $(missing)\nc: $file(script.sh)\n")
+	writeFile(filepath.Join("used", "base", "script.sh"), "echo $(domain)")
+	writeFile(filepath.Join("used", "base", "kustomization.yaml"), "vars:\n- name: fromKustomize\n")
+	writeFile(filepath.Join("used", "overlays", "gke", "service.yaml"), "a: $(fromKustomize)\n")
+	writeFile(filepath.Join("unused", "base", "deployment.yaml"), "a: $(domain) $(unusedMissing)\n")
+
+	renderer := NewRenderer(map[string]interface{}{
+		"domain": "example.com",
+	})
+	err := renderer.RenderDirectory(fSys, root, []string{"used"})
+
+	unresolvedError, ok := err.(*UnresolvedReferencesError)
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(unresolvedError.References))
+	assert.Equal(t, filepath.Join("used", "base", "deployment.yaml"), unresolvedError.References[0].Path)
+	assert.Equal(t, 2, unresolvedError.References[0].Reference.Line)
+
+	content, err := fSys.ReadFile(filepath.Join(root, "used", "base", "deployment.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a: example.com\nb: $(missing)\nc: echo $(domain)\n", string(content))
+
+	content, err = fSys.ReadFile(filepath.Join(root, "unused", "base", "deployment.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "a: example.com $(unusedMissing)\n", string(content))
+}
+
+func TestFindReferences_Table(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"none", "kind: Deployment\n", []string{}},
+		{"value", "host: $(applicationDomain)\n", []string{"$(applicationDomain)"}},
+		{"several on a line", "url: $(scheme)://$(fqdn)$(path)\n", []string{"$(scheme)", "$(fqdn)", "$(path)"}},
+		{"default", "replicas: $default(replicas, 1)\n", []string{"$default(replicas, 1)"}},
+		{"file", "script: $file(run.sh)\n", []string{"$file(run.sh)"}},
+		{"kubernetes environment variable", "args: [$(POD_NAME)]\n", []string{}},
+		{"escaped", "args: [$$(name)]\n", []string{}},
+		{"unknown function", "value: $unknown(name)\n", []string{}},
+		{"unclosed", "value: $(name\nnext: line)\n", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := make([]string, 0)
+			for _, reference := range FindReferences([]byte(test.content)) {
+				actual = append(actual, reference.String())
+			}
+			assert.Equal(t, test.want, actual)
+		})
+	}
+}
+
+func TestRenderer_Render_FileIndentation(t *testing.T) {
+	root, err := ioutil.TempDir("", "render")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "run.sh"), []byte("#!/bin/sh\n\necho hi\n"), 0644))
+
+	renderer := NewRenderer(map[string]interface{}{})
+
+	rendered, _, err := renderer.Render(filepath.Join(root, "configmap.yaml"), []byte("data:\n  run.sh: |\n    $file(run.sh)\n  other: value\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "data:\n  run.sh: |\n    #!/bin/sh\n\n    echo hi\n\n  other: value\n", string(rendered))
+}
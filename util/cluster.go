@@ -0,0 +1,127 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// ClusterOptions select the cluster Kubernetes clients connect to. Empty fields use the defaults of kubectl.
+type ClusterOptions struct {
+	// Kubeconfig is the kubeconfig file. If empty, KUBECONFIG or ~/.kube/config is used.
+	Kubeconfig string
+	// Context is the kubeconfig context. If empty, the current context is used.
+	Context string
+	// Cluster is the kubeconfig cluster. If empty, the one of the context is used.
+	Cluster string
+	// Server if set, clients refuse to connect to another API server
+	Server string
+}
+
+var (
+	clusterOptionsMutex sync.Mutex
+	clusterOptions      ClusterOptions
+)
+
+// SetClusterOptions sets the cluster every client connects to
+func SetClusterOptions(options ClusterOptions) {
+	clusterOptionsMutex.Lock()
+	defer clusterOptionsMutex.Unlock()
+
+	clusterOptions = options
+}
+
+// GetClusterOptions returns the options set by SetClusterOptions
+func GetClusterOptions() ClusterOptions {
+	clusterOptionsMutex.Lock()
+	defer clusterOptionsMutex.Unlock()
+
+	return clusterOptions
+}
+
+// newClientConfig returns the kubeconfig selected by the cluster options
+func newClientConfig() clientcmd.ClientConfig {
+	options := GetClusterOptions()
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = options.Kubeconfig
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: options.Context}
+	overrides.Context.Cluster = options.Cluster
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+}
+
+// CurrentContext returns the name of the kubeconfig context clients connect with
+func CurrentContext() (string, error) {
+	options := GetClusterOptions()
+	if options.Context != "" {
+		return options.Context, nil
+	}
+
+	config, err := newClientConfig().RawConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return config.CurrentContext, nil
+}
+
+// ContextCluster returns the kubeconfig cluster of the context options select, ignoring options.Cluster
+func ContextCluster(options ClusterOptions) (string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = options.Kubeconfig
+
+	config, err := loadingRules.Load()
+	if err != nil {
+		return "", err
+	}
+
+	contextName := options.Context
+	if contextName == "" {
+		contextName = config.CurrentContext
+	}
+
+	context, ok := config.Contexts[contextName]
+	if !ok {
+		return "", fmt.Errorf("the kubeconfig has no context '%v'", contextName)
+	}
+
+	return context.Cluster, nil
+}
+
+// checkServer returns an error if host is not the server of the cluster options, if it is set
+func checkServer(host string) error {
+	server := GetClusterOptions().Server
+	if server == "" {
+		return nil
+	}
+
+	if normalizeServer(host) != normalizeServer(server) {
+		return fmt.Errorf("the cluster at %v is not the one at %v the deployment is pinned to. Select it with --context, or change spec.cluster in config.yaml", host, server)
+	}
+
+	return nil
+}
+
+// normalizeServer returns server without a trailing slash, and with the default port of its scheme
+func normalizeServer(server string) string {
+	parsed, err := url.Parse(strings.TrimSuffix(server, "/"))
+	if err != nil || parsed.Host == "" {
+		return strings.TrimSuffix(server, "/")
+	}
+
+	if parsed.Port() == "" {
+		switch parsed.Scheme {
+		case "https":
+			parsed.Host += ":443"
+		case "http":
+			parsed.Host += ":80"
+		}
+	}
+
+	return parsed.Scheme + "://" + parsed.Host + parsed.Path
+}
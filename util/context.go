@@ -0,0 +1,46 @@
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep waits for d. It returns the error of ctx if ctx is done first, like when the command is interrupted.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Poll calls condition every interval, until it is true, or timeout elapses. false is returned if it timed out.
+// The error of ctx is returned if ctx is done first.
+func Poll(ctx context.Context, interval, timeout time.Duration, condition func(ctx context.Context) (bool, error)) (bool, error) {
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		done, err := condition(pollCtx)
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if pollCtx.Err() != nil {
+			return false, nil
+		}
+		if err != nil || done {
+			return done, err
+		}
+
+		if err := Sleep(pollCtx, interval); err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			return false, nil
+		}
+	}
+}
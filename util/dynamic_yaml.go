@@ -5,7 +5,6 @@ import (
 	"github.com/iancoleman/strcase"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
-	"log"
 	"sort"
 	"strconv"
 	"strings"
@@ -452,6 +451,29 @@ func sortNodePairs(node *yaml.Node) {
 	node.Content = sorted
 }
 
+// ClearStringValues empties every string value, keeping the keys, comments, and other values like booleans.
+// This turns params into a template, without site specific values or credentials.
+func (d *DynamicYaml) ClearStringValues() {
+	clearStringValues(d.node)
+}
+
+func clearStringValues(node *yaml.Node) {
+	for i, child := range node.Content {
+		// Keys of mappings are at even indexes
+		if node.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+
+		if child.Kind == yaml.ScalarNode && child.Tag == "!!str" {
+			child.Value = ""
+			child.Style = 0
+			continue
+		}
+
+		clearStringValues(child)
+	}
+}
+
 func (d *DynamicYaml) String() (string, error) {
 	builder := &strings.Builder{}
 	encoder := yaml.NewEncoder(builder)
@@ -535,7 +557,7 @@ func (d *DynamicYaml) Flatten(keyFormatter FlatMapKeyFormatter) map[string]NodeP
 	return results
 }
 
-func (d *DynamicYaml) FlattenToKeyValue(keyFormatter FlatMapKeyFormatter) map[string]interface{} {
+func (d *DynamicYaml) FlattenToKeyValue(keyFormatter FlatMapKeyFormatter) (map[string]interface{}, error) {
 	results := make(map[string]NodePair)
 
 	flattenMap("", keyFormatter, d.node, results)
@@ -545,14 +567,53 @@ func (d *DynamicYaml) FlattenToKeyValue(keyFormatter FlatMapKeyFormatter) map[st
 	for key := range results {
 		value, err := NodeValueToActual(results[key].Value)
 		if err != nil {
-			log.Fatal("Unable to convert node value: %v", err.Error())
-			continue
+			return nil, fmt.Errorf("unable to convert the value of %v: %v", key, err.Error())
 		}
 
 		flatResult[key] = value
 	}
 
-	return flatResult
+	return flatResult, nil
+}
+
+// FlattenToStructured returns every map and list in the data, decoded, keyed by its flattened path.
+// Scalars are not included, FlattenToKeyValue returns those.
+func (d *DynamicYaml) FlattenToStructured(keyFormatter FlatMapKeyFormatter) (map[string]interface{}, error) {
+	results := make(map[string]interface{})
+	if d.node == nil || len(d.node.Content) == 0 {
+		return results, nil
+	}
+
+	err := flattenStructured("", keyFormatter, d.node.Content[0], results)
+
+	return results, err
+}
+
+func flattenStructured(path string, keyFormatter FlatMapKeyFormatter, node *yaml.Node, results map[string]interface{}) error {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 1; i < len(node.Content); i += 2 {
+		childNode := node.Content[i]
+		if childNode.Kind != yaml.MappingNode && childNode.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		newPath := keyFormatter(path, node.Content[i-1].Value)
+
+		var value interface{}
+		if err := childNode.Decode(&value); err != nil {
+			return err
+		}
+		results[newPath] = value
+
+		if err := flattenStructured(newPath, keyFormatter, childNode, results); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // FlattenRequiredDefault goes through the data and finds values with a default
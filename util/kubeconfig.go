@@ -3,6 +3,8 @@ package util
 import (
 	"context"
 	"fmt"
+	"github.com/onepanelio/cli/apply"
+	"github.com/onepanelio/cli/logging"
 	"github.com/pkg/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -11,7 +13,11 @@ import (
 	"regexp"
 	"time"
 
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/transport"
 )
@@ -20,8 +26,8 @@ type Config = restclient.Config
 
 const onepanelEnabledLabelKey = "onepanel.io/enabled"
 
-func ListOnepanelEnabledNamespaces(c *kubernetes.Clientset) (namespaces []string, err error) {
-	namespaceList, err := c.CoreV1().Namespaces().List(context.Background(), v1.ListOptions{
+func ListOnepanelEnabledNamespaces(ctx context.Context, c *kubernetes.Clientset) (namespaces []string, err error) {
+	namespaceList, err := c.CoreV1().Namespaces().List(ctx, v1.ListOptions{
 		LabelSelector: fmt.Sprintf("%s=%s", onepanelEnabledLabelKey, "true"),
 	})
 
@@ -36,10 +42,19 @@ func ListOnepanelEnabledNamespaces(c *kubernetes.Clientset) (namespaces []string
 	return
 }
 
-// NewConfig creates a new, default, configuration for kubernetes
+// NewConfig creates a new configuration for kubernetes, for the cluster selected by SetClusterOptions.
+// Calls made with it are logged at the trace level.
 func NewConfig() (config *Config, err error) {
-	config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-		clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{}).ClientConfig()
+	config, err = newClientConfig().ClientConfig()
+	if err != nil {
+		return
+	}
+
+	if err = checkServer(config.Host); err != nil {
+		return nil, err
+	}
+
+	config.Wrap(logging.TraceKubernetesAPI)
 
 	return
 }
@@ -54,7 +69,36 @@ func NewKubernetesClient() (*kubernetes.Clientset, error) {
 	return kubernetes.NewForConfig(config)
 }
 
-func GetBearerToken(in *restclient.Config, explicitKubeConfigPath string, serviceAccountName string) (token string, username string, err error) {
+// NewApplyEngine creates an engine applying and deleting resources with the config returned from NewConfig.
+// Namespaced resources without a namespace go to the namespace of the kubeconfig context.
+func NewApplyEngine() (*apply.Engine, error) {
+	config, err := NewConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, _, err := newClientConfig().Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	engine := apply.NewEngine(dynamicClient, restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)))
+	engine.DefaultNamespace = namespace
+
+	return engine, nil
+}
+
+func GetBearerToken(ctx context.Context, in *restclient.Config, explicitKubeConfigPath string, serviceAccountName string) (token string, username string, err error) {
 	if in == nil {
 		return "", serviceAccountName, errors.Errorf("RestClient can't be nil")
 	}
@@ -65,14 +109,14 @@ func GetBearerToken(in *restclient.Config, explicitKubeConfigPath string, servic
 	}
 
 	namespaces := []string{"onepanel"}
-	moreNamespaces, err := ListOnepanelEnabledNamespaces(kubeClient)
+	moreNamespaces, err := ListOnepanelEnabledNamespaces(ctx, kubeClient)
 	if err != nil {
 		return "", "", err
 	}
 	namespaces = append(namespaces, moreNamespaces...)
 
 	for _, namespace := range namespaces {
-		secrets, err := kubeClient.CoreV1().Secrets(namespace).List(context.Background(), v1.ListOptions{})
+		secrets, err := kubeClient.CoreV1().Secrets(namespace).List(ctx, v1.ListOptions{})
 		if err != nil {
 			return "", serviceAccountName, errors.Errorf("Could not get %s secrets.", namespace)
 		}
@@ -115,10 +159,16 @@ func RefreshTokenIfExpired(restConfig *restclient.Config, explicitPath, curentTo
 }
 
 func ReloadKubeConfig(explicitPath string) clientcmd.ClientConfig {
+	options := GetClusterOptions()
+	if explicitPath == "" {
+		explicitPath = options.Kubeconfig
+	}
+
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	loadingRules.DefaultClientConfig = &clientcmd.DefaultClientConfig
 	loadingRules.ExplicitPath = explicitPath
-	overrides := clientcmd.ConfigOverrides{}
+	overrides := clientcmd.ConfigOverrides{CurrentContext: options.Context}
+	overrides.Context.Cluster = options.Cluster
 	return clientcmd.NewInteractiveDeferredLoadingClientConfig(loadingRules, &overrides, os.Stdin)
 }
 
@@ -23,8 +23,8 @@ func NamespacesToCheck(yamlFile *DynamicYaml) []string {
 }
 
 // IsApplicationControllerManagerRunning checks if the application-controller-manager pod is running
-func IsApplicationControllerManagerRunning(c *kubernetes.Clientset) (bool, error) {
-	pod, err := c.CoreV1().Pods("application-system").Get(context.Background(), "application-controller-manager-0", v1.GetOptions{})
+func IsApplicationControllerManagerRunning(ctx context.Context, c *kubernetes.Clientset) (bool, error) {
+	pod, err := c.CoreV1().Pods("application-system").Get(ctx, "application-controller-manager-0", v1.GetOptions{})
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			return false, nil
@@ -36,9 +36,9 @@ func IsApplicationControllerManagerRunning(c *kubernetes.Clientset) (bool, error
 }
 
 // NamespacesExist checks if the cluster has the input namespaces
-func NamespacesExist(c *kubernetes.Clientset, namespaces ...string) (bool, error) {
+func NamespacesExist(ctx context.Context, c *kubernetes.Clientset, namespaces ...string) (bool, error) {
 	for _, namespace := range namespaces {
-		_, err := c.CoreV1().Namespaces().Get(context.Background(), namespace, v1.GetOptions{})
+		_, err := c.CoreV1().Namespaces().Get(ctx, namespace, v1.GetOptions{})
 		if err != nil {
 			if strings.Contains(err.Error(), "not found") {
 				return false, nil
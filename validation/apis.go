@@ -0,0 +1,79 @@
+package validation
+
+// apiLifecycle is when an API version was introduced to, or removed from, Kubernetes.
+// Kind is empty when it applies to every kind of the group and version.
+type apiLifecycle struct {
+	Group      string
+	Version    string
+	Kind       string
+	Introduced *Version
+	Removed    *Version
+	// Replacement is the API version to use instead, once removed
+	Replacement string
+}
+
+func minor(m int) *Version {
+	return &Version{Major: 1, Minor: m}
+}
+
+// apiLifecycles are the built-in APIs that were removed, or introduced after 1.8, see
+// https://kubernetes.io/docs/reference/using-api/deprecation-guide/
+var apiLifecycles = []apiLifecycle{
+	{Group: "extensions", Version: "v1beta1", Kind: "Deployment", Removed: minor(16), Replacement: "apps/v1"},
+	{Group: "extensions", Version: "v1beta1", Kind: "DaemonSet", Removed: minor(16), Replacement: "apps/v1"},
+	{Group: "extensions", Version: "v1beta1", Kind: "ReplicaSet", Removed: minor(16), Replacement: "apps/v1"},
+	{Group: "extensions", Version: "v1beta1", Kind: "NetworkPolicy", Removed: minor(16), Replacement: "networking.k8s.io/v1"},
+	{Group: "extensions", Version: "v1beta1", Kind: "PodSecurityPolicy", Removed: minor(16), Replacement: "policy/v1beta1"},
+	{Group: "extensions", Version: "v1beta1", Kind: "Ingress", Removed: minor(22), Replacement: "networking.k8s.io/v1"},
+	{Group: "apps", Version: "v1beta1", Removed: minor(16), Replacement: "apps/v1"},
+	{Group: "apps", Version: "v1beta2", Removed: minor(16), Replacement: "apps/v1"},
+	{Group: "networking.k8s.io", Version: "v1beta1", Kind: "Ingress", Removed: minor(22), Replacement: "networking.k8s.io/v1"},
+	{Group: "networking.k8s.io", Version: "v1beta1", Kind: "IngressClass", Removed: minor(22), Replacement: "networking.k8s.io/v1"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress", Introduced: minor(19)},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "IngressClass", Introduced: minor(19)},
+	{Group: "apiextensions.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "apiextensions.k8s.io/v1"},
+	{Group: "apiextensions.k8s.io", Version: "v1", Introduced: minor(16)},
+	{Group: "admissionregistration.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "admissionregistration.k8s.io/v1"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Introduced: minor(16)},
+	{Group: "apiregistration.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "apiregistration.k8s.io/v1"},
+	{Group: "authentication.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "authentication.k8s.io/v1"},
+	{Group: "authorization.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "authorization.k8s.io/v1"},
+	{Group: "certificates.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "certificates.k8s.io/v1"},
+	{Group: "certificates.k8s.io", Version: "v1", Introduced: minor(19)},
+	{Group: "coordination.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "coordination.k8s.io/v1"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "rbac.authorization.k8s.io/v1"},
+	{Group: "scheduling.k8s.io", Version: "v1beta1", Removed: minor(22), Replacement: "scheduling.k8s.io/v1"},
+	{Group: "scheduling.k8s.io", Version: "v1", Introduced: minor(14)},
+	{Group: "storage.k8s.io", Version: "v1beta1", Kind: "CSIDriver", Removed: minor(22), Replacement: "storage.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Kind: "CSINode", Removed: minor(22), Replacement: "storage.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Kind: "StorageClass", Removed: minor(22), Replacement: "storage.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Kind: "VolumeAttachment", Removed: minor(22), Replacement: "storage.k8s.io/v1"},
+	{Group: "storage.k8s.io", Version: "v1beta1", Kind: "CSIStorageCapacity", Removed: minor(27), Replacement: "storage.k8s.io/v1"},
+	{Group: "batch", Version: "v1beta1", Kind: "CronJob", Removed: minor(25), Replacement: "batch/v1"},
+	{Group: "batch", Version: "v1", Kind: "CronJob", Introduced: minor(21)},
+	{Group: "discovery.k8s.io", Version: "v1beta1", Kind: "EndpointSlice", Removed: minor(25), Replacement: "discovery.k8s.io/v1"},
+	{Group: "discovery.k8s.io", Version: "v1", Kind: "EndpointSlice", Introduced: minor(21)},
+	{Group: "events.k8s.io", Version: "v1beta1", Kind: "Event", Removed: minor(25), Replacement: "events.k8s.io/v1"},
+	{Group: "policy", Version: "v1beta1", Kind: "PodDisruptionBudget", Removed: minor(25), Replacement: "policy/v1"},
+	{Group: "policy", Version: "v1beta1", Kind: "PodSecurityPolicy", Removed: minor(25)},
+	{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget", Introduced: minor(21)},
+	{Group: "node.k8s.io", Version: "v1beta1", Kind: "RuntimeClass", Removed: minor(25), Replacement: "node.k8s.io/v1"},
+	{Group: "node.k8s.io", Version: "v1", Kind: "RuntimeClass", Introduced: minor(20)},
+	{Group: "autoscaling", Version: "v2beta1", Removed: minor(25), Replacement: "autoscaling/v2"},
+	{Group: "autoscaling", Version: "v2beta2", Removed: minor(26), Replacement: "autoscaling/v2"},
+	{Group: "autoscaling", Version: "v2", Introduced: minor(23)},
+	{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta1", Removed: minor(26), Replacement: "flowcontrol.apiserver.k8s.io/v1beta3"},
+	{Group: "flowcontrol.apiserver.k8s.io", Version: "v1beta2", Removed: minor(29), Replacement: "flowcontrol.apiserver.k8s.io/v1beta3"},
+}
+
+// findAPILifecycle returns the lifecycle of kind in group and version, nil if it is not in apiLifecycles
+func findAPILifecycle(group, version, kind string) *apiLifecycle {
+	for i := range apiLifecycles {
+		lifecycle := &apiLifecycles[i]
+		if lifecycle.Group == group && lifecycle.Version == version && (lifecycle.Kind == "" || lifecycle.Kind == kind) {
+			return lifecycle
+		}
+	}
+
+	return nil
+}
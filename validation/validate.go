@@ -0,0 +1,144 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/onepanelio/cli/internal/resourceutil"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Problem is why a resource is not valid for a Kubernetes version
+type Problem struct {
+	Resource string
+	Message  string
+}
+
+func (p Problem) String() string {
+	return p.Resource + ": " + p.Message
+}
+
+// Error lists the resources of a build that are not valid for a Kubernetes version
+type Error struct {
+	Version  Version
+	Problems []Problem
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("%v resources are not valid for Kubernetes %v:", len(e.Problems), e.Version.String()))
+	for _, problem := range e.Problems {
+		lines = append(lines, "- "+problem.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks each resource of rm against the schema of its kind, and the APIs that Kubernetes version has.
+// Unknown fields and fields of the wrong type are problems, as are API versions removed in, or introduced after, version.
+// version only selects the API versions: the schemas are always the API types built into opctl, those of DefaultVersion.
+// Custom resources are only checked for their API version.
+// If there are problems, an *Error is returned.
+func Validate(rm resmap.ResMap, version Version) error {
+	customKinds := findCustomKinds(rm)
+
+	problems := make([]Problem, 0)
+	for _, r := range rm.Resources() {
+		for _, message := range validateResource(r, version, customKinds) {
+			problems = append(problems, Problem{Resource: resourceutil.Describe(r), Message: message})
+		}
+	}
+
+	if len(problems) != 0 {
+		return &Error{Version: version, Problems: problems}
+	}
+
+	return nil
+}
+
+// findCustomKinds returns the group and kind of each CustomResourceDefinition in rm, as in schema.GroupKind.String()
+func findCustomKinds(rm resmap.ResMap) map[string]bool {
+	result := make(map[string]bool)
+	for _, r := range rm.Resources() {
+		if r.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+
+		group := stringField(r, "spec", "group")
+		kind := stringField(r, "spec", "names", "kind")
+		if kind == "" {
+			continue
+		}
+
+		result[schema.GroupKind{Group: group, Kind: kind}.String()] = true
+	}
+
+	return result
+}
+
+// stringField returns the value of the field at path in r, empty if there is none
+func stringField(r *resource.Resource, path ...string) string {
+	node, err := r.Pipe(kyaml.Lookup(path...))
+	if err != nil || node == nil {
+		return ""
+	}
+
+	return node.YNode().Value
+}
+
+// validateResource returns the problems of r
+func validateResource(r *resource.Resource, version Version, customKinds map[string]bool) []string {
+	gvk := r.GetGvk()
+	groupVersionKind := schema.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}
+	apiVersion := groupVersionKind.GroupVersion().String()
+
+	if lifecycle := findAPILifecycle(gvk.Group, gvk.Version, gvk.Kind); lifecycle != nil {
+		if lifecycle.Removed != nil && version.AtLeast(*lifecycle.Removed) {
+			message := fmt.Sprintf("%v %v was removed in Kubernetes %v", apiVersion, gvk.Kind, lifecycle.Removed.String())
+			if lifecycle.Replacement != "" {
+				message += ", use " + lifecycle.Replacement
+			}
+			return []string{message}
+		}
+
+		if lifecycle.Introduced != nil && !version.AtLeast(*lifecycle.Introduced) {
+			return []string{fmt.Sprintf("%v %v is only available from Kubernetes %v", apiVersion, gvk.Kind, lifecycle.Introduced.String())}
+		}
+	}
+
+	if customKinds[groupVersionKind.GroupKind().String()] {
+		return nil
+	}
+
+	if !scheme.Scheme.Recognizes(groupVersionKind) {
+		if scheme.Scheme.IsVersionRegistered(groupVersionKind.GroupVersion()) {
+			return []string{fmt.Sprintf("%v has no kind %v", apiVersion, gvk.Kind)}
+		}
+
+		return nil
+	}
+
+	object, err := scheme.Scheme.New(groupVersionKind)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	data, err := r.MarshalJSON()
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(object); err != nil {
+		return []string{strings.TrimPrefix(err.Error(), "json: ")}
+	}
+
+	return nil
+}
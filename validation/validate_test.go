@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
+)
+
+func buildResMap(t *testing.T, content string) resmap.ResMap {
+	fSys := filesys.MakeFsInMemory()
+	assert.Nil(t, fSys.WriteFile("/build/resources.yaml", []byte(content)))
+	assert.Nil(t, fSys.WriteFile("/build/kustomization.yaml", []byte("resources:\n- resources.yaml\n")))
+
+	rm, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, "/build")
+	assert.Nil(t, err)
+
+	return rm
+}
+
+func TestParseVersion(t *testing.T) {
+	for _, input := range []string{"1.21", "v1.21.3", "1.21+"} {
+		version, err := ParseVersion(input)
+		assert.Nil(t, err)
+		assert.Equal(t, Version{Major: 1, Minor: 21}, *version)
+	}
+
+	_, err := ParseVersion("latest")
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	rm := buildResMap(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: core
+  namespace: onepanel
+spec:
+  selector:
+    matchLabels:
+      app: core
+  template:
+    spec:
+      containers:
+      - name: core
+        image: onepanel/core:v1.0.0
+        ports:
+        - containerPort: 8888
+---
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: core
+---
+apiVersion: onepanel.io/v1
+kind: Workspace
+metadata:
+  name: custom
+`)
+
+	assert.Nil(t, Validate(rm, Version{Major: 1, Minor: 21}))
+
+	err := Validate(rm, Version{Major: 1, Minor: 22})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(err.(*Error).Problems))
+	assert.Equal(t, "Ingress core: extensions/v1beta1 Ingress was removed in Kubernetes 1.22, use networking.k8s.io/v1", err.(*Error).Problems[0].String())
+}
+
+func TestValidate_Schema(t *testing.T) {
+	rm := buildResMap(t, `apiVersion: v1
+kind: Service
+metadata:
+  name: core
+spec:
+  ports:
+  - port: "http"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+date:
+  key: value
+---
+apiVersion: v1
+kind: Widget
+metadata:
+  name: widget
+`)
+
+	err := Validate(rm, DefaultVersion)
+	assert.NotNil(t, err)
+
+	problems := err.(*Error).Problems
+	assert.Equal(t, 3, len(problems))
+	assert.Contains(t, problems[0].String(), "Service core: cannot unmarshal string")
+	assert.Equal(t, `ConfigMap config: unknown field "date"`, problems[1].String())
+	assert.Equal(t, "Widget widget: v1 has no kind Widget", problems[2].String())
+}
@@ -0,0 +1,54 @@
+package validation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultVersion is the Kubernetes version of the API types built into opctl, which the schemas come from whatever the target version
+var DefaultVersion = Version{Major: 1, Minor: 21}
+
+// Version is a Kubernetes minor version, like 1.21
+type Version struct {
+	Major int
+	Minor int
+}
+
+// ParseVersion parses a Kubernetes version like 1.21, v1.21.3 or the 1.21+ some providers report
+func ParseVersion(version string) (*Version, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("'%v' is not a Kubernetes version, like 1.21", version)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("'%v' is not a Kubernetes version, like 1.21", version)
+	}
+
+	minor, err := strconv.Atoi(strings.TrimRight(parts[1], "+"))
+	if err != nil {
+		return nil, fmt.Errorf("'%v' is not a Kubernetes version, like 1.21", version)
+	}
+
+	return &Version{Major: major, Minor: minor}, nil
+}
+
+// ParseServerVersion returns the version of a cluster from the major and minor versions its API reports
+func ParseServerVersion(major, minor string) (*Version, error) {
+	return ParseVersion(major + "." + minor)
+}
+
+// AtLeast returns true if v is other or newer
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+
+	return v.Minor >= other.Minor
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%v.%v", v.Major, v.Minor)
+}